@@ -1,7 +1,11 @@
 package main
 
 import (
+	"log"
+	_ "time/tzdata" // Time zones of routing rules, also on images without zoneinfo
+
 	"github.com/gin-gonic/gin"
+	"github.com/mca93/qrcode_service/controllers"
 	"github.com/mca93/qrcode_service/routes"
 
 	cmd "github.com/mca93/qrcode_service/cmd/swagger"
@@ -10,8 +14,17 @@ import (
 
 func main() {
 	r := gin.Default() // cria um novo router
+	// O IP do cliente limita as tentativas de PIN e identifica os visitantes das variantes
+	if err := r.SetTrustedProxies(config.TrustedProxies()); err != nil {
+		log.Fatal("invalid TRUSTED_PROXIES: ", err)
+	}
 	cmd.RegisterSwagger(r)
 	config.InitDB()
+	config.InitKeys()
+	config.InitStorage()
+	config.InitRedemptions()
+	controllers.StartUploadCollector()
+	controllers.StartKeyMaintenance()
 	routes.SetupRoutes(r)
 	r.Run(":8080")
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/mca93/qrcode_service/models"
 	"gorm.io/driver/postgres"
@@ -25,10 +26,29 @@ func InitDB() {
 	}
 
 	// 👉 AutoMigrate para criar tabelas se não existirem
-	err = db.AutoMigrate(&models.ClientApp{}, &models.Template{}, &models.QRCode{})
+	err = db.AutoMigrate(&models.ClientApp{}, &models.Template{}, &models.QRCode{}, &models.ScanEvent{}, &models.StaffDevice{}, &models.Redemption{}, &models.ManagedKey{}, &models.UnlockCounter{})
 	//&models.QRCode{})
 	if err != nil {
 		log.Fatal("failed to migrate tables: ", err)
 	}
+	// O índice simples de digital_link foi substituído pelo índice único parcial
+	if db.Migrator().HasIndex(&models.QRCode{}, "idx_qr_codes_digital_link") {
+		if err := db.Migrator().DropIndex(&models.QRCode{}, "idx_qr_codes_digital_link"); err != nil {
+			log.Fatal("failed to drop index: ", err)
+		}
+	}
 	DB = db
 }
+
+// TrustedProxies returns the proxies listed in TRUSTED_PROXIES, comma-separated IPs or CIDRs,
+// whose X-Forwarded-For header gives the client IP. With none, the client IP is the address the
+// request came from, since the header of anyone else can be forged.
+func TrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/mca93/qrcode_service/keystore"
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/signing"
+)
+
+// Keys holds the signing, encryption, webhook and URL signing keys of the service.
+var Keys *keystore.Store
+
+// InitKeys opens the key store, whose keys are encrypted under KEY_STORE_MASTER_KEY, 32 bytes
+// encoded as base64 (e.g. from `openssl rand -base64 32`). It must stay the same across
+// restarts and instances, or stored keys cannot be decrypted.
+func InitKeys() {
+	encoded := os.Getenv("KEY_STORE_MASTER_KEY")
+	if encoded == "" {
+		log.Fatal("KEY_STORE_MASTER_KEY is not set")
+	}
+	masterKey, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		log.Fatal("KEY_STORE_MASTER_KEY is not valid base64: ", err)
+	}
+	if Keys, err = keystore.Open(DB, masterKey); err != nil {
+		log.Fatal("failed to open key store: ", err)
+	}
+	if err := importSigningKeyFiles(); err != nil {
+		log.Fatal("failed to import signing keys: ", err)
+	}
+}
+
+// importSigningKeyFiles carries over the keys payloads were signed with before the key store,
+// so printed tokens keep verifying. SIGNING_KEY_FILE, a PEM private key identified by
+// SIGNING_KEY_ID or its thumbprint, is imported once as the current signing key, and is
+// retired by the next rotation like any other. The retired public keys of the JWKS in
+// SIGNING_PUBLIC_KEYS_FILE cannot be stored, and are added for verification on every start.
+func importSigningKeyFiles() error {
+	if path := os.Getenv("SIGNING_KEY_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		key, err := signing.ParsePrivateKey(os.Getenv("SIGNING_KEY_ID"), data)
+		if err != nil {
+			return err
+		}
+		material, err := key.MarshalPrivateKey()
+		if err != nil {
+			return err
+		}
+		if _, err := Keys.Import(models.KeyPurposeSigning, key.ID, key.Algorithm, material); err != nil {
+			return err
+		}
+	}
+
+	if path := os.Getenv("SIGNING_PUBLIC_KEYS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var jwks signing.JWKS
+		if err := json.Unmarshal(data, &jwks); err != nil {
+			return err
+		}
+		for _, jwk := range jwks.Keys {
+			public, err := signing.ParseJWK(jwk)
+			if err != nil {
+				return err
+			}
+			Keys.AddVerificationKeys(public)
+		}
+	}
+	return nil
+}
+
+// SigningKeys returns the keys payloads are signed and verified with, or nil when the key
+// store is not open.
+func SigningKeys() *signing.KeySet {
+	if Keys == nil {
+		return nil
+	}
+	keys, err := Keys.SigningKeySet()
+	if err != nil {
+		log.Printf("Failed to load signing keys: %v", err)
+		return nil
+	}
+	return keys
+}
+
+// SigningKeysFor returns the keys to verify token with, picking up a key another instance
+// rotated to since the last reload.
+func SigningKeysFor(token string) *signing.KeySet {
+	if Keys == nil {
+		return nil
+	}
+	keys, err := Keys.SigningKeySetFor(signing.KeyID(token))
+	if err != nil {
+		log.Printf("Failed to load signing keys: %v", err)
+		return nil
+	}
+	return keys
+}
@@ -0,0 +1,20 @@
+package config
+
+import (
+	"log"
+	"time"
+)
+
+// MaxOfflineAge is how long before it is synced an offline redemption may have been made.
+var MaxOfflineAge = 72 * time.Hour
+
+// InitRedemptions reads REDEMPTION_MAX_OFFLINE_AGE, the longest a staff device may redeem codes
+// offline, as a duration such as "72h".
+func InitRedemptions() {
+	value := getEnv("REDEMPTION_MAX_OFFLINE_AGE", MaxOfflineAge.String())
+	age, err := time.ParseDuration(value)
+	if err != nil || age <= 0 {
+		log.Fatalf("invalid REDEMPTION_MAX_OFFLINE_AGE %q, must be a positive duration such as 72h", value)
+	}
+	MaxOfflineAge = age
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/storage"
+)
+
+// Storage keeps uploaded and generated assets.
+var Storage storage.Storage
+
+// InitStorage sets up the asset storage chosen by STORAGE_BACKEND: "local" (default), which
+// keeps files under STORAGE_LOCAL_DIR, or "s3", for AWS S3 and compatible servers such as MinIO.
+func InitStorage() {
+	switch backend := getEnv("STORAGE_BACKEND", "local"); backend {
+	case "local":
+		Storage = storage.NewLocal(
+			getEnv("STORAGE_LOCAL_DIR", "./uploads"),
+			getEnv("ASSET_BASE_URL", models.DeepLinkBaseURL()),
+			Keys.HMACKeys(models.KeyPurposeURLSigning),
+		)
+	case "s3":
+		pathStyle, _ := strconv.ParseBool(os.Getenv("S3_FORCE_PATH_STYLE"))
+		s3, err := storage.NewS3(storage.S3Config{
+			Endpoint:        getEnv("S3_ENDPOINT", "https://s3.amazonaws.com"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PathStyle:       pathStyle,
+		})
+		if err != nil {
+			log.Fatal("failed to set up asset storage: ", err)
+		}
+		Storage = s3
+	default:
+		log.Fatalf("unknown STORAGE_BACKEND %q, must be local or s3", backend)
+	}
+}
+
+func getEnv(key, defaultValue string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return defaultValue
+}
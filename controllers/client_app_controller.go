@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -8,6 +9,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/mca93/qrcode_service/config"
 	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/utils"
 	"github.com/mca93/qrcode_service/validators"
 
 	"github.com/gin-gonic/gin"
@@ -35,7 +37,21 @@ func CreateClientApp(c *gin.Context) {
 		CreatedAt:    time.Now(),
 	}
 
+	// O slug prefixa os caminhos personalizados dos QR codes e é único entre os aplicativos
+	if req.Slug != "" {
+		if err := ensureClientAppSlugAvailable(req.Slug, clientApp.ID); err != nil {
+			respondWithSlugError(c, err)
+			return
+		}
+		clientApp.Slug = &req.Slug
+	}
+
 	if err := config.DB.Create(&clientApp).Error; err != nil {
+		// Outro aplicativo pode ter ficado com o slug desde a verificação
+		if uniqueViolation(err) == clientAppSlugIndex {
+			respondWithSlugError(c, errSlugTaken)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create client app"})
 		return
 	}
@@ -86,6 +102,7 @@ func ListClientApps(c *gin.Context) {
 			CreatedAt:    a.CreatedAt,
 			UpdatedAt:    a.CreatedAt,
 			DeletedAt:    nil,
+			Slug:         a.Slug,
 		})
 	}
 
@@ -155,9 +172,26 @@ func UpdateClientApp(c *gin.Context) {
 	if req.Status != "" {
 		clientApp.Status = req.Status
 	}
+	// Mudar o slug quebraria os QR codes já impressos com caminhos personalizados
+	if req.Slug != "" && (clientApp.Slug == nil || *clientApp.Slug != req.Slug) {
+		if clientApp.Slug != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "slug cannot be changed once set"})
+			return
+		}
+		if err := ensureClientAppSlugAvailable(req.Slug, clientApp.ID); err != nil {
+			respondWithSlugError(c, err)
+			return
+		}
+		clientApp.Slug = &req.Slug
+	}
 
 	// Save the updated client app to the database
 	if err := config.DB.Save(&clientApp).Error; err != nil {
+		// Outro aplicativo pode ter ficado com o slug desde a verificação
+		if uniqueViolation(err) == clientAppSlugIndex {
+			respondWithSlugError(c, errSlugTaken)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update client app"})
 		return
 	}
@@ -170,5 +204,30 @@ func UpdateClientApp(c *gin.Context) {
 		Status:       clientApp.Status,
 		CreatedAt:    clientApp.CreatedAt,
 		UpdatedAt:    clientApp.UpdatedAt,
+		Slug:         clientApp.Slug,
 	})
 }
+
+// POST /v1/admin/clientapps/:id/reveal-key
+// IssueRevealKey issues the key a client app sends as X-Reveal-Key to read the sensitive data
+// of its QR codes, replacing any previous key. The key is returned once and not stored.
+func IssueRevealKey(c *gin.Context) {
+	var clientApp models.ClientApp
+	if err := config.DB.First(&clientApp, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "client app not found"})
+		return
+	}
+
+	key, keyHash, err := utils.NewRevealKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue reveal key"})
+		return
+	}
+	if err := config.DB.Model(&clientApp).Update("reveal_key_hash", keyHash).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue reveal key"})
+		return
+	}
+	log.Printf("Issued a reveal key for client app %s", clientApp.ID)
+
+	c.JSON(http.StatusCreated, gin.H{"clientAppId": clientApp.ID, "revealKey": key})
+}
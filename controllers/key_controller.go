@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mca93/qrcode_service/config"
+	"github.com/mca93/qrcode_service/keystore"
+	"github.com/mca93/qrcode_service/models"
+)
+
+// ListKeys lists the versions of the managed keys, optionally of one ?purpose. Key material
+// is never returned.
+func ListKeys(c *gin.Context) {
+	purpose := models.KeyPurpose(c.Query("purpose"))
+	if purpose != "" && !purpose.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purpose. Valid options are signing, encryption, webhook, url-signing, unlock"})
+		return
+	}
+
+	keys := config.Keys.Keys(purpose)
+	resp := make([]models.ManagedKey, 0, len(keys))
+	for _, key := range keys {
+		resp = append(resp, managedKeyResponse(key))
+	}
+	c.JSON(http.StatusOK, gin.H{"data": resp})
+}
+
+// RotateKey creates a new version of the :purpose key. The previous version is retired but
+// keeps verifying and decrypting.
+func RotateKey(c *gin.Context) {
+	purpose := models.KeyPurpose(c.Param("purpose"))
+	if !purpose.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purpose. Valid options are signing, encryption, webhook, url-signing, unlock"})
+		return
+	}
+
+	key, err := config.Keys.Rotate(purpose)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate key"})
+		return
+	}
+	log.Printf("Rotated %s key to %s", purpose, key.ID)
+	c.JSON(http.StatusCreated, managedKeyResponse(key))
+}
+
+func managedKeyResponse(key *keystore.Key) models.ManagedKey {
+	return models.ManagedKey{
+		ID:        key.ID,
+		Purpose:   key.Purpose,
+		Version:   key.Version,
+		Algorithm: key.Algorithm,
+		Status:    key.Status,
+		CreatedAt: key.CreatedAt,
+	}
+}
+
+// StartKeyMaintenance reloads the key store every KEY_REFRESH_INTERVAL (default 5m), picking up
+// rotations made by other instances, and rotates keys older than KEY_ROTATION_INTERVAL when it
+// is set.
+func StartKeyMaintenance() {
+	refresh := getDurationEnv("KEY_REFRESH_INTERVAL", 5*time.Minute)
+	maxAge := getDurationEnv("KEY_ROTATION_INTERVAL", 0)
+	if refresh <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(refresh)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := config.Keys.Reload(); err != nil {
+				log.Printf("Failed to reload keys: %v", err)
+				continue
+			}
+			if maxAge <= 0 {
+				continue
+			}
+			rotated, err := config.Keys.RotateOlderThan(maxAge)
+			if err != nil {
+				log.Printf("Failed to rotate keys: %v", err)
+			}
+			for _, key := range rotated {
+				log.Printf("Rotated %s key to %s", key.Purpose, key.ID)
+			}
+		}
+	}()
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mca93/qrcode_service/config"
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/storage"
+	"github.com/mca93/qrcode_service/utils"
+	"github.com/mca93/qrcode_service/validators"
+)
+
+// mediaURLExpiry is how long the signed URLs public media is redirected to stay valid.
+const mediaURLExpiry = 5 * time.Minute
+
+// UploadQRCodeMedia stores the file uploaded as "file" for a Media field of a QR code and
+// writes a reference to it into the QR code's data, replacing the previous one.
+func UploadQRCodeMedia(c *gin.Context) {
+	clientAppID, err := getValidClientAppID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var qr models.QRCode
+	if err := findQRCode(config.DB.Preload("Template"), c.Param("id"), &qr); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "QR Code not found"})
+		return
+	}
+
+	// Validate ownership
+	if err := validators.ValidateQRCodeOwnership(clientAppID, qr); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	field, ok := mediaField(qr.Template, c.Param("field"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template has no Media field named " + c.Param("field")})
+		return
+	}
+	if !field.IsVisible(qr.Data) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "field is not applicable for the provided data: " + field.Name})
+		return
+	}
+
+	// The template's maxSize is checked before reading more than it allows
+	maxBytes := utils.MaxMediaBytes
+	if maxSize, ok := field.Validations["maxSize"].(float64); ok && maxSize < float64(maxBytes) {
+		maxBytes = int(maxSize)
+	}
+	data, err := readFormFile(c, "file", maxBytes)
+	if err != nil {
+		respondWithUploadError(c, err, "media")
+		return
+	}
+	if data == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+
+	clean, contentType, ext, err := utils.SanitizeMedia(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid media file: %v", err)})
+		return
+	}
+	if err := validators.ValidateMediaUpload(field, contentType, int64(len(clean))); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, err := utils.SaveUpload(c.Request.Context(), config.Storage, models.MediaAssetPrefix(qr.ID)+field.Name, clean, ext)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process media upload"})
+		return
+	}
+
+	// Replaced media is left to the upload collector
+	ref := models.MediaReference{Asset: key, ContentType: contentType, Size: int64(len(clean)), URL: qr.MediaURL(field.Name)}
+	if qr.Data == nil {
+		qr.Data = models.JSONMap{}
+	}
+	qr.Data[field.Name] = ref.Map()
+	if err := config.DB.Model(&qr).Update("data", qr.Data).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update QR code"})
+		return
+	}
+
+	qr.Data = utils.MaskSensitiveData(qr.Data)
+	c.JSON(http.StatusOK, qr)
+}
+
+// ServeQRCodeMedia is the public address of the media in a QR code's data, used by its landing
+// page. It redirects to a short-lived signed URL of the stored asset.
+func ServeQRCodeMedia(c *gin.Context) {
+	var qr models.QRCode
+	if err := findQRCode(config.DB.Preload("Template"), c.Param("id"), &qr); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "QR Code not found"})
+		return
+	}
+	// Media of protected codes is as confidential as their landing page
+	if !requireUnlocked(c, &qr) {
+		return
+	}
+
+	field, ok := mediaField(qr.Template, c.Param("field"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+		return
+	}
+	ref, err := models.ParseMediaReference(qr.Data[field.Name])
+	if err != nil || !ref.BelongsTo(qr.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+		return
+	}
+
+	url, err := config.Storage.SignedURL(c.Request.Context(), ref.Asset, mediaURLExpiry)
+	if errors.Is(err, storage.ErrInvalidKey) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not sign media URL"})
+		return
+	}
+	c.Header("Cache-Control", "no-store") // The signed URL expires
+	c.Redirect(http.StatusFound, url)
+}
+
+// resolveMediaReferences rewrites the media references in data from the stored assets they
+// refer to, so the content type and size checked against the template are those of the upload
+// rather than what the client sent. The references must belong to the QR code.
+func resolveMediaReferences(ctx context.Context, qr *models.QRCode, data models.JSONMap, template models.Template) error {
+	for _, field := range template.Definition {
+		value, exists := data[field.Name]
+		if field.Type != models.FieldTypeMedia || !exists || value == nil {
+			continue
+		}
+		ref, err := models.ParseMediaReference(value)
+		if err != nil {
+			return err
+		}
+		object, err := config.Storage.Stat(ctx, ref.Asset)
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			return fmt.Errorf("media of field '%s': %w", field.Name, storage.ErrNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		ref.ContentType, ref.Size = utils.StoredMediaType(object.Key), object.Size
+		ref.URL = qr.MediaURL(field.Name)
+		data[field.Name] = ref.Map()
+	}
+	return nil
+}
+
+// mediaField returns the Media field of the template called name.
+func mediaField(template models.Template, name string) (models.Field, bool) {
+	for _, field := range template.Definition {
+		if field.Name == name && field.Type == models.FieldTypeMedia {
+			return field, true
+		}
+	}
+	return models.Field{}, false
+}
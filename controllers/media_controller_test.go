@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mca93/qrcode_service/config"
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveMediaReferences(t *testing.T) {
+	ctx := context.Background()
+	config.Storage = storage.NewLocal(t.TempDir(), "", nil)
+	pdf := []byte("%PDF-1.4\n%%EOF\n")
+	assert.NoError(t, config.Storage.Put(ctx, "media/qr-1/menu/abc.pdf", pdf, "application/pdf"))
+
+	qr := &models.QRCode{ID: "qr-1"}
+	template := models.Template{Definition: []models.Field{{Name: "menu", Type: models.FieldTypeMedia}}}
+
+	// The type and size sent by the client are replaced by those of the stored media
+	data := models.JSONMap{"menu": map[string]interface{}{
+		"asset": "media/qr-1/menu/abc.pdf", "contentType": "image/png", "size": 1, "url": "https://evil.com",
+	}}
+	assert.NoError(t, resolveMediaReferences(ctx, qr, data, template))
+	assert.Equal(t, map[string]interface{}{
+		"asset": "media/qr-1/menu/abc.pdf", "contentType": "application/pdf", "size": int64(len(pdf)), "url": qr.MediaURL("menu"),
+	}, data["menu"])
+
+	data = models.JSONMap{"menu": map[string]interface{}{"asset": "media/qr-1/menu/missing.pdf"}}
+	assert.ErrorIs(t, resolveMediaReferences(ctx, qr, data, template), storage.ErrNotFound)
+}
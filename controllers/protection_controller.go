@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mca93/qrcode_service/config"
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	unlockCookieMaxAge = 15 * time.Minute // How long an unlocked code stays open for the scanner
+	unlockWindow       = 15 * time.Minute // Failed attempts are counted over this window
+	maxCodeFailures    = 10               // Failed attempts on a code, from anyone, before wrong guesses on it are throttled
+	maxClientFailures  = 20               // Failed attempts from an IP, on any code, before it is throttled
+)
+
+// UnlockQRCode checks the PIN or password entered on the prompt of a protected QR code. On
+// success it sets a short-lived signed cookie and sends the scanner back to the resolver.
+// Failed attempts are throttled per IP. A code many wrong guesses were made on still lets the
+// right secret through, so guessing cannot lock its holders out.
+func UnlockQRCode(c *gin.Context) {
+	var qr models.QRCode
+	if err := findQRCode(config.DB, c.Param("id"), &qr); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "QR Code not found"})
+		return
+	}
+
+	var req models.UnlockRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	next := unlockNext(req.Next, qr.ID)
+	if qr.Protection == "" {
+		c.Redirect(http.StatusSeeOther, next)
+		return
+	}
+
+	now := time.Now()
+	client := utils.UnlockClientHash(c.ClientIP())
+	// The attempt is counted before the secret is checked, so parallel guesses cannot all
+	// slip under the limit
+	codeAllowed, clientAllowed, err := reserveUnlockAttempt(qr.ID, client, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check PIN"})
+		return
+	}
+	if !clientAllowed {
+		respondWithUnlockThrottled(c, &qr, next)
+		return
+	}
+
+	if !utils.CheckProtectionSecret(req.Secret, qr.ProtectionHash) {
+		if !codeAllowed {
+			respondWithUnlockThrottled(c, &qr, next)
+			return
+		}
+		message := "Wrong password"
+		if qr.Protection == models.ProtectionTypePIN {
+			message = "Wrong PIN"
+		}
+		respondWithUnlockPrompt(c, &qr, http.StatusUnauthorized, next, message)
+		return
+	}
+
+	// Only failed attempts count against the limits
+	if err := releaseUnlockAttempt(qr.ID, client); err != nil {
+		log.Printf("Failed to release unlock attempt on QR code %s: %v", qr.ID, err)
+	}
+
+	expires := now.Add(unlockCookieMaxAge)
+	token, err := utils.NewUnlockToken(config.Keys.HMACKeys(models.KeyPurposeUnlock), &qr, expires)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock QR code"})
+		return
+	}
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(utils.UnlockCookieName(qr.ID), token, int(unlockCookieMaxAge.Seconds()), "/", "", secure, true)
+
+	if c.ContentType() == gin.MIMEJSON {
+		c.JSON(http.StatusOK, gin.H{"id": qr.ID, "unlocked": true, "expiresAt": expires})
+		return
+	}
+	c.Redirect(http.StatusSeeOther, next)
+}
+
+// requireUnlocked reports whether the scanner may open the QR code, which is the case for
+// codes without protection and for scanners with a valid unlock cookie. Otherwise it responds
+// with the PIN or password prompt.
+func requireUnlocked(c *gin.Context, qr *models.QRCode) bool {
+	if qr.Protection == "" {
+		return true
+	}
+	token, err := c.Cookie(utils.UnlockCookieName(qr.ID))
+	if err == nil && utils.CheckUnlockToken(config.Keys.HMACKeys(models.KeyPurposeUnlock), qr, token, time.Now()) {
+		return true
+	}
+	respondWithUnlockPrompt(c, qr, http.StatusUnauthorized, c.Request.URL.RequestURI(), "")
+	return false
+}
+
+// respondWithUnlockPrompt serves the PIN or password prompt to browsers, and an error to
+// clients asking for JSON.
+func respondWithUnlockPrompt(c *gin.Context, qr *models.QRCode, status int, next, message string) {
+	c.Header("Cache-Control", "no-store")
+	if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON || c.Query("format") == "json" {
+		if message == "" {
+			message = "QR Code is protected"
+		}
+		c.JSON(status, gin.H{"error": message, "code": "PROTECTED", "id": qr.ID, "protection": qr.Protection})
+		return
+	}
+
+	page, err := utils.RenderUnlockPage(models.UnlockPageView{
+		QRCodeID: qr.ID,
+		Type:     qr.Protection,
+		Action:   "/qrcodes/" + qr.ID + "/unlock",
+		Next:     next,
+		Error:    message,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render PIN prompt"})
+		return
+	}
+	c.Data(status, "text/html; charset=utf-8", page)
+}
+
+// respondWithUnlockThrottled serves the prompt again, saying to retry once the window is over.
+func respondWithUnlockThrottled(c *gin.Context, qr *models.QRCode, next string) {
+	c.Header("Retry-After", strconv.Itoa(int(unlockWindow.Seconds())))
+	respondWithUnlockPrompt(c, qr, http.StatusTooManyRequests, next, "Too many attempts, try again later")
+}
+
+// unlockNext returns the resolver path to go back to once unlocked. Only paths on this service
+// are followed, so the prompt cannot be used to redirect elsewhere.
+func unlockNext(next, qrCodeID string) string {
+	fallback := "/qrcodes/" + qrCodeID
+	// Browsers drop tabs and newlines and read backslashes as slashes, turning /\t/host into //host
+	for _, r := range next {
+		if unicode.IsControl(r) || r == '\\' {
+			return fallback
+		}
+	}
+	u, err := url.Parse(next)
+	if err != nil || u.Scheme != "" || u.Host != "" || !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") {
+		return fallback
+	}
+	return next
+}
+
+// unlockCounterIDs returns the IDs of the attempt counters of a QR code and of a client.
+func unlockCounterIDs(qrCodeID, client string) []string {
+	return []string{"qrcode:" + qrCodeID, "client:" + client}
+}
+
+// reserveUnlockAttempt counts an attempt on the QR code by the client and reports whether it
+// is within the limits of the code and of the client. Attempts beyond the client's limit are
+// not counted. The counters are locked while they are checked and incremented, so concurrent
+// attempts are counted one by one.
+func reserveUnlockAttempt(qrCodeID, client string, now time.Time) (bool, bool, error) {
+	since := now.Add(-unlockWindow)
+	// Counters whose window is over no longer count
+	if err := config.DB.Where("window_start <= ?", since).Delete(&models.UnlockCounter{}).Error; err != nil {
+		return false, false, err
+	}
+
+	allowed := make([]bool, 2)
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		ids := unlockCounterIDs(qrCodeID, client)
+		limits := []int{maxCodeFailures, maxClientFailures}
+		counters := make([]models.UnlockCounter, len(ids))
+		for i, id := range ids {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.UnlockCounter{ID: id, WindowStart: now}).Error; err != nil {
+				return err
+			}
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&counters[i], "id = ?", id).Error; err != nil {
+				return err
+			}
+			if !counters[i].WindowStart.After(since) {
+				counters[i].Attempts, counters[i].WindowStart = 0, now
+			}
+			allowed[i] = counters[i].Attempts < limits[i]
+		}
+		if !allowed[1] {
+			return nil
+		}
+		for i := range counters {
+			counters[i].Attempts++
+			if err := tx.Save(&counters[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, false, err
+	}
+	return allowed[0], allowed[1], nil
+}
+
+// releaseUnlockAttempt uncounts an attempt that turned out to be right.
+func releaseUnlockAttempt(qrCodeID, client string) error {
+	return config.DB.Model(&models.UnlockCounter{}).
+		Where("id IN ? AND attempts > 0", unlockCounterIDs(qrCodeID, client)).
+		Update("attempts", gorm.Expr("attempts - 1")).Error
+}
@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnlockNext(t *testing.T) {
+	for _, next := range []string{"/qrcodes/qr-1", "/q/aB3dE5fG?format=json", "/01/09506000134352/10/A1"} {
+		assert.Equal(t, next, unlockNext(next, "qr-1"))
+	}
+	for _, next := range []string{"", "qrcodes/qr-1", "https://evil.com", "//evil.com", "/\\evil.com", "/\t/evil.com", "/\n/evil.com", "/%zz"} {
+		assert.Equal(t, "/qrcodes/qr-1", unlockNext(next, "qr-1"), next)
+	}
+}
@@ -1,16 +1,27 @@
 package controllers
 
 import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/mca93/qrcode_service/config"
 	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/storage"
 	"github.com/mca93/qrcode_service/utils"
 	"github.com/mca93/qrcode_service/validators"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ListQRCodes retrieves all QR codes for the authenticated client app.
@@ -37,6 +48,9 @@ func ListQRCodes(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve QR codes"})
 		return
 	}
+	for i := range codes {
+		codes[i].Data = utils.MaskSensitiveData(codes[i].Data)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"qr_codes": codes})
 }
@@ -63,37 +77,179 @@ func CreateQRCode(c *gin.Context) {
 		return
 	}
 
+	// Fill in template defaults for absent keys before validating
+	req.Data = template.Definition.ApplyDefaults(req.Data)
+
 	// Validate the Data field
 	if err := validators.ValidateQRCodeData(req.Data, template); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Validate the typed content payload
+	if err := validators.ValidateQRContent(req.ContentType, req.Content); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validate the routing rules
+	if err := validators.ValidateRouting(req.Routing, req.Type); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validate the activation window and scan limit
+	if err := validators.ValidateQRCodeValidity(req.ActivatesAt, req.ExpiresAt, req.MaxScans); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validate the PIN or password
+	if err := validators.ValidateQRCodeProtection(req.Protection, req.Type); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Create the QR code
 	qrCode := models.QRCode{
 		ID:            uuid.NewString(),
 		Type:          req.Type,
 		CreatedAt:     time.Now(),
+		ActivatesAt:   req.ActivatesAt,
 		ExpiresAt:     req.ExpiresAt,
-		Status:        "ACTIVE",
+		Status:        models.QRCodeStatusActive,
 		ScanCount:     0,
+		MaxScans:      req.MaxScans,
+		OneTimeUse:    req.OneTimeUse,
 		ImageURL:      "", // This should be generated later
 		DeepLinkURL:   "", // This should be generated later
 		ClientAppID:   req.ClientAppID,
 		TemplateID:    req.TemplateID,
 		ThirdPartyRef: req.ThirdPartyRef,
 		Data:          req.Data,
+		ContentType:   req.ContentType,
+		Content:       req.Content,
+		DigitalLink:   digitalLinkPath(req.ContentType, req.Content),
+		Routing:       routingOrNil(req.Routing),
+	}
+
+	// Media is uploaded for the QR code once it exists
+	if err := validators.ValidateMediaOwnership(qrCode.Data, template, qrCode.ID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A GS1 Digital Link must resolve to a single QR code
+	if err := ensureDigitalLinkAvailable(qrCode.DigitalLink, qrCode.ID); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Codes resolve under a short ID, assigned on creation, and under a custom slug when one is asked for
+	if req.Slug != "" {
+		deepLink, ok := slugDeepLinkURL(c, req.Slug, clientAppID)
+		if !ok {
+			return
+		}
+		if err := ensureQRCodeSlugAvailable(req.Slug, clientAppID); err != nil {
+			respondWithSlugError(c, err)
+			return
+		}
+		qrCode.Slug = &req.Slug
+		qrCode.DeepLinkURL = deepLink
+	}
+
+	if err := setProtection(&qrCode, req.Protection); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash PIN or password"})
+		return
+	}
+
+	// Sensitive fields are stored encrypted
+	if qrCode.Data, err = utils.EncryptSensitiveData(qrCode.Data, template.Definition, qrCode.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt sensitive data"})
+		return
 	}
 
 	// Save the QR code to the database
-	if err := config.DB.Create(&qrCode).Error; err != nil {
+	if err := createQRCode(&qrCode); err != nil {
+		switch uniqueViolation(err) {
+		case digitalLinkIndex:
+			c.JSON(http.StatusConflict, gin.H{"error": errDigitalLinkTaken.Error()})
+			return
+		case qrCodeSlugIndex:
+			respondWithSlugError(c, errSlugTaken)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create QR code"})
 		return
 	}
 
+	qrCode.Data = utils.MaskSensitiveData(qrCode.Data)
 	c.JSON(http.StatusOK, qrCode)
 }
 
+// PreviewQRCodeContent reports the content, encoded length and QR version a QR code
+// would get, without creating it.
+func PreviewQRCodeContent(c *gin.Context) {
+	clientAppID, err := getValidClientAppID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req models.QRCodeCreateRequest
+	req.ClientAppID = clientAppID
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var template models.Template
+	if err := config.DB.First(&template, "id = ?", req.TemplateID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	req.Data = template.Definition.ApplyDefaults(req.Data)
+	if err := validators.ValidateQRCodeData(req.Data, template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validators.ValidateQRContent(req.ContentType, req.Content); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Build an unsaved QR code so the deep link has its final length
+	qrCode := models.QRCode{
+		ID:          uuid.NewString(),
+		Type:        req.Type,
+		ClientAppID: req.ClientAppID,
+		TemplateID:  req.TemplateID,
+		Data:        req.Data,
+		ContentType: req.ContentType,
+		Content:     req.Content,
+	}
+	placeholder := strings.Repeat("0", utils.ShortIDLength)
+	qrCode.ShortID = &placeholder
+	qrCode.DeepLinkURL = qrCode.BuildDeepLinkURL()
+	if req.Slug != "" {
+		deepLink, ok := slugDeepLinkURL(c, req.Slug, clientAppID)
+		if !ok {
+			return
+		}
+		qrCode.DeepLinkURL = deepLink
+	}
+
+	preview, err := utils.NewQRCodeService(&qrCode, &template).EncodedContentInfo()
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Could not encode QR code content: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
 // GetQRCode retrieves a specific QR code by its ID.
 func GetQRCode(c *gin.Context) {
 	clientAppID, err := getValidClientAppID(c)
@@ -104,7 +260,7 @@ func GetQRCode(c *gin.Context) {
 
 	id := c.Param("id")
 	var qrCode models.QRCode
-	if err := config.DB.First(&qrCode, "id = ?", id).Error; err != nil {
+	if err := findQRCode(config.DB, id, &qrCode); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "QR Code not found"})
 		return
 	}
@@ -115,6 +271,24 @@ func GetQRCode(c *gin.Context) {
 		return
 	}
 
+	// Sensitive values are decrypted only for callers with the client app's reveal key
+	revealKey := c.GetHeader("X-Reveal-Key")
+	if revealKey == "" {
+		qrCode.Data = utils.MaskSensitiveData(qrCode.Data)
+		c.JSON(http.StatusOK, qrCode)
+		return
+	}
+	var clientApp models.ClientApp
+	if err := config.DB.First(&clientApp, "id = ?", clientAppID).Error; err != nil || !utils.CheckRevealKey(revealKey, clientApp.RevealKeyHash) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid reveal key"})
+		return
+	}
+	if qrCode.Data, err = utils.DecryptSensitiveData(qrCode.Data, qrCode.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt sensitive data"})
+		return
+	}
+	log.Printf("Client app %s revealed the sensitive data of QR code %s", clientAppID, qrCode.ID)
+
 	c.JSON(http.StatusOK, qrCode)
 }
 
@@ -128,7 +302,7 @@ func UpdateQRCode(c *gin.Context) {
 
 	id := c.Param("id")
 	var qrCode models.QRCode
-	if err := config.DB.First(&qrCode, "id = ?", id).Error; err != nil {
+	if err := findQRCode(config.DB, id, &qrCode); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "QR Code not found"})
 		return
 	}
@@ -155,17 +329,109 @@ func UpdateQRCode(c *gin.Context) {
 	if req.Type != "" {
 		qrCode.Type = req.Type
 	}
+	if req.ActivatesAt != nil {
+		qrCode.ActivatesAt = req.ActivatesAt
+	}
 	if req.ExpiresAt != nil {
 		qrCode.ExpiresAt = req.ExpiresAt
 	}
+	if req.MaxScans != nil {
+		qrCode.MaxScans = *req.MaxScans
+	}
+	if req.OneTimeUse != nil {
+		qrCode.OneTimeUse = *req.OneTimeUse
+	}
 	if req.Status != "" {
 		qrCode.Status = req.Status
 	}
+	if err := validators.ValidateQRCodeValidity(qrCode.ActivatesAt, qrCode.ExpiresAt, qrCode.MaxScans); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if req.Data != nil {
-		qrCode.Data = req.Data
+		// New data must still fit the template, and keep media uploaded for this QR code
+		var template models.Template
+		if err := config.DB.First(&template, "id = ?", qrCode.TemplateID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+			return
+		}
+		// Masked values sent back keep the stored value
+		data, err := utils.UnmaskSensitiveData(req.Data, qrCode.Data, qrCode.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt sensitive data"})
+			return
+		}
+		if err := validators.ValidateMediaOwnership(data, template, qrCode.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// The type and size of media are checked as stored, not as sent
+		if err := resolveMediaReferences(c.Request.Context(), &qrCode, data, template); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check media"})
+			return
+		}
+		if err := validators.ValidateQRCodeData(data, template); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if qrCode.Data, err = utils.EncryptSensitiveData(data, template.Definition, qrCode.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt sensitive data"})
+			return
+		}
+	}
+	if req.Routing != nil {
+		qrCode.Routing = routingOrNil(req.Routing)
+	}
+	if err := validators.ValidateQRCodeProtection(req.Protection, qrCode.Type); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Protection != nil {
+		if err := setProtection(&qrCode, req.Protection); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash PIN or password"})
+			return
+		}
+	}
+	// Only DYNAMIC codes ask for their PIN or password
+	if qrCode.Protection != "" && qrCode.Type != models.QRCodeTypeDynamic {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "protection requires a DYNAMIC QR code"})
+		return
+	}
+	// Routing stays valid for the type the QR code ends up with
+	if err := validators.ValidateRouting(qrCode.Routing, qrCode.Type); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ContentType != "" {
+		qrCode.ContentType = req.ContentType
+		qrCode.Content = req.Content
+		qrCode.DigitalLink = digitalLinkPath(req.ContentType, req.Content)
+		if err := ensureDigitalLinkAvailable(qrCode.DigitalLink, qrCode.ID); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 	}
 
-	config.DB.Save(&qrCode)
+	// Only the editable columns are written, so scans counted meanwhile are kept and a code
+	// redeemed meanwhile stays redeemed unless the status was asked for
+	columns := []string{"type", "activates_at", "expires_at", "max_scans", "one_time_use", "data",
+		"routing", "protection", "protection_hash", "content_type", "content", "digital_link"}
+	if req.Status != "" {
+		columns = append(columns, "status")
+	}
+	if err := config.DB.Model(&qrCode).Select(columns).Updates(&qrCode).Error; err != nil {
+		if uniqueViolation(err) == digitalLinkIndex {
+			c.JSON(http.StatusConflict, gin.H{"error": errDigitalLinkTaken.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update QR code"})
+		return
+	}
+	qrCode.Data = utils.MaskSensitiveData(qrCode.Data)
 	c.JSON(http.StatusOK, qrCode)
 }
 
@@ -179,7 +445,7 @@ func DeleteQRCode(c *gin.Context) {
 
 	id := c.Param("id")
 	var qrCode models.QRCode
-	if err := config.DB.First(&qrCode, "id = ?", id).Error; err != nil {
+	if err := findQRCode(config.DB, id, &qrCode); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "QR Code not found"})
 		return
 	}
@@ -191,6 +457,8 @@ func DeleteQRCode(c *gin.Context) {
 	}
 
 	config.DB.Delete(&qrCode)
+	config.DB.Where("qr_code_id = ?", qrCode.ID).Delete(&models.ScanEvent{})
+	config.DB.Delete(&models.UnlockCounter{}, "id = ?", "qrcode:"+qrCode.ID)
 	c.JSON(http.StatusOK, gin.H{"message": "QR Code deleted successfully"})
 }
 
@@ -199,21 +467,195 @@ func GetQRCodeImage(c *gin.Context) {
 	id := c.Param("id")
 	var qr models.QRCode
 
-	if err := config.DB.Preload("Template").First(&qr, "id = ?", id).Error; err != nil {
+	if err := findQRCode(config.DB.Preload("Template"), id, &qr); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "QR Code not found"})
 		return
 	}
 
-	//png, err := qrcode.Encode(qr.DeepLinkURL, qrcode.Medium, 256)
+	// png (default) or svg
+	format := utils.ImageFormat(c.DefaultQuery("format", string(utils.ImageFormatPNG)))
+	if !format.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, must be png or svg"})
+		return
+	}
+
+	// verify=true decodes the rendered image and refuses codes that do not read back
+	verify, err := strconv.ParseBool(c.DefaultQuery("verify", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verify, must be true or false"})
+		return
+	}
 
 	imgQR := utils.NewQRCodeService(&qr, &qr.Template)
-	png, err := imgQR.GenerateBase64Image()
+	img, err := imgQR.GenerateImage(format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate QR code:" + err.Error()})
+		return
+	}
+
+	if verify {
+		if format == utils.ImageFormatPNG {
+			_, err = imgQR.VerifyImage(img)
+		} else {
+			_, err = imgQR.Verify()
+		}
+		if errors.Is(err, utils.ErrVerificationFailed) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "QR code could not be verified: " + err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify QR code: " + err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, base64.StdEncoding.EncodeToString(img))
+}
+
+const (
+	exportPrefix    = "exports"        // Storage prefix of images exported by DownloadQRCode
+	exportURLExpiry = 15 * time.Minute // How long download URLs of exported images stay valid
+)
+
+// DownloadQRCode renders the QR code image, stores it and returns a signed, time-limited URL
+// to download it from.
+func DownloadQRCode(c *gin.Context) {
+	clientAppID, err := getValidClientAppID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := c.Param("id")
+	var qr models.QRCode
+	if err := findQRCode(config.DB.Preload("Template"), id, &qr); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "QR Code not found"})
+		return
+	}
+
+	// Validate ownership
+	if err := validators.ValidateQRCodeOwnership(clientAppID, qr); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	// png (default) or svg
+	format := utils.ImageFormat(c.DefaultQuery("format", string(utils.ImageFormatPNG)))
+	if !format.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, must be png or svg"})
+		return
+	}
+
+	img, err := utils.NewQRCodeService(&qr, &qr.Template).GenerateImage(format)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate QR code:" + err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, png)
+	ctx := c.Request.Context()
+	key, err := utils.SaveUpload(ctx, config.Storage, exportPrefix+"/"+qr.ID, img, "."+string(format))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not store QR code image"})
+		return
+	}
+	url, err := config.Storage.SignedURL(ctx, key, exportURLExpiry)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not sign download URL"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": url, "expiresAt": time.Now().Add(exportURLExpiry)})
+}
+
+// maxDecodeUploadSize is the largest image accepted by DecodeQRCodes.
+const maxDecodeUploadSize = 10 << 20
+
+// DecodeQRCodes reads the QR codes in an uploaded PNG or JPEG image, such as a customer's
+// photo of a damaged label, and returns the codes issued by the caller's client app.
+func DecodeQRCodes(c *gin.Context) {
+	clientAppID, err := getValidClientAppID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image file is required"})
+		return
+	}
+	if file.Size > maxDecodeUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image must not exceed 10 MB"})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read image"})
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxDecodeUploadSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read image"})
+		return
+	}
+
+	codes, err := utils.DecodeQRImageData(data)
+	if errors.Is(err, utils.ErrUnsupportedImage) || errors.Is(err, utils.ErrImageTooLarge) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "No readable QR code found: " + err.Error()})
+		return
+	}
+
+	results := make([]models.QRCodeDecodeResult, len(codes))
+	for i, code := range codes {
+		results[i] = models.QRCodeDecodeResult{
+			Content:         code.Content,
+			Version:         code.Version,
+			ErrorCorrection: code.ErrorCorrection,
+			ErrorsCorrected: code.ErrorsCorrected,
+		}
+		// Codes of other client apps are reported without their record
+		if qr := findIssuedQRCode(code.Content); qr != nil && validators.ValidateQRCodeOwnership(clientAppID, *qr) == nil {
+			qr.Data = utils.MaskSensitiveData(qr.Data)
+			results[i].QRCode = qr
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"codes": results})
+}
+
+// findIssuedQRCode returns the QR code whose deep link, or GS1 Digital Link, is content, or
+// whose ID is signed into content's token.
+func findIssuedQRCode(content string) *models.QRCode {
+	var qr models.QRCode
+	if id, ok := utils.SignedPayloadSubject(config.SigningKeysFor(utils.SignedPayloadToken(content)), content); ok {
+		if err := config.DB.First(&qr, "id = ?", id).Error; err == nil {
+			return &qr
+		}
+		return nil
+	}
+
+	content = utils.StripSignedPayload(content)
+	if err := config.DB.First(&qr, "deep_link_url = ?", content).Error; err == nil {
+		return &qr
+	}
+
+	u, err := url.Parse(content)
+	if err != nil {
+		return nil
+	}
+	path, err := utils.ParseGS1DigitalLinkPath(u.EscapedPath())
+	if err != nil {
+		return nil
+	}
+	if err := config.DB.First(&qr, "digital_link = ?", path).Error; err == nil {
+		return &qr
+	}
+	return nil
 }
 
 // ScanQRCode increments the scan count for a QR code.
@@ -221,12 +663,217 @@ func ScanQRCode(c *gin.Context) {
 	id := c.Param("id")
 	var qr models.QRCode
 
-	if err := config.DB.First(&qr, "id = ?", id).Error; err != nil {
+	if err := findQRCode(config.DB.Preload("Template"), id, &qr); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "QR Code not found"})
 		return
 	}
 
-	qr.ScanCount++
-	config.DB.Save(&qr)
-	c.JSON(http.StatusOK, gin.H{"message": "Scan count updated", "scan_count": qr.ScanCount})
+	recordScan(c, &qr)
+}
+
+// ResolveDigitalLink maps an incoming GS1 Digital Link path back to the stored QR code.
+// The path is canonicalised first, then less specific forms are tried so a serialised
+// item falls back to the code registered for its batch or GTIN.
+func ResolveDigitalLink(c *gin.Context) {
+	canonical, err := utils.ParseGS1DigitalLinkPath(c.Request.URL.EscapedPath())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, path := range utils.GS1DigitalLinkFallbacks(canonical) {
+		var qr models.QRCode
+		if err := config.DB.Preload("Template").First(&qr, "digital_link = ?", path).Error; err == nil {
+			recordScan(c, &qr)
+			return
+		}
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{"error": "QR Code not found"})
+}
+
+// recordScan increments the scan count of a resolved QR code and reports it, or reports why
+// the code does not resolve. DYNAMIC codes
+// redirect to the destination their routing rules pick for the scan. Failing that, those whose
+// template has a landing page open on it, as HTML for browsers or as JSON for clients asking
+// for it with ?format=json or an Accept header.
+func recordScan(c *gin.Context, qr *models.QRCode) {
+	// Protected codes ask for their PIN or password once they would resolve, and count the
+	// scan when opened
+	if qr.Protection != "" {
+		if err := qr.CheckResolvable(time.Now()); err != nil {
+			respondWithUnresolvable(c, qr, err)
+			return
+		}
+		if !requireUnlocked(c, qr) {
+			return
+		}
+	}
+
+	if err := countScan(qr); err != nil {
+		respondWithUnresolvable(c, qr, err)
+		return
+	}
+
+	if qr.Type == models.QRCodeTypeDynamic && qr.Routing != nil {
+		if destination := routeScan(c, qr); destination != "" {
+			// The destination depends on who scans and when
+			c.Header("Cache-Control", "no-store")
+			c.Redirect(http.StatusFound, destination)
+			return
+		}
+	}
+
+	if qr.Type != models.QRCodeTypeDynamic || qr.Template.LandingPage == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Scan count updated", "id": qr.ID, "scan_count": qr.ScanCount})
+		return
+	}
+
+	view, err := utils.BuildLandingPage(qr, &qr.Template)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build landing page"})
+		return
+	}
+	format := c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON)
+	if c.Query("format") == "json" {
+		format = gin.MIMEJSON
+	}
+	if format == gin.MIMEJSON {
+		c.JSON(http.StatusOK, view)
+		return
+	}
+
+	page, err := utils.RenderLandingPage(view)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render landing page"})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+}
+
+// countScan counts a scan of a QR code that can be resolved, redeeming one-time-use codes. The
+// checks and the update are a single statement, so concurrent scans cannot go over the scan
+// limit or redeem a code twice. It returns why the code cannot be resolved otherwise.
+func countScan(qr *models.QRCode) error {
+	now := time.Now()
+	if err := qr.CheckResolvable(now); err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{"scan_count": gorm.Expr("scan_count + 1")}
+	if qr.OneTimeUse {
+		updates["status"] = models.QRCodeStatusRedeemed
+	}
+	// Only these columns are written, Save would also upsert the preloaded template
+	counted := models.QRCode{ID: qr.ID}
+	result := config.DB.Model(&counted).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "scan_count"}, {Name: "status"}}}).
+		Where("status = ?", models.QRCodeStatusActive).
+		Where("activates_at IS NULL OR activates_at <= ?", now).
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Where("max_scans = 0 OR scan_count < max_scans").
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Another scan got there first; report the state it left the code in
+		if err := config.DB.First(qr, "id = ?", qr.ID).Error; err != nil {
+			return err
+		}
+		if err := qr.CheckResolvable(now); err != nil {
+			return err
+		}
+		return models.ErrQRCodeInactive
+	}
+	qr.ScanCount, qr.Status = counted.ScanCount, counted.Status
+	return nil
+}
+
+// unresolvableCodes tell apart the reasons a QR code does not resolve.
+var unresolvableCodes = map[error]struct {
+	status int
+	code   string
+}{
+	models.ErrQRCodeNotYetActive: {http.StatusForbidden, "NOT_YET_ACTIVE"},
+	models.ErrQRCodeInactive:     {http.StatusForbidden, "INACTIVE"},
+	models.ErrQRCodeExpired:      {http.StatusGone, "EXPIRED"},
+	models.ErrQRCodeExhausted:    {http.StatusGone, "EXHAUSTED"},
+	models.ErrQRCodeRedeemed:     {http.StatusGone, "REDEEMED"},
+}
+
+// respondWithUnresolvable reports why a scanned QR code does not resolve.
+func respondWithUnresolvable(c *gin.Context, qr *models.QRCode, err error) {
+	reason, ok := unresolvableCodes[err]
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record scan"})
+		return
+	}
+	body := gin.H{"error": err.Error(), "code": reason.code, "id": qr.ID}
+	switch err {
+	case models.ErrQRCodeNotYetActive:
+		body["activatesAt"] = qr.ActivatesAt
+	case models.ErrQRCodeExpired:
+		body["expiresAt"] = qr.ExpiresAt
+	}
+	c.JSON(reason.status, body)
+}
+
+// setProtection stores the hash of the PIN or password of a QR code, or removes its protection
+// when none is given.
+func setProtection(qr *models.QRCode, protection *models.QRCodeProtection) error {
+	if protection == nil || protection.Type == "" {
+		qr.Protection, qr.ProtectionHash = "", ""
+		return nil
+	}
+	hash, err := utils.HashProtectionSecret(protection.Secret)
+	if err != nil {
+		return err
+	}
+	qr.Protection, qr.ProtectionHash = protection.Type, hash
+	return nil
+}
+
+// routingOrNil drops routing without rules, variants or default URL, so the QR code resolves as before.
+func routingOrNil(routing *models.Routing) *models.Routing {
+	if routing == nil || (len(routing.Rules) == 0 && len(routing.Variants) == 0 && routing.DefaultURL == "") {
+		return nil
+	}
+	return routing
+}
+
+// digitalLinkPath returns the canonical GS1 Digital Link path for GS1 content, or "".
+func digitalLinkPath(contentType models.QRContentType, content *models.QRContent) string {
+	if contentType != models.QRContentTypeGS1 || content == nil || content.GS1 == nil {
+		return ""
+	}
+	return utils.GS1DigitalLinkPath(content.GS1)
+}
+
+// digitalLinkIndex is the partial unique index that keeps Digital Link paths to one QR code.
+const digitalLinkIndex = "idx_qr_codes_digital_link_unique"
+
+var errDigitalLinkTaken = errors.New("a QR code is already registered for this GS1 Digital Link")
+
+// ensureDigitalLinkAvailable checks that no other QR code is registered under the same Digital Link path.
+// The unique index still rejects a path registered concurrently.
+func ensureDigitalLinkAvailable(path, id string) error {
+	if path == "" {
+		return nil
+	}
+	var count int64
+	config.DB.Model(&models.QRCode{}).Where("digital_link = ? AND id <> ?", path, id).Count(&count)
+	if count > 0 {
+		return errDigitalLinkTaken
+	}
+	return nil
+}
+
+// uniqueViolation returns the name of the unique index that err violates, or "" for other errors.
+func uniqueViolation(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return pgErr.ConstraintName
+	}
+	return ""
 }
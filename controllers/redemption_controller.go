@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mca93/qrcode_service/config"
+	"github.com/mca93/qrcode_service/middleware"
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/utils"
+	"github.com/mca93/qrcode_service/validators"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Redeem checks in a ticket or voucher scanned by a staff device. Codes are redeemed once; later
+// attempts are recorded and answered with the original redemption.
+func Redeem(c *gin.Context) {
+	device := c.MustGet(middleware.StaffDeviceKey).(*models.StaffDevice)
+
+	var req models.RedemptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validators.ValidateRedemptionRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, status, err := redeemCode(device, req, nil, time.Now(), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record redemption"})
+		return
+	}
+	c.JSON(status, result)
+}
+
+// SyncRedemptions records the redemptions a staff device made while offline. They are applied
+// in the order they were scanned, and when an offline redemption predates the one that
+// redeemed the code, the earliest wins. Uploading a batch again is safe.
+func SyncRedemptions(c *gin.Context) {
+	device := c.MustGet(middleware.StaffDeviceKey).(*models.StaffDevice)
+
+	var req models.RedemptionSyncRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order := make([]int, len(req.Redemptions))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return req.Redemptions[order[a]].RedeemedAt.Before(req.Redemptions[order[b]].RedeemedAt)
+	})
+
+	now := time.Now()
+	results := make([]models.RedemptionResult, len(req.Redemptions))
+	for _, i := range order {
+		offline := req.Redemptions[i]
+		clientRedemptionID := offline.ClientRedemptionID
+		if err := validators.ValidateOfflineRedemption(offline, device, config.MaxOfflineAge, now); err != nil {
+			results[i] = models.RedemptionResult{Status: models.RedemptionStatusRejected, Code: "INVALID", Error: err.Error()}
+		} else {
+			result, _, err := redeemCode(device, offline.RedemptionRequest, &clientRedemptionID, offline.RedeemedAt, true)
+			if err != nil {
+				// Redemptions already recorded are returned as they were when the batch is retried
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record redemption " + clientRedemptionID})
+				return
+			}
+			results[i] = result
+		}
+		results[i].ClientRedemptionID = clientRedemptionID
+	}
+
+	c.JSON(http.StatusOK, models.RedemptionSyncResponse{Results: results})
+}
+
+// redeemCode redeems the QR code a staff device scanned at the given time, and returns the
+// result with the HTTP status reporting it.
+func redeemCode(device *models.StaffDevice, req models.RedemptionRequest, clientRedemptionID *string, at time.Time, offline bool) (models.RedemptionResult, int, error) {
+	if clientRedemptionID != nil {
+		var previous models.Redemption
+		err := config.DB.First(&previous, "device_id = ? AND client_redemption_id = ?", device.ID, *clientRedemptionID).Error
+		if err == nil {
+			result, err := recordedRedemptionResult(&previous)
+			return result, http.StatusOK, err
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.RedemptionResult{}, 0, err
+		}
+	}
+
+	// Codes of other client apps are reported as unknown
+	notFound := models.RedemptionResult{Status: models.RedemptionStatusRejected, Code: "NOT_FOUND", Error: "QR Code not found"}
+	var qr models.QRCode
+	query := config.DB.Where("client_app_id = ?", device.ClientAppID)
+	subject, signed := utils.SignedPayloadSubject(config.SigningKeysFor(utils.SignedPayloadToken(req.Payload)), req.Payload)
+	switch id, digitalLink := utils.ParseScannedPayload(req.Payload); {
+	case signed:
+		query = query.Where("id = ?", subject)
+	case id != "" && utils.IsShortID(id):
+		query = query.Where("short_id = ?", id)
+	case id != "":
+		query = query.Where("id = ?", id)
+	case digitalLink != "":
+		query = query.Where("digital_link = ?", digitalLink)
+	case strings.TrimSpace(req.Payload) != "":
+		// Custom slug links are matched as the deep link they are
+		query = query.Where("deep_link_url = ?", utils.StripSignedPayload(req.Payload))
+	default:
+		return notFound, http.StatusNotFound, nil
+	}
+	if err := query.First(&qr).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return notFound, http.StatusNotFound, nil
+		}
+		return models.RedemptionResult{}, 0, err
+	}
+
+	redemption := models.Redemption{
+		ID:                 uuid.NewString(),
+		QRCodeID:           qr.ID,
+		ClientAppID:        device.ClientAppID,
+		DeviceID:           device.ID,
+		ClientRedemptionID: clientRedemptionID,
+		Operator:           req.Operator,
+		Latitude:           req.Latitude,
+		Longitude:          req.Longitude,
+		Location:           req.Location,
+		RedeemedAt:         at,
+		ReceivedAt:         time.Now(),
+		Offline:            offline,
+	}
+	result := models.RedemptionResult{QRCodeID: qr.ID, Redemption: &redemption}
+	status := http.StatusOK
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Redemptions of the same code wait for each other
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&qr, "id = ?", qr.ID).Error; err != nil {
+			return err
+		}
+
+		if qr.Status != models.QRCodeStatusRedeemed {
+			if err := qr.CheckRedeemable(at); err != nil {
+				reason := unresolvableCodes[err]
+				result = models.RedemptionResult{Status: models.RedemptionStatusRejected, QRCodeID: qr.ID, Code: reason.code, Error: err.Error()}
+				status = reason.status
+				return nil
+			}
+			if err := tx.Model(&qr).Update("status", models.QRCodeStatusRedeemed).Error; err != nil {
+				return err
+			}
+			redemption.Status = models.RedemptionStatusRedeemed
+			result.Status = redemption.Status
+			return tx.Create(&redemption).Error
+		}
+
+		var original models.Redemption
+		err := tx.First(&original, "qr_code_id = ? AND status = ?", qr.ID, models.RedemptionStatusRedeemed).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		hasOriginal := err == nil
+
+		if hasOriginal && supersedes(&qr, &original, offline, at) {
+			if err := tx.Model(&original).Update("status", models.RedemptionStatusAlreadyRedeemed).Error; err != nil {
+				return err
+			}
+			original.Status = models.RedemptionStatusAlreadyRedeemed
+			redemption.Status = models.RedemptionStatusRedeemed
+			result.Status, result.Superseded = redemption.Status, &original
+			return tx.Create(&redemption).Error
+		}
+
+		redemption.Status = models.RedemptionStatusAlreadyRedeemed
+		result.Status, result.Error = redemption.Status, models.ErrQRCodeRedeemed.Error()
+		if hasOriginal {
+			result.Original = &original
+		}
+		status = http.StatusConflict
+		return tx.Create(&redemption).Error
+	})
+	if err != nil {
+		return models.RedemptionResult{}, 0, err
+	}
+	return result, status, nil
+}
+
+// supersedes reports whether a redemption made at the given time displaces the original one of
+// the redeemed QR code. A door scanner that was offline may have let the holder in before the
+// code was redeemed elsewhere, so the earliest redemption stands, as long as the code was
+// valid when it was made.
+func supersedes(qr *models.QRCode, original *models.Redemption, offline bool, at time.Time) bool {
+	return offline && at.Before(original.RedeemedAt) && qr.CheckValidAt(at) == nil
+}
+
+// recordedRedemptionResult reports a recorded redemption as it stands now, with the
+// redemption that came first when it was not.
+func recordedRedemptionResult(redemption *models.Redemption) (models.RedemptionResult, error) {
+	result := models.RedemptionResult{Status: redemption.Status, QRCodeID: redemption.QRCodeID, Redemption: redemption}
+	if redemption.Status != models.RedemptionStatusAlreadyRedeemed {
+		return result, nil
+	}
+
+	result.Error = models.ErrQRCodeRedeemed.Error()
+	var original models.Redemption
+	err := config.DB.First(&original, "qr_code_id = ? AND status = ?", redemption.QRCodeID, models.RedemptionStatusRedeemed).Error
+	if err == nil {
+		result.Original = &original
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return result, err
+	}
+	return result, nil
+}
+
+// ListQRCodeRedemptions lists the redemptions and redemption attempts of a QR code.
+func ListQRCodeRedemptions(c *gin.Context) {
+	clientAppID, err := getValidClientAppID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var qrCode models.QRCode
+	if err := findQRCode(config.DB, c.Param("id"), &qrCode); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "QR Code not found"})
+		return
+	}
+	if err := validators.ValidateQRCodeOwnership(clientAppID, qrCode); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	var redemptions []models.Redemption
+	if err := config.DB.Where("qr_code_id = ?", qrCode.ID).Order("redeemed_at").Find(&redemptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve redemptions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"redemptions": redemptions})
+}
@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupersedes(t *testing.T) {
+	now := time.Now()
+	activatesAt, expiresAt := now.Add(-2*time.Hour), now.Add(2*time.Hour)
+	qr := &models.QRCode{Status: models.QRCodeStatusRedeemed, ActivatesAt: &activatesAt, ExpiresAt: &expiresAt}
+	original := &models.Redemption{RedeemedAt: now}
+
+	// The earliest offline redemption stands
+	assert.True(t, supersedes(qr, original, true, now.Add(-time.Hour)))
+	// Online redemptions and later offline ones do not
+	assert.False(t, supersedes(qr, original, false, now.Add(-time.Hour)))
+	assert.False(t, supersedes(qr, original, true, now.Add(time.Minute)))
+	// Nor do offline redemptions made before the code activated or after it expired
+	assert.False(t, supersedes(qr, original, true, now.Add(-3*time.Hour)))
+	original.RedeemedAt = now.Add(4 * time.Hour)
+	assert.False(t, supersedes(qr, original, true, now.Add(3*time.Hour)))
+}
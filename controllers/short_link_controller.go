@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mca93/qrcode_service/config"
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/utils"
+	"github.com/mca93/qrcode_service/validators"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxShortIDAttempts is how many short IDs are drawn before giving up on a free one.
+const maxShortIDAttempts = 5
+
+// Unique indexes behind short IDs and slugs
+const (
+	shortIDIndex       = "idx_qr_codes_short_id"
+	qrCodeSlugIndex    = "idx_qr_codes_client_app_slug"
+	clientAppSlugIndex = "idx_client_apps_slug"
+)
+
+var errSlugTaken = errors.New("slug is already in use")
+
+// ResolveShortLink resolves the short link of a QR code, /q/<shortId>.
+func ResolveShortLink(c *gin.Context) {
+	var qr models.QRCode
+	if err := findQRCode(config.DB.Preload("Template"), c.Param("ref"), &qr); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "QR Code not found"})
+		return
+	}
+
+	recordScan(c, &qr)
+}
+
+// ResolveSlugLink resolves the custom link of a QR code, /q/<clientAppSlug>/<slug>.
+func ResolveSlugLink(c *gin.Context) {
+	var clientApp models.ClientApp
+	if err := config.DB.First(&clientApp, "slug = ?", c.Param("ref")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "QR Code not found"})
+		return
+	}
+	var qr models.QRCode
+	if err := config.DB.Preload("Template").First(&qr, "client_app_id = ? AND slug = ?", clientApp.ID, c.Param("slug")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "QR Code not found"})
+		return
+	}
+
+	recordScan(c, &qr)
+}
+
+// findQRCode loads the QR code that ref identifies, by its UUID or its short ID.
+func findQRCode(db *gorm.DB, ref string, qr *models.QRCode) error {
+	if utils.IsShortID(ref) {
+		return db.First(qr, "short_id = ?", ref).Error
+	}
+	return db.First(qr, "id = ?", ref).Error
+}
+
+// createQRCode creates the QR code under a new short ID. When the unique index rejects a short
+// ID taken concurrently, it is created again under another one.
+func createQRCode(qr *models.QRCode) error {
+	deepLink := qr.DeepLinkURL
+	var err error
+	for i := 0; i < maxShortIDAttempts; i++ {
+		var shortID string
+		if shortID, err = newShortID(); err != nil {
+			return err
+		}
+		// The deep link of codes without a slug is built from the short ID
+		qr.ShortID, qr.DeepLinkURL = &shortID, deepLink
+		if err = config.DB.Create(qr).Error; uniqueViolation(err) != shortIDIndex {
+			return err
+		}
+	}
+	return err
+}
+
+// newShortID draws a short ID that no QR code has yet, skipping those that spell a blocked word.
+// The unique index still rejects the rare ID taken concurrently, see createQRCode.
+func newShortID() (string, error) {
+	for i := 0; i < maxShortIDAttempts; i++ {
+		id, err := utils.NewShortID()
+		if err != nil {
+			return "", err
+		}
+		if validators.ContainsBlockedWord(id) {
+			continue
+		}
+		var count int64
+		if err := config.DB.Model(&models.QRCode{}).Where("short_id = ?", id).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return id, nil
+		}
+	}
+	return "", errors.New("could not find a free short ID")
+}
+
+// slugDeepLinkURL checks the custom slug asked for a QR code of the client app and returns its
+// deep link. Otherwise it responds with why the slug cannot be used.
+func slugDeepLinkURL(c *gin.Context, slug, clientAppID string) (string, bool) {
+	if err := validators.ValidateSlug(slug); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	var clientApp models.ClientApp
+	if err := config.DB.First(&clientApp, "id = ?", clientAppID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client app not found"})
+		return "", false
+	}
+	if clientApp.Slug == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "slug requires the client app to have a slug"})
+		return "", false
+	}
+	return models.SlugDeepLinkURL(*clientApp.Slug, slug), true
+}
+
+// ensureClientAppSlugAvailable returns errSlugTaken when another client app has the slug.
+func ensureClientAppSlugAvailable(slug, clientAppID string) error {
+	var count int64
+	if err := config.DB.Model(&models.ClientApp{}).Where("slug = ? AND id <> ?", slug, clientAppID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errSlugTaken
+	}
+	return nil
+}
+
+// ensureQRCodeSlugAvailable returns errSlugTaken when another QR code of the client app has the slug.
+func ensureQRCodeSlugAvailable(slug, clientAppID string) error {
+	var count int64
+	if err := config.DB.Model(&models.QRCode{}).Where("client_app_id = ? AND slug = ?", clientAppID, slug).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errSlugTaken
+	}
+	return nil
+}
+
+// respondWithSlugError reports a slug that is taken as a conflict, and other failures as errors.
+func respondWithSlugError(c *gin.Context, err error) {
+	if errors.Is(err, errSlugTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check slug"})
+}
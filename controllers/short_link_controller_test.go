@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUniqueViolation(t *testing.T) {
+	err := fmt.Errorf("create: %w", &pgconn.PgError{Code: "23505", ConstraintName: shortIDIndex})
+	assert.Equal(t, shortIDIndex, uniqueViolation(err))
+	assert.Empty(t, uniqueViolation(&pgconn.PgError{Code: "23503", ConstraintName: "fk_qr_codes_template"}))
+	assert.Empty(t, uniqueViolation(errors.New("connection refused")))
+	assert.Empty(t, uniqueViolation(nil))
+}
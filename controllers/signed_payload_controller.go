@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mca93/qrcode_service/config"
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/signing"
+	"github.com/mca93/qrcode_service/utils"
+)
+
+// invalidSignedPayloads maps verification errors to the codes returned for them.
+var invalidSignedPayloads = map[error]string{
+	signing.ErrInvalidToken:           "INVALID_SIGNATURE",
+	signing.ErrUnknownKey:             "UNKNOWN_KEY",
+	utils.ErrSignedPayloadNotYetValid: "NOT_YET_VALID",
+	utils.ErrSignedPayloadExpired:     "EXPIRED",
+}
+
+// GetSigningKeys publishes the public keys signed payloads verify against, as a JWKS that
+// scanner apps cache to validate codes offline.
+func GetSigningKeys(c *gin.Context) {
+	keys := config.SigningKeys()
+	if keys == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Payload signing is not configured"})
+		return
+	}
+	// Short enough for scanner apps to pick up rotated keys soon
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, keys.JWKS())
+}
+
+// VerifySignedPayload checks a token read from a QR code, or content carrying one, and returns
+// its claims. Invalid tokens are reported in the response rather than as errors.
+func VerifySignedPayload(c *gin.Context) {
+	var req models.SignedPayloadVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token := utils.SignedPayloadToken(req.Token)
+	claims, kid, err := utils.VerifySignedPayload(config.SigningKeysFor(token), token, time.Now())
+	if errors.Is(err, utils.ErrSigningNotConfigured) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Payload signing is not configured"})
+		return
+	}
+	resp := models.SignedPayloadVerifyResponse{Valid: err == nil, KeyID: kid, Claims: claims}
+	if err != nil {
+		resp.Code, resp.Error = invalidSignedPayloads[err], err.Error()
+	}
+	c.JSON(http.StatusOK, resp)
+}
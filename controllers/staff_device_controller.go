@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mca93/qrcode_service/config"
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/utils"
+)
+
+// CreateStaffDevice registers a staff device for the client app and issues its token.
+func CreateStaffDevice(c *gin.Context) {
+	clientAppID, err := getValidClientAppID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req models.StaffDeviceCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	device := models.StaffDevice{
+		ID:          uuid.NewString(),
+		ClientAppID: clientAppID,
+		Name:        req.Name,
+		Active:      true,
+		CreatedAt:   time.Now(),
+	}
+	token, hash, err := utils.NewDeviceToken(device.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue staff device token"})
+		return
+	}
+	device.TokenHash = hash
+
+	if err := config.DB.Create(&device).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register staff device"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.StaffDeviceCreateResponse{Device: device, Token: token})
+}
+
+// ListStaffDevices lists the staff devices of the client app.
+func ListStaffDevices(c *gin.Context) {
+	clientAppID, err := getValidClientAppID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var devices []models.StaffDevice
+	if err := config.DB.Where("client_app_id = ?", clientAppID).Order("created_at").Find(&devices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve staff devices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"devices": devices})
+}
+
+// DeactivateStaffDevice revokes the token of a staff device. Its redemptions are kept.
+func DeactivateStaffDevice(c *gin.Context) {
+	clientAppID, err := getValidClientAppID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var device models.StaffDevice
+	if err := config.DB.First(&device, "id = ? AND client_app_id = ?", c.Param("id"), clientAppID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Staff device not found"})
+		return
+	}
+
+	if err := config.DB.Model(&device).Update("active", false).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate staff device"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Staff device deactivated successfully"})
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strconv"
 	"time"
 
@@ -14,6 +13,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/mca93/qrcode_service/config"
 	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/utils"
 	"github.com/mca93/qrcode_service/validators"
 	"gorm.io/gorm"
 )
@@ -44,11 +44,20 @@ func (tc *TemplateController) CreateTemplate(c *gin.Context) {
 	name := c.PostForm("name")
 	description := c.PostForm("description")
 	shape := c.PostForm("shape")
+	eyeShape := c.PostForm("eyeShape")
+	eyeColor := c.PostForm("eyeColor")
 	foregroundColor := c.PostForm("foregroundColor")
 	backgroundColor := c.PostForm("backgroundColor")
 	size := c.PostForm("size")
 	errorCorrection := c.PostForm("errorCorrection")
 	definitionJSON := c.PostForm("definition")
+	contentJSON := c.PostForm("content")
+	gradientJSON := c.PostForm("foregroundGradient")
+	frameJSON := c.PostForm("frame")
+	landingPageJSON := c.PostForm("landingPage")
+	signedPayloadJSON := c.PostForm("signedPayload")
+	logoURL := c.PostForm("logoUrl")
+	logoOptionsJSON := c.PostForm("logoOptions")
 
 	// Validate required fields
 	if name == "" || clientAppID == "" {
@@ -62,18 +71,74 @@ func (tc *TemplateController) CreateTemplate(c *gin.Context) {
 		respondWithError(c, http.StatusBadRequest, "Invalid definition format")
 		return
 	}
-	if err := definition.Validate(); err != nil {
-		respondWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid definition: %v", err))
-		return
+
+	// Parse the optional content mapping (JSON)
+	var content *models.ContentMapping
+	if contentJSON != "" {
+		content = &models.ContentMapping{}
+		if err := json.Unmarshal([]byte(contentJSON), content); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid content format")
+			return
+		}
 	}
 
-	// Handle logo file upload
-	logoPath, err := handleLogoUpload(c)
-	if err != nil {
-		respondWithError(c, http.StatusInternalServerError, "Failed to process logo upload")
+	// Parse the optional foreground gradient (JSON)
+	var gradient *models.Gradient
+	if gradientJSON != "" {
+		gradient = &models.Gradient{}
+		if err := json.Unmarshal([]byte(gradientJSON), gradient); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid foregroundGradient format")
+			return
+		}
+	}
+
+	// Parse the optional logo options (JSON)
+	var logoOptions *models.LogoOptions
+	if logoOptionsJSON != "" {
+		logoOptions = &models.LogoOptions{}
+		if err := json.Unmarshal([]byte(logoOptionsJSON), logoOptions); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid logoOptions format")
+			return
+		}
+	}
+
+	// Only uploaded logos are read from asset storage; clients may point to http(s) URLs
+	if logoURL != "" && !utils.IsRemoteLogoURL(logoURL) {
+		respondWithError(c, http.StatusBadRequest, "logoUrl must be an http or https URL")
 		return
 	}
 
+	// Parse the optional frame (JSON)
+	var frame *models.Frame
+	if frameJSON != "" {
+		frame = &models.Frame{}
+		if err := json.Unmarshal([]byte(frameJSON), frame); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid frame format")
+			return
+		}
+		frame.FontURL = "" // Only set from an uploaded font
+	}
+
+	// Parse the optional landing page (JSON)
+	var landingPage *models.LandingPage
+	if landingPageJSON != "" {
+		landingPage = &models.LandingPage{}
+		if err := json.Unmarshal([]byte(landingPageJSON), landingPage); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid landingPage format")
+			return
+		}
+	}
+
+	// Parse the optional signed payload (JSON)
+	var signedPayload *models.SignedPayload
+	if signedPayloadJSON != "" {
+		signedPayload = &models.SignedPayload{}
+		if err := json.Unmarshal([]byte(signedPayloadJSON), signedPayload); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid signedPayload format")
+			return
+		}
+	}
+
 	// Convert size and errorCorrection to appropriate types
 	sizeInt, err := strconv.Atoi(size)
 	if err != nil || sizeInt <= 0 {
@@ -88,22 +153,89 @@ func (tc *TemplateController) CreateTemplate(c *gin.Context) {
 		return
 	}
 
+	// Validate the template the same way as updates, so it cannot be saved and later fail to render
+	req := models.TemplateCreateRequest{
+		Name:               name,
+		Description:        description,
+		ClientAppID:        clientAppID,
+		Definition:         definition,
+		Shape:              models.ModuleShape(shape),
+		EyeShape:           models.EyeShape(eyeShape),
+		EyeColor:           eyeColor,
+		ForegroundColor:    foregroundColor,
+		ForegroundGradient: gradient,
+		BackgroundColor:    backgroundColor,
+		Size:               sizeInt,
+		LogoURL:            logoURL,
+		LogoOptions:        logoOptions,
+		ErrorCorrection:    errorCorrectionEnum,
+		Content:            content,
+		Frame:              frame,
+		LandingPage:        landingPage,
+		SignedPayload:      signedPayload,
+	}
+	if err := validators.ValidateTemplateCreate(req); err != nil {
+		respondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Handle logo upload, as a file or inline, which takes precedence over logoUrl
+	logoPath, err := handleLogoUpload(c)
+	if err == nil && logoPath == "" {
+		logoPath, err = handleLogoData(c.Request.Context(), c.PostForm("logoData"))
+	}
+	if err != nil {
+		respondWithUploadError(c, err, "logo")
+		return
+	}
+	if logoPath != "" {
+		logoURL = logoPath
+	}
+
+	// Handle caption font upload
+	fontPath, err := handleFontUpload(c)
+	if err != nil {
+		respondWithUploadError(c, err, "font")
+		return
+	}
+	if fontPath != "" {
+		if frame == nil {
+			respondWithError(c, http.StatusBadRequest, "A frame is required to use an uploaded font")
+			return
+		}
+		frame.FontURL = fontPath
+	}
+
 	// Create the template
 	template := models.Template{
-		ID:              uuid.NewString(),
-		Name:            name,
-		Description:     description,
-		ClientAppID:     clientAppID,
-		Definition:      definition,
-		Shape:           shape,
-		ForegroundColor: foregroundColor,
-		BackgroundColor: backgroundColor,
-		Size:            sizeInt,
-		LogoURL:         logoPath,
-		ErrorCorrection: errorCorrectionEnum,
-		Active:          true,
-		CreatedAt:       time.Now(),
-		UpdatedAt:       time.Now(),
+		ID:                 uuid.NewString(),
+		Name:               name,
+		Description:        description,
+		ClientAppID:        clientAppID,
+		Definition:         definition,
+		Shape:              req.Shape,
+		EyeShape:           req.EyeShape,
+		EyeColor:           req.EyeColor,
+		ForegroundColor:    foregroundColor,
+		ForegroundGradient: gradient,
+		BackgroundColor:    backgroundColor,
+		Size:               sizeInt,
+		LogoURL:            logoURL,
+		LogoOptions:        logoOptions,
+		ErrorCorrection:    errorCorrectionEnum,
+		Content:            content,
+		Frame:              frame,
+		LandingPage:        landingPage,
+		SignedPayload:      signedPayload,
+		Active:             true,
+		CreatedAt:          time.Now(),
+		UpdatedAt:          time.Now(),
+	}
+
+	// Reject templates whose codes are not expected to scan
+	if report, err := utils.CheckScannability(&template); err != nil || report.HasErrors() {
+		respondWithScannabilityError(c, report, err)
+		return
 	}
 
 	// Save the template to the database
@@ -189,40 +321,78 @@ func (tc *TemplateController) UpdateTemplate(c *gin.Context) {
 		return
 	}
 
+	// Only uploaded logos are read from asset storage; clients may keep theirs or point to http(s) URLs
+	if req.LogoURL != "" && req.LogoURL != template.LogoURL && !utils.IsRemoteLogoURL(req.LogoURL) {
+		respondWithError(c, http.StatusBadRequest, "logoUrl must be an http or https URL")
+		return
+	}
+
 	if err := validators.ValidateTemplateUpdate(req); err != nil {
 		respondWithError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	logoPath, err := handleLogoUpload(c)
+	// A new logo is sent inline, since the request is JSON
+	logoPath, err := handleLogoData(c.Request.Context(), req.LogoData)
 	if err != nil {
-		respondWithError(c, http.StatusInternalServerError, "Failed to process logo upload")
+		respondWithUploadError(c, err, "logo")
 		return
 	}
 
+	// Values already stored for fields that become sensitive are encrypted with the update
+	encryptStored := hasNewlySensitiveField(template.Definition, req.Definition)
+
 	// Update template fields
 	template.Name = req.Name
 	template.Description = req.Description
 	template.Definition = req.Definition
 	template.Shape = req.Shape
+	template.EyeShape = req.EyeShape
+	template.EyeColor = req.EyeColor
 	template.ForegroundColor = req.ForegroundColor
+	template.ForegroundGradient = req.ForegroundGradient
 	template.BackgroundColor = req.BackgroundColor
 	template.Size = req.Size
 	template.ErrorCorrection = req.ErrorCorrection
+	template.Content = req.Content
+	template.LogoOptions = req.LogoOptions
+	template.LandingPage = req.LandingPage
+	template.SignedPayload = req.SignedPayload
 	template.UpdatedAt = time.Now()
 
 	if logoPath != "" {
-		// Delete old logo if exists
-		if template.LogoURL != "" {
-			if err := deleteFile(template.LogoURL); err != nil {
-				// Log the error but continue with the update
-				fmt.Printf("Failed to delete old logo: %v\n", err)
-			}
-		}
 		template.LogoURL = logoPath
+	} else if req.LogoURL != "" {
+		template.LogoURL = req.LogoURL
 	}
 
-	if err := config.DB.Save(&template).Error; err != nil {
+	// The uploaded font stays with the frame, the request cannot point it elsewhere
+	oldFontURL := ""
+	if template.Frame != nil {
+		oldFontURL = template.Frame.FontURL
+	}
+	template.Frame = req.Frame
+	if template.Frame != nil {
+		template.Frame.FontURL = oldFontURL
+	}
+
+	// Reject updates that would make the template's codes unscannable
+	// Logos and fonts the template no longer uses are left to the upload collector
+	if report, err := utils.CheckScannability(&template); err != nil || report.HasErrors() {
+		respondWithScannabilityError(c, report, err)
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&template).Error; err != nil {
+			return err
+		}
+		if !encryptStored {
+			return nil
+		}
+		return encryptStoredSensitiveData(tx, &template)
+	})
+	if err != nil {
 		respondWithError(c, http.StatusInternalServerError, "Failed to update template")
 		return
 	}
@@ -230,6 +400,71 @@ func (tc *TemplateController) UpdateTemplate(c *gin.Context) {
 	respondWithSuccess(c, http.StatusOK, template)
 }
 
+// hasNewlySensitiveField reports whether a field of old definition is sensitive in updated.
+func hasNewlySensitiveField(old, updated models.Definition) bool {
+	wasSensitive := make(map[string]bool, len(old))
+	for _, field := range old {
+		wasSensitive[field.Name] = field.Sensitive
+	}
+	for _, field := range updated {
+		if was, exists := wasSensitive[field.Name]; exists && !was && field.Sensitive {
+			return true
+		}
+	}
+	return false
+}
+
+// encryptStoredSensitiveData encrypts the values the template's QR codes stored in plaintext
+// before their fields were marked sensitive, so they are masked in responses from then on.
+func encryptStoredSensitiveData(tx *gorm.DB, template *models.Template) error {
+	var codes []models.QRCode
+	return tx.Where("template_id = ?", template.ID).FindInBatches(&codes, 100, func(_ *gorm.DB, _ int) error {
+		for _, code := range codes {
+			data, err := utils.EncryptSensitiveData(code.Data, template.Definition, code.ID)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&models.QRCode{}).Where("id = ?", code.ID).Update("data", data).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// CheckTemplate reports contrast, inverted colors and logo coverage issues of a template.
+func (tc *TemplateController) CheckTemplate(c *gin.Context) {
+	clientAppID, err := getValidClientAppID(c)
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id := c.Param("id")
+	var template models.Template
+	if err := config.DB.First(&template, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondWithError(c, http.StatusNotFound, "Template not found")
+		} else {
+			respondWithError(c, http.StatusInternalServerError, "Failed to retrieve template")
+		}
+		return
+	}
+
+	if template.ClientAppID != clientAppID {
+		respondWithError(c, http.StatusForbidden, "You do not have permission to access this template")
+		return
+	}
+
+	report, err := utils.CheckScannability(&template)
+	if err != nil {
+		respondWithError(c, http.StatusUnprocessableEntity, fmt.Sprintf("Could not check template: %v", err))
+		return
+	}
+
+	respondWithSuccess(c, http.StatusOK, report)
+}
+
 // DeactivateTemplate deactivates a template by its ID.
 func (tc *TemplateController) DeactivateTemplate(c *gin.Context) {
 	clientAppID, err := getValidClientAppID(c)
@@ -282,49 +517,6 @@ func getValidClientAppID(c *gin.Context) (string, error) {
 	return clientAppID, nil
 }
 
-func handleLogoUpload(c *gin.Context) (string, error) {
-	file, err := c.FormFile("logo")
-	if err != nil {
-		if errors.Is(err, http.ErrMissingFile) {
-			return "", nil // No file uploaded is not an error
-		}
-		return "", err
-	}
-
-	// Generate unique filename
-	filename := fmt.Sprintf("%s_%s", uuid.NewString(), file.Filename)
-	uploadDir := getEnv("UPLOAD_DIR", "./uploads/logos")
-	logoPath := filepath.Join(uploadDir, filename)
-
-	// Ensure the directory exists
-	if err := ensureDirectoryExists(uploadDir); err != nil {
-		return "", fmt.Errorf("failed to create upload directory: %w", err)
-	}
-
-	// Save the uploaded file
-	if err := c.SaveUploadedFile(file, logoPath); err != nil {
-		return "", fmt.Errorf("failed to save logo file: %w", err)
-	}
-
-	return logoPath, nil
-}
-
-func deleteFile(path string) error {
-	if path == "" {
-		return nil
-	}
-	return os.Remove(path)
-}
-
-func ensureDirectoryExists(dirPath string) error {
-	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
-		if err := os.MkdirAll(dirPath, os.ModePerm); err != nil {
-			return fmt.Errorf("failed to create directory: %w", err)
-		}
-	}
-	return nil
-}
-
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -336,6 +528,15 @@ func respondWithError(c *gin.Context, code int, message string) {
 	c.JSON(code, gin.H{"error": message})
 }
 
+// respondWithScannabilityError rejects a template that failed its scannability check.
+func respondWithScannabilityError(c *gin.Context, report *models.ScannabilityReport, err error) {
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, fmt.Sprintf("Could not check template: %v", err))
+		return
+	}
+	c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Template is not scannable", "issues": report.Issues})
+}
+
 func respondWithSuccess(c *gin.Context, code int, data interface{}) {
 	c.JSON(code, data)
 }
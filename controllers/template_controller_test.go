@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasNewlySensitiveField(t *testing.T) {
+	old := models.Definition{{Name: "seat"}, {Name: "phone", Sensitive: true}}
+	assert.False(t, hasNewlySensitiveField(old, old))
+	// New fields have no stored values yet
+	assert.False(t, hasNewlySensitiveField(old, models.Definition{{Name: "seat"}, {Name: "nationalId", Sensitive: true}}))
+	assert.True(t, hasNewlySensitiveField(old, models.Definition{{Name: "seat", Sensitive: true}, {Name: "phone", Sensitive: true}}))
+}
@@ -0,0 +1,261 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mca93/qrcode_service/config"
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/utils"
+)
+
+// Uploads are stored named by their content hash, so templates with the same logo or font
+// share an object. Objects are never deleted when a template or QR code lets go of them; the
+// collector removes the ones nothing refers to.
+const (
+	logoUploadPrefix = "logos"
+	fontUploadPrefix = "fonts"
+	mediaPrefix      = "media" // Media field uploads, see models.MediaAssetPrefix
+)
+
+// invalidUploadError rejects an upload for its content. Its message is returned to the client.
+type invalidUploadError struct {
+	err error
+}
+
+func (e *invalidUploadError) Error() string {
+	return e.err.Error()
+}
+
+func (e *invalidUploadError) Unwrap() error {
+	return e.err
+}
+
+// handleLogoUpload sanitizes and stores the logo uploaded in the "logo" form file, and returns
+// its key or "" when the request carries none.
+func handleLogoUpload(c *gin.Context) (string, error) {
+	data, err := readFormFile(c, "logo", utils.MaxLogoBytes)
+	if err != nil || data == nil {
+		return "", err
+	}
+	return storeLogo(c.Request.Context(), data)
+}
+
+// handleLogoData sanitizes and stores a logo sent inline, as base64 or a base64 data: URI,
+// and returns its key or "" when logoData is empty.
+func handleLogoData(ctx context.Context, logoData string) (string, error) {
+	if logoData == "" {
+		return "", nil
+	}
+	data, err := decodeLogoData(logoData)
+	if err != nil {
+		return "", &invalidUploadError{err}
+	}
+	return storeLogo(ctx, data)
+}
+
+func storeLogo(ctx context.Context, data []byte) (string, error) {
+	clean, ext, err := utils.SanitizeLogo(data)
+	if err != nil {
+		return "", &invalidUploadError{fmt.Errorf("invalid logo file: %w", err)}
+	}
+	return utils.SaveUpload(ctx, config.Storage, logoUploadPrefix, clean, ext)
+}
+
+func decodeLogoData(logoData string) ([]byte, error) {
+	encoded := strings.TrimSpace(logoData)
+	if strings.HasPrefix(encoded, "data:") {
+		comma := strings.IndexByte(encoded, ',')
+		if comma < 0 || !strings.HasSuffix(encoded[:comma], ";base64") {
+			return nil, errors.New("logoData must be base64 encoded")
+		}
+		encoded = encoded[comma+1:]
+	}
+	if base64.StdEncoding.DecodedLen(len(encoded)) > utils.MaxLogoBytes+2 {
+		return nil, utils.ErrLogoTooLarge
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("logoData must be base64 encoded")
+	}
+	if len(data) > utils.MaxLogoBytes {
+		return nil, utils.ErrLogoTooLarge
+	}
+	return data, nil
+}
+
+// handleFontUpload checks and stores the caption font uploaded in the "font" form file, and
+// returns its key or "" when the request carries none.
+func handleFontUpload(c *gin.Context) (string, error) {
+	data, err := readFormFile(c, "font", utils.MaxFontBytes)
+	if err != nil || data == nil {
+		return "", err
+	}
+	ext, err := utils.ValidateFont(data)
+	if err != nil {
+		return "", &invalidUploadError{fmt.Errorf("invalid font file: %w", err)}
+	}
+	return utils.SaveUpload(c.Request.Context(), config.Storage, fontUploadPrefix, data, ext)
+}
+
+// readFormFile reads the file uploaded in field, or returns nil when the request carries no
+// such file. The client's file name and content type are not trusted.
+func readFormFile(c *gin.Context, field string, maxBytes int) ([]byte, error) {
+	file, err := c.FormFile(field)
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) || errors.Is(err, http.ErrNotMultipart) {
+			return nil, nil // No file uploaded is not an error
+		}
+		return nil, err
+	}
+	limit := fmt.Sprintf("%d bytes", maxBytes)
+	if maxBytes >= 1<<20 {
+		limit = fmt.Sprintf("%d MB", maxBytes>>20)
+	}
+	tooLarge := &invalidUploadError{fmt.Errorf("%s must be at most %s", field, limit)}
+	if file.Size > int64(maxBytes) {
+		return nil, tooLarge
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, int64(maxBytes)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBytes {
+		return nil, tooLarge
+	}
+	return data, nil
+}
+
+// respondWithUploadError reports an upload of kind ("logo", "font" or "media") that was not stored.
+func respondWithUploadError(c *gin.Context, err error, kind string) {
+	var invalid *invalidUploadError
+	if errors.As(err, &invalid) {
+		respondWithError(c, http.StatusBadRequest, invalid.Error())
+		return
+	}
+	respondWithError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to process %s upload", kind))
+}
+
+// CollectOrphanedUploads removes the uploaded logos, fonts and media that nothing refers to and
+// that are older than grace, and the exported images whose download URLs have expired. It
+// returns the removed keys.
+func CollectOrphanedUploads(ctx context.Context, grace time.Duration) ([]string, error) {
+	var templates []models.Template
+	if err := config.DB.Model(&models.Template{}).Select("logo_url", "frame").Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("failed to list template uploads: %w", err)
+	}
+
+	referenced := make(map[string]bool)
+	for _, template := range templates {
+		if template.LogoURL != "" && !utils.IsRemoteLogoURL(template.LogoURL) {
+			referenced[utils.AssetKey(template.LogoURL)] = true
+		}
+		if template.Frame != nil && template.Frame.FontURL != "" {
+			referenced[utils.AssetKey(template.Frame.FontURL)] = true
+		}
+	}
+
+	var removed []string
+	for _, prefix := range []string{logoUploadPrefix, fontUploadPrefix} {
+		keys, err := utils.CollectUploads(ctx, config.Storage, prefix, referenced, grace)
+		removed = append(removed, keys...)
+		if err != nil {
+			return removed, err
+		}
+	}
+	keys, err := collectOrphanedMedia(ctx, grace)
+	removed = append(removed, keys...)
+	if err != nil {
+		return removed, err
+	}
+	keys, err = utils.CollectUploads(ctx, config.Storage, exportPrefix, nil, exportURLExpiry)
+	return append(removed, keys...), err
+}
+
+// collectOrphanedMedia removes the media no QR code refers to any more: replaced uploads and
+// the media of deleted QR codes.
+func collectOrphanedMedia(ctx context.Context, grace time.Duration) ([]string, error) {
+	objects, err := config.Storage.List(ctx, mediaPrefix+"/")
+	if err != nil {
+		return nil, err
+	}
+	// Keys are media/<qrCodeID>/<field>/<sha256>.<ext>
+	var ids []string
+	seen := make(map[string]bool)
+	for _, object := range objects {
+		if parts := strings.SplitN(object.Key, "/", 3); len(parts) == 3 && !seen[parts[1]] {
+			seen[parts[1]] = true
+			ids = append(ids, parts[1])
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var qrCodes []models.QRCode
+	if err := config.DB.Select("id", "data").Where("id IN ?", ids).Find(&qrCodes).Error; err != nil {
+		return nil, fmt.Errorf("failed to list QR code media: %w", err)
+	}
+	referenced := make(map[string]bool)
+	for _, qr := range qrCodes {
+		for _, value := range qr.Data {
+			if ref, err := models.ParseMediaReference(value); err == nil && ref.BelongsTo(qr.ID) {
+				referenced[ref.Asset] = true
+			}
+		}
+	}
+	return utils.CollectUploads(ctx, config.Storage, mediaPrefix, referenced, grace)
+}
+
+// StartUploadCollector collects orphaned uploads in the background every UPLOAD_GC_INTERVAL
+// (default 1h, 0 disables it), sparing files written within UPLOAD_GC_GRACE (default 1h).
+func StartUploadCollector() {
+	interval := getDurationEnv("UPLOAD_GC_INTERVAL", time.Hour)
+	grace := getDurationEnv("UPLOAD_GC_GRACE", time.Hour)
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			removed, err := CollectOrphanedUploads(context.Background(), grace)
+			if err != nil {
+				log.Printf("Failed to collect orphaned uploads: %v", err)
+			}
+			if len(removed) > 0 {
+				log.Printf("Removed %d orphaned uploads", len(removed))
+			}
+		}
+	}()
+}
+
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mca93/qrcode_service/config"
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/utils"
+	"github.com/mca93/qrcode_service/validators"
+	"gorm.io/gorm"
+)
+
+const (
+	variantCookiePrefix = "qr_variant_"     // Followed by the QR code ID
+	variantCookieMaxAge = 90 * 24 * 60 * 60 // Seconds scanners stay on their variant
+	scanIDParam         = "qrScanId"        // Added to variant destinations for conversion callbacks
+)
+
+// routeScan resolves where the routing of a DYNAMIC QR code sends a scan. Scanners sent to a
+// variant keep it in a cookie, and the scan is recorded for the variant report.
+func routeScan(c *gin.Context, qr *models.QRCode) string {
+	cookieName := variantCookiePrefix + qr.ID
+	assigned, _ := c.Cookie(cookieName)
+	visitorID := utils.VisitorID(qr.ID, c.ClientIP(), c.Request.UserAgent())
+
+	route := qr.Routing.Resolve(utils.NewScanContext(c.Request, qr.ScanCount, visitorID, assigned))
+	if route.Variant == nil {
+		return route.Destination
+	}
+
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(cookieName, route.Variant.Name, variantCookieMaxAge, "/", "", secure, true)
+
+	event := models.ScanEvent{
+		ID:        uuid.NewString(),
+		QRCodeID:  qr.ID,
+		Variant:   route.Variant.Name,
+		VisitorID: visitorID,
+		CreatedAt: time.Now(),
+	}
+	if err := config.DB.Create(&event).Error; err != nil {
+		// The scanner still gets to the variant, only the report misses the scan
+		log.Printf("Failed to record scan of QR code %s: %v", qr.ID, err)
+		return route.Destination
+	}
+	destination, err := utils.AddQueryParam(route.Destination, scanIDParam, event.ID)
+	if err != nil {
+		return route.Destination
+	}
+	return destination
+}
+
+// RecordConversion marks a scan sent to a variant as converted. Destinations call it with the
+// qrScanId they received; repeated calls count once.
+func RecordConversion(c *gin.Context) {
+	var req models.ConversionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var qrCode models.QRCode
+	if err := findQRCode(config.DB, c.Param("id"), &qrCode); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "QR Code not found"})
+		return
+	}
+
+	var event models.ScanEvent
+	if err := config.DB.First(&event, "id = ? AND qr_code_id = ?", req.ScanID, qrCode.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Scan not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record conversion"})
+		return
+	}
+
+	if event.ConvertedAt == nil {
+		now := time.Now()
+		err := config.DB.Model(&event).Where("converted_at IS NULL").Update("converted_at", now).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record conversion"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Conversion recorded", "variant": event.Variant})
+}
+
+// GetVariantReport compares the scans, unique visitors and conversions of a QR code's variants.
+func GetVariantReport(c *gin.Context) {
+	clientAppID, err := getValidClientAppID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var qrCode models.QRCode
+	if err := findQRCode(config.DB, c.Param("id"), &qrCode); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "QR Code not found"})
+		return
+	}
+	if err := validators.ValidateQRCodeOwnership(clientAppID, qrCode); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	var rows []models.VariantStats
+	err = config.DB.Model(&models.ScanEvent{}).
+		Select("variant, COUNT(*) AS scans, COUNT(DISTINCT visitor_id) AS visitors, COUNT(converted_at) AS conversions").
+		Where("qr_code_id = ?", qrCode.ID).
+		Group("variant").
+		Order("variant").
+		Scan(&rows).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build variant report"})
+		return
+	}
+
+	var variants []models.Variant
+	if qrCode.Routing != nil {
+		variants = qrCode.Routing.Variants
+	}
+	c.JSON(http.StatusOK, models.VariantReport{QRCodeID: qrCode.ID, Variants: utils.VariantStats(variants, rows)})
+}
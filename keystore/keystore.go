@@ -0,0 +1,437 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/signing"
+	"gorm.io/gorm"
+)
+
+// Algorithms of the key material of each purpose.
+const (
+	AlgorithmA256GCM = "A256GCM" // 256-bit AES key
+	AlgorithmHS256   = "HS256"   // 256-bit HMAC-SHA256 secret
+)
+
+// missReloadInterval is the least time between the reloads lookups of unknown keys cause, so
+// bogus key IDs cannot hammer the database.
+const missReloadInterval = 10 * time.Second
+
+// ErrKeyNotFound is returned for key IDs the store does not have, or that belong to another purpose.
+var ErrKeyNotFound = errors.New("key not found")
+
+// Key is a decrypted version of a managed key.
+type Key struct {
+	ID        string
+	Purpose   models.KeyPurpose
+	Version   int
+	Algorithm string
+	Status    models.KeyStatus
+	CreatedAt time.Time
+	Material  []byte // PKCS#8 PEM private key for signing, raw bytes otherwise
+}
+
+// Store keeps the keys of every crypto feature of the service in the database, encrypted
+// under a master key from configuration. It caches the decrypted keys; Reload picks up
+// rotations made by other instances.
+type Store struct {
+	db     *gorm.DB
+	master cipher.AEAD
+
+	mu         sync.RWMutex
+	keys       map[string]*Key
+	current    map[models.KeyPurpose]*Key
+	generation uint64
+	signingSet *signing.KeySet
+	signingGen uint64
+	missReload time.Time // Last reload caused by a lookup miss
+	verifyOnly []*signing.PublicKey
+}
+
+// Open loads the keys from db, decrypting them with the 32-byte masterKey, and creates the
+// first version of purposes that have no key yet.
+func Open(db *gorm.DB, masterKey []byte) (*Store, error) {
+	master, err := newMasterCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{db: db, master: master}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	for _, purpose := range models.KeyPurposes {
+		if _, err := s.Current(purpose); err == nil {
+			continue
+		}
+		if _, err := s.Rotate(purpose); err != nil {
+			// Another instance may have provisioned it first
+			if reloadErr := s.Reload(); reloadErr != nil {
+				return nil, reloadErr
+			}
+			if _, currentErr := s.Current(purpose); currentErr != nil {
+				return nil, fmt.Errorf("failed to create %s key: %w", purpose, err)
+			}
+		}
+	}
+	return s, nil
+}
+
+func newMasterCipher(masterKey []byte) (cipher.AEAD, error) {
+	if len(masterKey) != 32 {
+		return nil, errors.New("master key must be 32 bytes")
+	}
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Reload reads the keys from the database again.
+func (s *Store) Reload() error {
+	var rows []models.ManagedKey
+	if err := s.db.Order("purpose, version").Find(&rows).Error; err != nil {
+		return fmt.Errorf("failed to load keys: %w", err)
+	}
+
+	keys := make(map[string]*Key, len(rows))
+	current := make(map[models.KeyPurpose]*Key)
+	for _, row := range rows {
+		material, err := openMaterial(s.master, row.ID, row.EncryptedMaterial)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt key %s, the master key may be wrong: %w", row.ID, err)
+		}
+		key := &Key{
+			ID:        row.ID,
+			Purpose:   row.Purpose,
+			Version:   row.Version,
+			Algorithm: row.Algorithm,
+			Status:    row.Status,
+			CreatedAt: row.CreatedAt,
+			Material:  material,
+		}
+		keys[key.ID] = key
+		// Rows are ordered by version, so the newest active version wins
+		if key.Status == models.KeyStatusActive {
+			current[key.Purpose] = key
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if changed(s.keys, keys) {
+		s.generation++
+	}
+	s.keys, s.current = keys, current
+	return nil
+}
+
+// changed tells whether keys were added or changed status since before.
+func changed(before, after map[string]*Key) bool {
+	if len(before) != len(after) {
+		return true
+	}
+	for id, key := range after {
+		if old, ok := before[id]; !ok || old.Status != key.Status {
+			return true
+		}
+	}
+	return false
+}
+
+// Current returns the version of purpose new signatures and ciphertexts use.
+func (s *Store) Current(purpose models.KeyPurpose) (*Key, error) {
+	s.mu.RLock()
+	key, ok := s.current[purpose]
+	s.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	// Another instance may have provisioned it since the last reload
+	if s.reloadAfterMiss() {
+		s.mu.RLock()
+		key, ok = s.current[purpose]
+		s.mu.RUnlock()
+	}
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+// Key returns the version of purpose with id, active or retired. Unknown IDs reload the keys
+// first, as another instance may have rotated since the last reload.
+func (s *Store) Key(purpose models.KeyPurpose, id string) (*Key, error) {
+	s.mu.RLock()
+	key, ok := s.keys[id]
+	s.mu.RUnlock()
+	if !ok && s.reloadAfterMiss() {
+		s.mu.RLock()
+		key, ok = s.keys[id]
+		s.mu.RUnlock()
+	}
+	if !ok || key.Purpose != purpose {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+// reloadAfterMiss reloads the keys after a lookup miss, at most once per missReloadInterval,
+// and reports whether it did.
+func (s *Store) reloadAfterMiss() bool {
+	s.mu.Lock()
+	if time.Since(s.missReload) < missReloadInterval {
+		s.mu.Unlock()
+		return false
+	}
+	s.missReload = time.Now()
+	s.mu.Unlock()
+	return s.Reload() == nil
+}
+
+// SigningKeySetFor returns the signing key set to verify a token signed with the key kid,
+// reloading the keys first when kid is unknown.
+func (s *Store) SigningKeySetFor(kid string) (*signing.KeySet, error) {
+	if kid != "" {
+		s.Key(models.KeyPurposeSigning, kid) // Reloads on a miss
+	}
+	return s.SigningKeySet()
+}
+
+// Keys returns the versions of purpose, or of every purpose when it is empty, newest first.
+func (s *Store) Keys(purpose models.KeyPurpose) []*Key {
+	s.mu.RLock()
+	keys := make([]*Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		if purpose == "" || key.Purpose == purpose {
+			keys = append(keys, key)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Purpose != keys[j].Purpose {
+			return keys[i].Purpose < keys[j].Purpose
+		}
+		return keys[i].Version > keys[j].Version
+	})
+	return keys
+}
+
+// Rotate creates a new version of purpose and retires the current one, which keeps verifying
+// and decrypting what was made with it.
+func (s *Store) Rotate(purpose models.KeyPurpose) (*Key, error) {
+	if !purpose.IsValid() {
+		return nil, fmt.Errorf("unknown key purpose: %s", purpose)
+	}
+	material, algorithm, err := generateMaterial(purpose)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.addVersion(purpose, "", algorithm, material); err != nil {
+		return nil, fmt.Errorf("failed to rotate %s key: %w", purpose, err)
+	}
+	return s.Current(purpose)
+}
+
+// Import adds existing key material as the current version of purpose, keeping id as its key
+// ID so what was made with it keeps verifying. Importing an ID the store has does nothing, so
+// a key imported on every start stays retired once rotated.
+func (s *Store) Import(purpose models.KeyPurpose, id, algorithm string, material []byte) (*Key, error) {
+	if !purpose.IsValid() {
+		return nil, fmt.Errorf("unknown key purpose: %s", purpose)
+	}
+	if key, err := s.Key(purpose, id); err == nil {
+		return key, nil
+	}
+	if err := s.addVersion(purpose, id, algorithm, material); err != nil {
+		// Another instance may have imported it first
+		if key, keyErr := s.Key(purpose, id); keyErr == nil {
+			return key, nil
+		}
+		return nil, fmt.Errorf("failed to import %s key %s: %w", purpose, id, err)
+	}
+	return s.Key(purpose, id)
+}
+
+// addVersion stores material as the new active version of purpose, retiring the current one,
+// and reloads the keys. Without an id the version is named purpose-vN.
+func (s *Store) addVersion(purpose models.KeyPurpose, id, algorithm string, material []byte) error {
+	now := time.Now()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		version := 1
+		var latest models.ManagedKey
+		err := tx.Where("purpose = ?", purpose).Order("version DESC").First(&latest).Error
+		switch {
+		case err == nil:
+			version = latest.Version + 1
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		row := models.ManagedKey{
+			ID:        id,
+			Purpose:   purpose,
+			Version:   version,
+			Algorithm: algorithm,
+			Status:    models.KeyStatusActive,
+			CreatedAt: now,
+		}
+		if row.ID == "" {
+			row.ID = fmt.Sprintf("%s-v%d", purpose, version)
+		}
+		if row.EncryptedMaterial, err = sealMaterial(s.master, row.ID, material); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ManagedKey{}).
+			Where("purpose = ? AND status = ?", purpose, models.KeyStatusActive).
+			Updates(map[string]interface{}{"status": models.KeyStatusRetired, "retired_at": now}).Error; err != nil {
+			return err
+		}
+		// A concurrent rotation fails here on the unique purpose and version
+		return tx.Create(&row).Error
+	})
+	if err != nil {
+		return err
+	}
+	return s.Reload()
+}
+
+// AddVerificationKeys adds public keys the signing key set verifies with, for tokens signed
+// with keys the store does not hold.
+func (s *Store) AddVerificationKeys(keys ...*signing.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.verifyOnly = append(s.verifyOnly, keys...)
+	s.generation++
+}
+
+// RotateOlderThan rotates the purposes whose current version was created more than maxAge ago.
+func (s *Store) RotateOlderThan(maxAge time.Duration) ([]*Key, error) {
+	var rotated []*Key
+	for _, purpose := range models.KeyPurposes {
+		current, err := s.Current(purpose)
+		if err == nil && time.Since(current.CreatedAt) < maxAge {
+			continue
+		}
+		key, err := s.Rotate(purpose)
+		if err != nil {
+			return rotated, err
+		}
+		rotated = append(rotated, key)
+	}
+	return rotated, nil
+}
+
+// SigningKeySet returns the signing keys as a key set that signs with the current version and
+// verifies with every version. It is rebuilt only after the keys change.
+func (s *Store) SigningKeySet() (*signing.KeySet, error) {
+	s.mu.RLock()
+	set, fresh := s.signingSet, s.signingSet != nil && s.signingGen == s.generation
+	s.mu.RUnlock()
+	if fresh {
+		return set, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var current *signing.Key
+	var others []*signing.PublicKey
+	for _, key := range s.keys {
+		if key.Purpose != models.KeyPurposeSigning {
+			continue
+		}
+		parsed, err := signing.ParsePrivateKey(key.ID, key.Material)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signing key %s: %w", key.ID, err)
+		}
+		if key == s.current[models.KeyPurposeSigning] {
+			current = parsed
+		} else {
+			others = append(others, &signing.PublicKey{ID: parsed.ID, Algorithm: parsed.Algorithm, Key: parsed.Public()})
+		}
+	}
+	// Publish the keys in a stable order, oldest first
+	sort.Slice(others, func(i, j int) bool { return s.keys[others[i].ID].Version < s.keys[others[j].ID].Version })
+	others = append(others, s.verifyOnly...)
+	s.signingSet, s.signingGen = signing.NewKeySet(current, others...), s.generation
+	return s.signingSet, nil
+}
+
+// HMACKeys are the versions of an HMAC purpose, looked up by key ID.
+type HMACKeys struct {
+	store   *Store
+	purpose models.KeyPurpose
+}
+
+// HMACKeys returns the keys of the webhook, url-signing or unlock purpose.
+func (s *Store) HMACKeys(purpose models.KeyPurpose) HMACKeys {
+	return HMACKeys{store: s, purpose: purpose}
+}
+
+// Current returns the ID and secret of the current version.
+func (h HMACKeys) Current() (string, []byte, error) {
+	key, err := h.store.Current(h.purpose)
+	if err != nil {
+		return "", nil, err
+	}
+	return key.ID, key.Material, nil
+}
+
+// Key returns the secret of the version with id.
+func (h HMACKeys) Key(id string) ([]byte, error) {
+	key, err := h.store.Key(h.purpose, id)
+	if err != nil {
+		return nil, err
+	}
+	return key.Material, nil
+}
+
+// generateMaterial creates the key material of a new version of purpose.
+func generateMaterial(purpose models.KeyPurpose) ([]byte, string, error) {
+	if purpose == models.KeyPurposeSigning {
+		key, err := signing.GenerateKey("", signing.AlgorithmEdDSA)
+		if err != nil {
+			return nil, "", err
+		}
+		pemData, err := key.MarshalPrivateKey()
+		return pemData, signing.AlgorithmEdDSA, err
+	}
+
+	algorithm := AlgorithmHS256
+	if purpose == models.KeyPurposeEncryption {
+		algorithm = AlgorithmA256GCM
+	}
+	material := make([]byte, 32)
+	if _, err := rand.Read(material); err != nil {
+		return nil, "", err
+	}
+	return material, algorithm, nil
+}
+
+// sealMaterial encrypts key material under the master key, bound to the key's ID so stored
+// material cannot be swapped between keys.
+func sealMaterial(master cipher.AEAD, id string, material []byte) ([]byte, error) {
+	nonce := make([]byte, master.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return master.Seal(nonce, nonce, material, []byte(id)), nil
+}
+
+func openMaterial(master cipher.AEAD, id string, sealed []byte) ([]byte, error) {
+	if len(sealed) < master.NonceSize() {
+		return nil, errors.New("sealed key material is too short")
+	}
+	nonce, ciphertext := sealed[:master.NonceSize()], sealed[master.NonceSize():]
+	return master.Open(nil, nonce, ciphertext, []byte(id))
+}
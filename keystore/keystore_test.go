@@ -0,0 +1,91 @@
+package keystore
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/signing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSealMaterial(t *testing.T) {
+	master, err := newMasterCipher(bytes.Repeat([]byte{1}, 32))
+	assert.NoError(t, err)
+
+	sealed, err := sealMaterial(master, "encryption-v1", []byte("material"))
+	assert.NoError(t, err)
+	material, err := openMaterial(master, "encryption-v1", sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("material"), material)
+
+	// Material is bound to its key ID and master key
+	_, err = openMaterial(master, "encryption-v2", sealed)
+	assert.Error(t, err)
+	other, _ := newMasterCipher(bytes.Repeat([]byte{2}, 32))
+	_, err = openMaterial(other, "encryption-v1", sealed)
+	assert.Error(t, err)
+
+	_, err = newMasterCipher([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestSigningKeySet(t *testing.T) {
+	store := &Store{keys: map[string]*Key{}, current: map[models.KeyPurpose]*Key{}}
+	for version, status := range []models.KeyStatus{models.KeyStatusRetired, models.KeyStatusActive} {
+		material, algorithm, err := generateMaterial(models.KeyPurposeSigning)
+		assert.NoError(t, err)
+		key := &Key{ID: fmt.Sprintf("signing-v%d", version+1), Purpose: models.KeyPurposeSigning, Version: version + 1,
+			Algorithm: algorithm, Status: status, Material: material}
+		store.keys[key.ID] = key
+		if status == models.KeyStatusActive {
+			store.current[key.Purpose] = key
+		}
+	}
+
+	set, err := store.SigningKeySet()
+	assert.NoError(t, err)
+	assert.Equal(t, "signing-v2", set.SigningKey().ID)
+	jwks := set.JWKS()
+	assert.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "signing-v2", jwks.Keys[0].Kid)
+	assert.Equal(t, "signing-v1", jwks.Keys[1].Kid)
+
+	// Tokens of the retired version still verify
+	retired, _ := signing.ParsePrivateKey("signing-v1", store.keys["signing-v1"].Material)
+	token, _ := signing.Sign(retired, map[string]string{"sub": "qr-1"})
+	var claims map[string]string
+	kid, err := set.Verify(token, &claims)
+	assert.NoError(t, err)
+	assert.Equal(t, "signing-v1", kid)
+
+	// Cached until the keys change
+	again, _ := store.SigningKeySet()
+	assert.Same(t, set, again)
+
+	// Public keys of signing keys the store does not hold still verify
+	legacy, _ := signing.GenerateKey("legacy", signing.AlgorithmES256)
+	store.AddVerificationKeys(&signing.PublicKey{ID: legacy.ID, Algorithm: legacy.Algorithm, Key: legacy.Public()})
+	set, err = store.SigningKeySet()
+	assert.NoError(t, err)
+	assert.Len(t, set.JWKS().Keys, 3)
+	token, _ = signing.Sign(legacy, map[string]string{"sub": "qr-1"})
+	kid, err = set.Verify(token, &claims)
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy", kid)
+
+	hmacKeys := store.HMACKeys(models.KeyPurposeWebhook)
+	_, err = hmacKeys.Key("signing-v1")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestKeyMissReloadIsRateLimited(t *testing.T) {
+	// A reload this recent keeps the miss from reaching the database, which this store has not
+	store := &Store{keys: map[string]*Key{}, current: map[models.KeyPurpose]*Key{}, missReload: time.Now()}
+	_, err := store.Key(models.KeyPurposeEncryption, "encryption-v9")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	_, err = store.Current(models.KeyPurposeEncryption)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mca93/qrcode_service/config"
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/utils"
+)
+
+// StaffDeviceKey is the context key StaffDeviceAuthMiddleware stores the authenticated
+// *models.StaffDevice under.
+const StaffDeviceKey = "staffDevice"
+
+// StaffDeviceAuthMiddleware authenticates staff devices by the token they were issued, sent as
+// "Authorization: Bearer <token>". The device and its client app must be active.
+func StaffDeviceAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		deviceID, secret, valid := utils.ParseDeviceToken(strings.TrimSpace(token))
+		if !ok || !valid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "A staff device token is required"})
+			c.Abort()
+			return
+		}
+
+		var device models.StaffDevice
+		if err := config.DB.First(&device, "id = ?", deviceID).Error; err != nil || !utils.CheckDeviceSecret(secret, device.TokenHash) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid staff device token"})
+			c.Abort()
+			return
+		}
+		if !device.Active {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Staff device is not active"})
+			c.Abort()
+			return
+		}
+
+		var clientApp models.ClientApp
+		if err := config.DB.First(&clientApp, "id = ?", device.ClientAppID).Error; err != nil || clientApp.Status != models.ClientAppStatusActive {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Client app is not active!"})
+			c.Abort()
+			return
+		}
+
+		now := time.Now()
+		config.DB.Model(&device).UpdateColumn("last_seen_at", now)
+		device.LastSeenAt = &now
+
+		c.Set(StaffDeviceKey, &device)
+		c.Next()
+	}
+}
@@ -14,32 +14,37 @@ const (
 )
 
 type ClientApp struct {
-	ID           string          `gorm:"primaryKey" json:"ID"`
-	Name         string          `json:"Name"`
-	ContactEmail string          `json:"ContactEmail"`
-	Status       ClientAppStatus `gorm:"default:CLIENT_APP_STATUS_ACTIVE" json:"Status"`
-	CreatedAt    time.Time       `json:"CreatedAt"`
-	UpdatedAt    time.Time       `json:"UpdatedAt"` // Data de atualização do aplicativo
+	ID            string          `gorm:"primaryKey" json:"ID"`
+	Name          string          `json:"Name"`
+	ContactEmail  string          `json:"ContactEmail"`
+	Status        ClientAppStatus `gorm:"default:CLIENT_APP_STATUS_ACTIVE" json:"Status"`
+	CreatedAt     time.Time       `json:"CreatedAt"`
+	UpdatedAt     time.Time       `json:"UpdatedAt"`                         // Data de atualização do aplicativo
+	RevealKeyHash string          `json:"-"`                                 // Hash da chave que revela os campos sensíveis dos QR codes
+	Slug          *string         `gorm:"uniqueIndex" json:"Slug,omitempty"` // Prefixo dos caminhos personalizados dos QR codes
 
 }
 type ClientAppCreateRequest struct {
 	Name         string          `json:"Name"`         // Nome do aplicativo
 	ContactEmail string          `json:"ContactEmail"` // Email de contato do cliente
 	Status       ClientAppStatus `json:"Status"`       // Status do aplicativo
+	Slug         string          `json:"Slug"`         // Prefixo dos caminhos personalizados dos QR codes
 }
 type ClientAppUpdateRequest struct {
 	Name         string          `json:"Name"`         // Nome do aplicativo
 	ContactEmail string          `json:"ContactEmail"` // Email de contato do cliente
 	Status       ClientAppStatus `json:"Status"`       // Status do aplicativo
+	Slug         string          `json:"Slug"`         // Só pode ser definido uma vez
 }
 type ClientAppResponse struct {
-	ID           string          `json:"ID"`           // ID do aplicativo
-	Name         string          `json:"Name"`         // Nome do aplicativo
-	ContactEmail string          `json:"ContactEmail"` // Email de contato do cliente
-	Status       ClientAppStatus `json:"Status"`       // Status do aplicativo
-	CreatedAt    time.Time       `json:"CreatedAt"`    // Data de criação do aplicativo
-	UpdatedAt    time.Time       `json:"UpdatedAt"`    // Data de atualização do aplicativo
-	DeletedAt    *time.Time      `json:"DeletedAt"`    // Data de exclusão do aplicativo
+	ID           string          `json:"ID"`             // ID do aplicativo
+	Name         string          `json:"Name"`           // Nome do aplicativo
+	ContactEmail string          `json:"ContactEmail"`   // Email de contato do cliente
+	Status       ClientAppStatus `json:"Status"`         // Status do aplicativo
+	CreatedAt    time.Time       `json:"CreatedAt"`      // Data de criação do aplicativo
+	UpdatedAt    time.Time       `json:"UpdatedAt"`      // Data de atualização do aplicativo
+	DeletedAt    *time.Time      `json:"DeletedAt"`      // Data de exclusão do aplicativo
+	Slug         *string         `json:"Slug,omitempty"` // Prefixo dos caminhos personalizados dos QR codes
 }
 type ClientAppListResponse struct {
 	ClientApps []ClientAppResponse `json:"ClientApps"` // Lista de aplicativos
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// ContentFormat defines how the content encoded in the symbol is assembled.
+type ContentFormat string
+
+const (
+	ContentFormatDeepLink ContentFormat = "deeplink" // Encode the QR code's DeepLinkURL (default)
+	ContentFormatURL      ContentFormat = "url"      // URL template with {field} placeholders
+	ContentFormatKeyValue ContentFormat = "keyvalue" // key=value pairs joined by a separator
+	ContentFormatJSON     ContentFormat = "json"     // JSON object built from Data
+)
+
+func (cf ContentFormat) IsValid() bool {
+	switch cf {
+	case ContentFormatDeepLink, ContentFormatURL, ContentFormatKeyValue, ContentFormatJSON:
+		return true
+	default:
+		return false
+	}
+}
+
+// Placeholders that are not backed by Data but by the QR code itself.
+const (
+	PlaceholderQRCodeID    = "qr.id"
+	PlaceholderDeepLinkURL = "qr.deepLinkUrl"
+)
+
+// ContentMapping declares how a template assembles the encoded content from QRCode.Data,
+// e.g. {"format": "url", "template": "https://shop.example/p/{sku}?lot={lot}"}.
+type ContentMapping struct {
+	Format    ContentFormat `json:"format"`
+	Template  string        `json:"template,omitempty"`  // Used by "url"
+	Fields    []string      `json:"fields,omitempty"`    // Used by "keyvalue" and "json"; all Data keys when empty
+	Separator string        `json:"separator,omitempty"` // Used by "keyvalue"; defaults to ";"
+}
+
+// Value implements the `driver.Valuer` interface for ContentMapping.
+func (m ContentMapping) Value() (driver.Value, error) {
+	return json.Marshal(m)
+}
+
+// Scan implements the `sql.Scanner` interface for ContentMapping.
+func (m *ContentMapping) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan ContentMapping: expected []byte")
+	}
+
+	return json.Unmarshal(bytes, m)
+}
+
+// QRCodeContentPreview reports what would be encoded for a QR code before it is created.
+type QRCodeContentPreview struct {
+	Content         string                `json:"content"`
+	Length          int                   `json:"length"`    // Encoded length in bytes
+	Version         int                   `json:"version"`   // QR version (1-40)
+	Dimension       int                   `json:"dimension"` // Modules per side
+	ErrorCorrection QRCodeErrorCorrection `json:"errorCorrection"`
+}
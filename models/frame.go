@@ -0,0 +1,75 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// FrameStyle defines the decoration drawn around the symbol.
+type FrameStyle string
+
+const (
+	FrameStyleBorder FrameStyle = "border" // A rectangular outline around the symbol and caption
+	FrameStyleCard   FrameStyle = "card"   // A rounded card the symbol and caption sit on
+	FrameStyleBanner FrameStyle = "banner" // An outline joined to a solid band holding the caption
+)
+
+func (fs FrameStyle) IsValid() bool {
+	switch fs {
+	case FrameStyleBorder, FrameStyleCard, FrameStyleBanner:
+		return true
+	default:
+		return false
+	}
+}
+
+// CaptionPosition places the caption above or below the symbol.
+type CaptionPosition string
+
+const (
+	CaptionPositionTop    CaptionPosition = "top"
+	CaptionPositionBottom CaptionPosition = "bottom" // Default
+)
+
+func (cp CaptionPosition) IsValid() bool {
+	switch cp {
+	case CaptionPositionTop, CaptionPositionBottom:
+		return true
+	default:
+		return false
+	}
+}
+
+// Frame describes a frame and caption drawn around the symbol, e.g.
+// {"style": "banner", "color": "#1A237E", "caption": "Table {tableNumber}", "font": "go-bold"}.
+// The frame is drawn outside the symbol, so the image grows beyond the template size.
+type Frame struct {
+	Style           FrameStyle      `json:"style"`
+	Color           string          `json:"color,omitempty"`           // Outline, card or band colour; defaults to the foreground colour
+	Caption         string          `json:"caption,omitempty"`         // Call to action; {placeholders} are filled from QRCode.Data
+	CaptionPosition CaptionPosition `json:"captionPosition,omitempty"` // Defaults to bottom
+	Font            string          `json:"font,omitempty"`            // Bundled font name, defaults to go-bold
+	FontURL         string          `json:"fontUrl,omitempty"`         // Uploaded TTF or OTF font, overrides Font; set by the server
+	FontSize        float64         `json:"fontSize,omitempty"`        // Pixels, defaults to 1/10 of the symbol size; shrunk to fit
+	TextColor       string          `json:"textColor,omitempty"`       // Defaults to black or white, whichever contrasts more
+}
+
+// Value implements the `driver.Valuer` interface for Frame.
+func (f Frame) Value() (driver.Value, error) {
+	return json.Marshal(f)
+}
+
+// Scan implements the `sql.Scanner` interface for Frame.
+func (f *Frame) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan Frame: expected []byte")
+	}
+
+	return json.Unmarshal(bytes, f)
+}
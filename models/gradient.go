@@ -0,0 +1,60 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// GradientType defines how the colour stops of a gradient are laid out.
+type GradientType string
+
+const (
+	GradientTypeLinear GradientType = "linear" // Along a line through the symbol centre at Angle
+	GradientTypeRadial GradientType = "radial" // Outwards from a centre point
+)
+
+func (gt GradientType) IsValid() bool {
+	switch gt {
+	case GradientTypeLinear, GradientTypeRadial:
+		return true
+	default:
+		return false
+	}
+}
+
+// GradientStop is a colour at a relative position along a gradient.
+type GradientStop struct {
+	Offset float64 `json:"offset"` // 0 to 1
+	Color  string  `json:"color"`  // #RGB, #RGBA, #RRGGBB or #RRGGBBAA
+}
+
+// Gradient describes a fill spanning the symbol area, e.g.
+// {"type": "linear", "angle": 45, "stops": [{"offset": 0, "color": "#000"}, {"offset": 1, "color": "#1a237e"}]}.
+type Gradient struct {
+	Type    GradientType   `json:"type"`
+	Angle   float64        `json:"angle,omitempty"`   // Linear: degrees, 0 = left to right, 90 = top to bottom
+	CenterX *float64       `json:"centerX,omitempty"` // Radial: fraction of the symbol width, defaults to 0.5
+	CenterY *float64       `json:"centerY,omitempty"` // Radial: fraction of the symbol height, defaults to 0.5
+	Radius  float64        `json:"radius,omitempty"`  // Radial: fraction of the symbol width, defaults to reaching the corners
+	Stops   []GradientStop `json:"stops"`
+}
+
+// Value implements the `driver.Valuer` interface for Gradient.
+func (g Gradient) Value() (driver.Value, error) {
+	return json.Marshal(g)
+}
+
+// Scan implements the `sql.Scanner` interface for Gradient.
+func (g *Gradient) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan Gradient: expected []byte")
+	}
+
+	return json.Unmarshal(bytes, g)
+}
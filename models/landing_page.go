@@ -0,0 +1,99 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// LandingLayout selects how a landing page arranges the QR code's data.
+type LandingLayout string
+
+const (
+	LandingLayoutSheet   LandingLayout = "sheet"   // Label and value rows (default)
+	LandingLayoutProduct LandingLayout = "product" // The first image above the title and details
+	LandingLayoutMenu    LandingLayout = "menu"    // Items with their values, e.g. prices, aligned across
+	LandingLayoutCard    LandingLayout = "card"    // Business card: photo, name and contact links
+)
+
+func (l LandingLayout) IsValid() bool {
+	switch l {
+	case LandingLayoutSheet, LandingLayoutProduct, LandingLayoutMenu, LandingLayoutCard:
+		return true
+	default:
+		return false
+	}
+}
+
+// LandingPage describes the page DYNAMIC QR codes of the template open on, built from their Data, e.g.
+// {"layout": "product", "title": "{name}", "fields": ["photo", "price", "manual"], "labels": {"manual": "User manual"}}.
+// Templates without a landing page resolve as before.
+type LandingPage struct {
+	Layout          LandingLayout     `json:"layout,omitempty"`          // Defaults to sheet
+	Title           string            `json:"title,omitempty"`           // {placeholders} are filled from QRCode.Data; defaults to the template name
+	Subtitle        string            `json:"subtitle,omitempty"`        // {placeholders} are filled from QRCode.Data
+	Fields          []string          `json:"fields,omitempty"`          // Fields shown, in order; all Definition fields when empty
+	Labels          map[string]string `json:"labels,omitempty"`          // Field labels, by default derived from the field names
+	AccentColor     string            `json:"accentColor,omitempty"`     // Headings and links; defaults to the foreground colour
+	BackgroundColor string            `json:"backgroundColor,omitempty"` // Defaults to the template background colour
+}
+
+// Value implements the `driver.Valuer` interface for LandingPage.
+func (p LandingPage) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+// Scan implements the `sql.Scanner` interface for LandingPage.
+func (p *LandingPage) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan LandingPage: expected []byte")
+	}
+
+	return json.Unmarshal(bytes, p)
+}
+
+// LandingFieldKind tells how a landing page field is displayed.
+type LandingFieldKind string
+
+const (
+	LandingFieldText  LandingFieldKind = "text"
+	LandingFieldLink  LandingFieldKind = "link" // Web address, email address or phone number
+	LandingFieldImage LandingFieldKind = "image"
+	LandingFieldVideo LandingFieldKind = "video"
+	LandingFieldAudio LandingFieldKind = "audio"
+	LandingFieldFile  LandingFieldKind = "file" // Other media, offered for download
+)
+
+// LandingPageView is the landing page of a QR code, with its Data resolved for display. It is
+// rendered as HTML for browsers and returned as JSON to app clients.
+type LandingPageView struct {
+	QRCodeID string             `json:"qrCodeId"`
+	Layout   LandingLayout      `json:"layout"`
+	Title    string             `json:"title"`
+	Subtitle string             `json:"subtitle,omitempty"`
+	Fields   []LandingPageField `json:"fields"`
+	Colors   LandingPageColors  `json:"colors"`
+}
+
+// LandingPageField is a field of the QR code's data shown on its landing page.
+type LandingPageField struct {
+	Name  string           `json:"name"`
+	Label string           `json:"label"`
+	Kind  LandingFieldKind `json:"kind"`
+	Value interface{}      `json:"value"`           // As stored in QRCode.Data
+	Text  string           `json:"text"`            // Value formatted for display
+	Link  string           `json:"link,omitempty"`  // http(s), mailto: or tel: URL of link fields, public URL of media
+	Media *MediaReference  `json:"media,omitempty"` // Media fields only
+}
+
+// LandingPageColors are the colours of a landing page, as #RRGGBB.
+type LandingPageColors struct {
+	Accent     string `json:"accent"`
+	Background string `json:"background"`
+	Text       string `json:"text"`
+}
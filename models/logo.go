@@ -0,0 +1,55 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// LogoPlate defines the background drawn behind the logo.
+type LogoPlate string
+
+const (
+	LogoPlateNone    LogoPlate = "none"    // The cleared modules show the symbol background
+	LogoPlateSquare  LogoPlate = "square"  // A square plate over the cleared modules
+	LogoPlateRounded LogoPlate = "rounded" // A plate with rounded corners (default)
+)
+
+func (p LogoPlate) IsValid() bool {
+	switch p {
+	case LogoPlateNone, LogoPlateSquare, LogoPlateRounded:
+		return true
+	default:
+		return false
+	}
+}
+
+// LogoOptions describes how the template's logo is placed on the symbol, e.g.
+// {"scale": 0.25, "padding": 1, "plate": "rounded", "plateColor": "#FFFFFF"}.
+// The modules under the logo and its padding are left out rather than drawn over, and error
+// correction is raised above the template's level when the logo covers too much of the symbol.
+type LogoOptions struct {
+	Scale      float64   `json:"scale,omitempty"`      // Logo width or height relative to the symbol, defaults to 0.2
+	Padding    *float64  `json:"padding,omitempty"`    // Modules cleared around the logo, defaults to 1
+	Plate      LogoPlate `json:"plate,omitempty"`      // Defaults to rounded
+	PlateColor string    `json:"plateColor,omitempty"` // Defaults to the background color
+}
+
+// Value implements the `driver.Valuer` interface for LogoOptions.
+func (o LogoOptions) Value() (driver.Value, error) {
+	return json.Marshal(o)
+}
+
+// Scan implements the `sql.Scanner` interface for LogoOptions.
+func (o *LogoOptions) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan LogoOptions: expected []byte")
+	}
+
+	return json.Unmarshal(bytes, o)
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// KeyPurpose is what a managed key is used for. Each purpose has its own versions.
+type KeyPurpose string
+
+const (
+	KeyPurposeSigning    KeyPurpose = "signing"     // Ed25519 keys signed payloads are signed with
+	KeyPurposeEncryption KeyPurpose = "encryption"  // AES-256 keys data is encrypted with
+	KeyPurposeWebhook    KeyPurpose = "webhook"     // HMAC-SHA256 secrets webhook deliveries are signed with
+	KeyPurposeURLSigning KeyPurpose = "url-signing" // HMAC-SHA256 keys signed asset URLs are signed with
+	KeyPurposeUnlock     KeyPurpose = "unlock"      // HMAC-SHA256 keys the cookies of unlocked protected codes are signed with
+)
+
+// KeyPurposes lists every purpose, in the order keys are provisioned.
+var KeyPurposes = []KeyPurpose{KeyPurposeSigning, KeyPurposeEncryption, KeyPurposeWebhook, KeyPurposeURLSigning, KeyPurposeUnlock}
+
+func (p KeyPurpose) IsValid() bool {
+	switch p {
+	case KeyPurposeSigning, KeyPurposeEncryption, KeyPurposeWebhook, KeyPurposeURLSigning, KeyPurposeUnlock:
+		return true
+	default:
+		return false
+	}
+}
+
+// KeyStatus tells whether a key version is used for new signatures and ciphertexts.
+type KeyStatus string
+
+const (
+	KeyStatusActive  KeyStatus = "ACTIVE"  // The current version of its purpose
+	KeyStatusRetired KeyStatus = "RETIRED" // Rotated out; still verifies and decrypts
+)
+
+// ManagedKey is a version of a key, stored encrypted under the master key. Keys are never
+// deleted, so whatever was signed or encrypted with them stays readable.
+type ManagedKey struct {
+	ID                string     `gorm:"primaryKey" json:"id"` // e.g. "signing-v3", or the ID an imported key had
+	Purpose           KeyPurpose `gorm:"not null;uniqueIndex:idx_managed_key_purpose_version" json:"purpose"`
+	Version           int        `gorm:"not null;uniqueIndex:idx_managed_key_purpose_version" json:"version"`
+	Algorithm         string     `gorm:"not null" json:"algorithm"`
+	Status            KeyStatus  `gorm:"not null" json:"status"`
+	EncryptedMaterial []byte     `gorm:"not null" json:"-"` // Nonce and AES-GCM sealed key material
+	CreatedAt         time.Time  `json:"createdAt"`
+	RetiredAt         *time.Time `json:"retiredAt,omitempty"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// MediaReference is the value of a Media field in QRCode.Data, e.g.
+// {"asset": "media/<qrCodeID>/photo/<sha256>.jpg", "contentType": "image/jpeg", "size": 48213,
+// "url": "https://yourdomain.com/qrcodes/<qrCodeID>/media/photo"}.
+// References are written by the media upload endpoint; clients keep or remove them but cannot
+// point them at other assets.
+type MediaReference struct {
+	Asset       string `json:"asset"`       // Storage key, under MediaAssetPrefix of the QR code
+	ContentType string `json:"contentType"` // Sniffed from the uploaded content
+	Size        int64  `json:"size"`        // Bytes
+	URL         string `json:"url"`         // Public URL the media is served from
+}
+
+// MediaAssetPrefix returns the storage prefix of the media uploaded for a QR code.
+func MediaAssetPrefix(qrCodeID string) string {
+	return "media/" + qrCodeID + "/"
+}
+
+// MediaURL returns the public URL the media of a field of the QR code is served from.
+func (q *QRCode) MediaURL(field string) string {
+	return q.ResolverURL() + "/media/" + url.PathEscape(field)
+}
+
+// ParseMediaReference reads the value of a Media field, as decoded from JSON.
+func ParseMediaReference(value interface{}) (*MediaReference, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.New("media value must be a media reference")
+	}
+	var ref MediaReference
+	if err := json.Unmarshal(data, &ref); err != nil || ref.Asset == "" {
+		return nil, errors.New("media value must be a media reference")
+	}
+	return &ref, nil
+}
+
+// BelongsTo reports whether the referenced asset was uploaded for the QR code.
+func (m *MediaReference) BelongsTo(qrCodeID string) bool {
+	return strings.HasPrefix(m.Asset, MediaAssetPrefix(qrCodeID))
+}
+
+// Map returns the reference as a value of QRCode.Data.
+func (m *MediaReference) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"asset":       m.Asset,
+		"contentType": m.ContentType,
+		"size":        m.Size,
+		"url":         m.URL,
+	}
+}
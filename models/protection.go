@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// ProtectionType is the secret a protected QR code asks for before it resolves.
+type ProtectionType string
+
+const (
+	ProtectionTypePIN      ProtectionType = "PIN"      // 4 to 12 digits
+	ProtectionTypePassword ProtectionType = "PASSWORD" // 8 to 128 characters
+)
+
+func (p ProtectionType) IsValid() bool {
+	switch p {
+	case ProtectionTypePIN, ProtectionTypePassword:
+		return true
+	default:
+		return false
+	}
+}
+
+// QRCodeProtection sets the PIN or password of a DYNAMIC QR code, e.g. {"type": "PIN", "secret": "4821"}.
+// Only a hash of the secret is stored.
+type QRCodeProtection struct {
+	Type   ProtectionType `json:"type,omitempty"`
+	Secret string         `json:"secret,omitempty"`
+}
+
+// UnlockRequest is a PIN or password entered to open a protected QR code, from the prompt's
+// form or as JSON.
+type UnlockRequest struct {
+	Secret string `form:"secret" json:"secret" binding:"required,max=128"`
+	Next   string `form:"next" json:"next,omitempty"` // Resolver path to return to, e.g. a GS1 Digital Link
+}
+
+// UnlockCounter counts the PIN or password attempts on a QR code, or from a client, within a
+// throttling window. Clients are identified by a hash of their IP address.
+type UnlockCounter struct {
+	ID          string    `gorm:"primaryKey" json:"-"` // qrcode:<id> or client:<hash>
+	Attempts    int       `gorm:"not null;default:0" json:"-"`
+	WindowStart time.Time `gorm:"not null;index" json:"-"`
+}
+
+// UnlockPageView is the prompt protected QR codes open on.
+type UnlockPageView struct {
+	QRCodeID string
+	Type     ProtectionType
+	Action   string // Where the form is posted
+	Next     string
+	Error    string
+}
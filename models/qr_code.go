@@ -19,35 +19,122 @@ const (
 	QRCodeTypeDynamic QRCodeType = "DYNAMIC"
 )
 
+// Statuses of a QR code. Only ACTIVE codes resolve.
+const (
+	QRCodeStatusActive   = "ACTIVE"
+	QRCodeStatusRedeemed = "REDEEMED" // One-time-use code that was resolved
+)
+
+var (
+	ErrQRCodeNotYetActive = errors.New("QR Code is not active yet")
+	ErrQRCodeExpired      = errors.New("QR Code has expired")
+	ErrQRCodeExhausted    = errors.New("QR Code has reached its scan limit")
+	ErrQRCodeRedeemed     = errors.New("QR Code has already been redeemed")
+	ErrQRCodeInactive     = errors.New("QR Code is not active")
+)
+
 // QRCode represents the QR code entity.
 type QRCode struct {
-	ID            string     `gorm:"primaryKey" json:"id"`
-	Type          QRCodeType `gorm:"not null" json:"type"` // Restricted to STABLE or DYNAMIC
-	CreatedAt     time.Time  `json:"createdAt"`
-	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
-	Status        string     `json:"status"`
-	ScanCount     int64      `json:"scanCount"`
-	ImageURL      string     `json:"imageUrl"`
-	DeepLinkURL   string     `json:"deepLinkUrl"`                                   // Auto-generated deep link
-	ClientAppID   string     `gorm:"not null" json:"clientAppId"`                   // Foreign key to ClientApp
-	TemplateID    string     `gorm:"not null" json:"templateId"`                    // Foreign key to Template
-	ThirdPartyRef string     `json:"thirdPartRef"`                                  // Reference to third-party systems
-	Data          JSONMap    `gorm:"type:jsonb" json:"data"`                        // Custom key-value data
-	ClientApp     ClientApp  `gorm:"foreignKey:ClientAppID;references:ID" json:"-"` // Association with ClientApp
-	Template      Template   `gorm:"foreignKey:TemplateID;references:ID" json:"-"`  // Association with Template
+	ID             string         `gorm:"primaryKey" json:"id"`
+	Type           QRCodeType     `gorm:"not null" json:"type"` // Restricted to STABLE or DYNAMIC
+	CreatedAt      time.Time      `json:"createdAt"`
+	ActivatesAt    *time.Time     `json:"activatesAt,omitempty"` // Resolves from then on
+	ExpiresAt      *time.Time     `json:"expiresAt,omitempty"`
+	Status         string         `json:"status"`
+	ScanCount      int64          `json:"scanCount"`
+	MaxScans       int64          `gorm:"not null;default:0" json:"maxScans,omitempty"`       // Resolves this many times, 0 for no limit
+	OneTimeUse     bool           `gorm:"not null;default:false" json:"oneTimeUse,omitempty"` // Becomes REDEEMED when first resolved
+	ImageURL       string         `json:"imageUrl"`
+	DeepLinkURL    string         `gorm:"index" json:"deepLinkUrl"`                                                                           // Auto-generated deep link
+	ShortID        *string        `gorm:"uniqueIndex" json:"shortId,omitempty"`                                                               // Base62 ID of the short resolver path
+	Slug           *string        `gorm:"uniqueIndex:idx_qr_codes_client_app_slug" json:"slug,omitempty"`                                     // Custom path under the client app's slug
+	ClientAppID    string         `gorm:"not null;uniqueIndex:idx_qr_codes_client_app_slug,priority:1" json:"clientAppId"`                    // Foreign key to ClientApp
+	TemplateID     string         `gorm:"not null" json:"templateId"`                                                                         // Foreign key to Template
+	ThirdPartyRef  string         `json:"thirdPartRef"`                                                                                       // Reference to third-party systems
+	Data           JSONMap        `gorm:"type:jsonb" json:"data"`                                                                             // Custom key-value data
+	ContentType    QRContentType  `json:"contentType,omitempty"`                                                                              // Kind of payload encoded in the symbol
+	Content        *QRContent     `gorm:"type:jsonb" json:"content,omitempty"`                                                                // Typed payload for non-URL content types
+	DigitalLink    string         `gorm:"uniqueIndex:idx_qr_codes_digital_link_unique,where:digital_link <> ''" json:"digitalLink,omitempty"` // Canonical GS1 Digital Link path for GS1 content
+	Routing        *Routing       `gorm:"type:jsonb" json:"routing,omitempty"`                                                                // Where scans of DYNAMIC codes are redirected
+	Protection     ProtectionType `gorm:"not null;default:''" json:"protection,omitempty"`                                                    // PIN or password asked before resolving
+	ProtectionHash string         `json:"-"`                                                                                                  // bcrypt hash of the PIN or password
+	ClientApp      ClientApp      `gorm:"foreignKey:ClientAppID;references:ID" json:"-"`                                                      // Association with ClientApp
+	Template       Template       `gorm:"foreignKey:TemplateID;references:ID" json:"-"`                                                       // Association with Template
 }
 
 // BeforeCreate is a GORM hook that runs before a new QRCode is inserted into the database.
 func (q *QRCode) BeforeCreate(tx *gorm.DB) (err error) {
 	// Auto-generate the DeepLinkURL if it is not already set
 	if q.DeepLinkURL == "" {
-		protocol := getEnv("DEEPLINK_PROTOCOL", "https")  // Default to "https" if not set
-		host := getEnv("DEEPLINK_HOST", "yourdomain.com") // Default to "yourdomain.com" if not set
-		q.DeepLinkURL = fmt.Sprintf("%s://%s/qrcodes/%s", protocol, host, q.ID)
+		q.DeepLinkURL = q.BuildDeepLinkURL()
 	}
 	return nil
 }
 
+// CheckResolvable reports why the QR code cannot be resolved at now, or nil when it can.
+func (q *QRCode) CheckResolvable(now time.Time) error {
+	if err := q.CheckRedeemable(now); err != nil {
+		return err
+	}
+	if q.MaxScans > 0 && q.ScanCount >= q.MaxScans {
+		return ErrQRCodeExhausted
+	}
+	return nil
+}
+
+// CheckRedeemable reports why staff cannot redeem the QR code at the given time, or nil when
+// they can. Unlike resolving, redeeming does not count against the scan limit.
+func (q *QRCode) CheckRedeemable(at time.Time) error {
+	switch {
+	case q.Status == QRCodeStatusRedeemed:
+		return ErrQRCodeRedeemed
+	case q.Status != QRCodeStatusActive:
+		return ErrQRCodeInactive
+	default:
+		return q.CheckValidAt(at)
+	}
+}
+
+// CheckValidAt reports whether the time falls before the QR code activates or after it expires,
+// or nil when it falls within its validity window, whatever its status.
+func (q *QRCode) CheckValidAt(at time.Time) error {
+	switch {
+	case q.ActivatesAt != nil && at.Before(*q.ActivatesAt):
+		return ErrQRCodeNotYetActive
+	case q.ExpiresAt != nil && !at.Before(*q.ExpiresAt):
+		return ErrQRCodeExpired
+	default:
+		return nil
+	}
+}
+
+// BuildDeepLinkURL returns the deep link under which the QR code is resolved. Codes with a
+// short ID get the short link, which keeps the symbol small.
+func (q *QRCode) BuildDeepLinkURL() string {
+	if q.ShortID != nil {
+		return fmt.Sprintf("%s/q/%s", DeepLinkBaseURL(), *q.ShortID)
+	}
+	return q.ResolverURL()
+}
+
+// ResolverURL returns the canonical URL of the QR code on the resolver, by its ID.
+func (q *QRCode) ResolverURL() string {
+	return fmt.Sprintf("%s/qrcodes/%s", DeepLinkBaseURL(), q.ID)
+}
+
+// SlugDeepLinkURL returns the deep link of a QR code with a custom slug, under the slug of its
+// client app.
+func SlugDeepLinkURL(clientAppSlug, slug string) string {
+	return fmt.Sprintf("%s/q/%s/%s", DeepLinkBaseURL(), clientAppSlug, slug)
+}
+
+// DeepLinkBaseURL returns the scheme and host that serve the public resolver.
+func DeepLinkBaseURL() string {
+	protocol := getEnv("DEEPLINK_PROTOCOL", "https")  // Default to "https" if not set
+	host := getEnv("DEEPLINK_HOST", "yourdomain.com") // Default to "yourdomain.com" if not set
+	return fmt.Sprintf("%s://%s", protocol, host)
+}
+
 // getEnv retrieves the value of an environment variable or returns a default value if not set.
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
@@ -59,20 +146,35 @@ func getEnv(key, defaultValue string) string {
 // QRCodeCreateRequest represents the request structure for creating a QR code.
 type QRCodeCreateRequest struct {
 	Type          QRCodeType             `json:"type" binding:"required,oneof=STABLE DYNAMIC"` // Restricted to STABLE or DYNAMIC
+	ActivatesAt   *time.Time             `json:"activatesAt,omitempty"`
 	ExpiresAt     *time.Time             `json:"expiresAt,omitempty"`
+	MaxScans      int64                  `json:"maxScans,omitempty"`
+	OneTimeUse    bool                   `json:"oneTimeUse,omitempty"`
 	TemplateID    string                 `json:"templateId" binding:"required"`
 	ClientAppID   string                 `json:"clientAppId" binding:"required"`
 	ThirdPartyRef string                 `json:"third_party_ref"`
-	Data          map[string]interface{} `json:"data"` // Custom key-value data
+	Data          map[string]interface{} `json:"data"`                  // Custom key-value data
+	ContentType   QRContentType          `json:"contentType,omitempty"` // Defaults to URL
+	Content       *QRContent             `json:"content,omitempty"`     // Typed payload for the content type
+	Routing       *Routing               `json:"routing,omitempty"`     // DYNAMIC codes only
+	Protection    *QRCodeProtection      `json:"protection,omitempty"`  // DYNAMIC codes only
+	Slug          string                 `json:"slug,omitempty"`        // Custom path; requires a client app slug
 }
 
 // QRCodeUpdateRequest represents the request structure for updating a QR code.
 type QRCodeUpdateRequest struct {
 	Type          QRCodeType             `json:"type,omitempty" binding:"omitempty,oneof=STABLE DYNAMIC"` // Restricted to STABLE or DYNAMIC
+	ActivatesAt   *time.Time             `json:"activatesAt,omitempty"`
 	ExpiresAt     *time.Time             `json:"expiresAt,omitempty"`
-	Status        string                 `json:"status,omitempty"`
+	MaxScans      *int64                 `json:"maxScans,omitempty"` // 0 removes the limit
+	OneTimeUse    *bool                  `json:"oneTimeUse,omitempty"`
+	Status        string                 `json:"status,omitempty"` // ACTIVE reactivates a redeemed code
 	ThirdPartyRef string                 `json:"third_party_ref,omitempty"`
-	Data          map[string]interface{} `json:"data,omitempty"` // Custom key-value data
+	Data          map[string]interface{} `json:"data,omitempty"`        // Custom key-value data
+	ContentType   QRContentType          `json:"contentType,omitempty"` // Requires Content when set
+	Content       *QRContent             `json:"content,omitempty"`
+	Routing       *Routing               `json:"routing,omitempty"`    // Replaces the routing; {} removes it
+	Protection    *QRCodeProtection      `json:"protection,omitempty"` // Replaces the PIN or password; {} removes it
 }
 
 // QRCodeResponse represents the response structure for a QR code.
@@ -80,15 +182,34 @@ type QRCodeResponse struct {
 	ID            string                 `json:"id"`
 	Type          QRCodeType             `json:"type"`
 	CreatedAt     time.Time              `json:"createdAt"`
+	ActivatesAt   *time.Time             `json:"activatesAt,omitempty"`
 	ExpiresAt     *time.Time             `json:"expiresAt,omitempty"`
 	Status        string                 `json:"status"`
 	ScanCount     int64                  `json:"scanCount"`
+	MaxScans      int64                  `json:"maxScans,omitempty"`
+	OneTimeUse    bool                   `json:"oneTimeUse,omitempty"`
 	ImageURL      string                 `json:"imageUrl"`
 	DeepLinkURL   string                 `json:"deepLinkUrl"`
+	ShortID       *string                `json:"shortId,omitempty"`
+	Slug          *string                `json:"slug,omitempty"`
 	ClientAppID   string                 `json:"clientAppId"`
 	TemplateID    string                 `json:"templateId"`
 	ThirdPartyRef string                 `json:"third_party_ref"`
 	Data          map[string]interface{} `json:"data"` // Custom key-value data
+	ContentType   QRContentType          `json:"contentType,omitempty"`
+	Content       *QRContent             `json:"content,omitempty"`
+	Routing       *Routing               `json:"routing,omitempty"`
+	Protection    ProtectionType         `json:"protection,omitempty"`
+}
+
+// QRCodeDecodeResult is a QR code read from an uploaded image, with the matching record when
+// the code was issued by the caller's client app.
+type QRCodeDecodeResult struct {
+	Content         string                `json:"content"`
+	Version         int                   `json:"version"`
+	ErrorCorrection QRCodeErrorCorrection `json:"errorCorrection"`
+	ErrorsCorrected int                   `json:"errorsCorrected"` // Damaged codewords restored while reading
+	QRCode          *QRCode               `json:"qrCode,omitempty"`
 }
 
 // JSONMap is a custom type to handle JSON fields in the database.
@@ -0,0 +1,220 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// QRContentType defines what kind of payload a QR code encodes.
+type QRContentType string
+
+const (
+	QRContentTypeURL    QRContentType = "URL"    // Template content mapping or deep link (default)
+	QRContentTypeVCard  QRContentType = "VCARD"  // Contact card, vCard 3.0 or 4.0
+	QRContentTypeMeCard QRContentType = "MECARD" // Contact card, MeCard
+	QRContentTypeWiFi   QRContentType = "WIFI"   // WiFi network join string
+	QRContentTypeEmail  QRContentType = "EMAIL"  // mailto URI with subject and body
+	QRContentTypeSMS    QRContentType = "SMS"    // sms URI with message body
+	QRContentTypeTel    QRContentType = "TEL"    // tel URI
+	QRContentTypeGeo    QRContentType = "GEO"    // geo URI
+	QRContentTypeEvent  QRContentType = "EVENT"  // iCalendar VEVENT
+	QRContentTypeEPC    QRContentType = "EPC"    // EPC069-12 SEPA credit transfer
+	QRContentTypeEMVCo  QRContentType = "EMVCO"  // EMVCo merchant-presented payment
+	QRContentTypeGS1    QRContentType = "GS1"    // GS1 Digital Link URI
+)
+
+func (ct QRContentType) IsValid() bool {
+	switch ct {
+	case QRContentTypeURL, QRContentTypeVCard, QRContentTypeMeCard, QRContentTypeWiFi, QRContentTypeEmail,
+		QRContentTypeSMS, QRContentTypeTel, QRContentTypeGeo, QRContentTypeEvent, QRContentTypeEPC, QRContentTypeEMVCo,
+		QRContentTypeGS1:
+		return true
+	default:
+		return false
+	}
+}
+
+// WiFi security types.
+const (
+	WiFiSecurityWPA    = "WPA"
+	WiFiSecurityWEP    = "WEP"
+	WiFiSecurityNoPass = "nopass"
+)
+
+// QRContent holds the typed payload of a QR code. Only the member matching
+// the QR code's ContentType is used.
+type QRContent struct {
+	Contact *ContactContent `json:"contact,omitempty"` // VCARD and MECARD
+	WiFi    *WiFiContent    `json:"wifi,omitempty"`
+	Email   *EmailContent   `json:"email,omitempty"`
+	SMS     *SMSContent     `json:"sms,omitempty"`
+	Tel     *TelContent     `json:"tel,omitempty"`
+	Geo     *GeoContent     `json:"geo,omitempty"`
+	Event   *EventContent   `json:"event,omitempty"`
+	SEPA    *SEPAPayment    `json:"sepa,omitempty"`  // EPC
+	EMVCo   *EMVCoPayment   `json:"emvco,omitempty"` // EMVCO
+	GS1     *GS1DigitalLink `json:"gs1,omitempty"`
+}
+
+// ContactContent describes a contact card.
+type ContactContent struct {
+	Version      string          `json:"version,omitempty"` // vCard version: "3.0" (default) or "4.0"
+	FirstName    string          `json:"firstName"`
+	LastName     string          `json:"lastName"`
+	Organization string          `json:"organization,omitempty"`
+	Title        string          `json:"title,omitempty"`
+	Phones       []ContactPhone  `json:"phones,omitempty"`
+	Emails       []string        `json:"emails,omitempty"`
+	URL          string          `json:"url,omitempty"`
+	Address      *ContactAddress `json:"address,omitempty"`
+	Birthday     string          `json:"birthday,omitempty"` // YYYY-MM-DD
+	Note         string          `json:"note,omitempty"`
+}
+
+// ContactPhone is a phone number with an optional type such as "cell", "work" or "home".
+type ContactPhone struct {
+	Type   string `json:"type,omitempty"`
+	Number string `json:"number"`
+}
+
+// ContactAddress is a postal address.
+type ContactAddress struct {
+	Street     string `json:"street,omitempty"`
+	City       string `json:"city,omitempty"`
+	Region     string `json:"region,omitempty"`
+	PostalCode string `json:"postalCode,omitempty"`
+	Country    string `json:"country,omitempty"`
+}
+
+// WiFiContent describes a WiFi network to join.
+type WiFiContent struct {
+	SSID     string `json:"ssid"`
+	Password string `json:"password,omitempty"`
+	Security string `json:"security,omitempty"` // WPA (default), WEP or nopass
+	Hidden   bool   `json:"hidden,omitempty"`
+}
+
+// EmailContent describes an email to compose.
+type EmailContent struct {
+	To      string `json:"to"`
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body,omitempty"`
+}
+
+// SMSContent describes a text message to compose.
+type SMSContent struct {
+	Number  string `json:"number"`
+	Message string `json:"message,omitempty"`
+}
+
+// TelContent describes a phone number to call.
+type TelContent struct {
+	Number string `json:"number"`
+}
+
+// GeoContent describes a location.
+type GeoContent struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Altitude  *float64 `json:"altitude,omitempty"`
+	Query     string   `json:"query,omitempty"` // Optional label or search query
+}
+
+// EventContent describes a calendar event.
+type EventContent struct {
+	Summary     string     `json:"summary"`
+	Description string     `json:"description,omitempty"`
+	Location    string     `json:"location,omitempty"`
+	Start       time.Time  `json:"start"`
+	End         *time.Time `json:"end,omitempty"`
+	AllDay      bool       `json:"allDay,omitempty"`
+}
+
+// SEPAPayment describes an EPC069-12 SEPA credit transfer in euro.
+type SEPAPayment struct {
+	Name           string   `json:"name"`                     // Beneficiary name
+	IBAN           string   `json:"iban"`                     // Beneficiary account
+	BIC            string   `json:"bic,omitempty"`            // Beneficiary bank, optional within the EEA
+	Amount         *float64 `json:"amount,omitempty"`         // EUR 0.01 to 999999999.99
+	Purpose        string   `json:"purpose,omitempty"`        // ISO 20022 purpose code, e.g. GDDS
+	Reference      string   `json:"reference,omitempty"`      // ISO 11649 creditor reference (RF...)
+	RemittanceText string   `json:"remittanceText,omitempty"` // Unstructured remittance, exclusive with Reference
+	Information    string   `json:"information,omitempty"`    // Beneficiary to originator information
+}
+
+// Point of initiation methods of an EMVCo payload.
+const (
+	EMVCoInitiationStatic  = "STATIC"  // Reusable code, amount entered by the payer
+	EMVCoInitiationDynamic = "DYNAMIC" // Single transaction, usually with an amount
+)
+
+// EMVCoPayment describes an EMVCo merchant-presented mode payment payload.
+type EMVCoPayment struct {
+	PointOfInitiation    string                 `json:"pointOfInitiation,omitempty"` // STATIC (default) or DYNAMIC
+	MerchantAccounts     []EMVCoMerchantAccount `json:"merchantAccounts"`
+	MerchantCategoryCode string                 `json:"merchantCategoryCode"` // ISO 18245, 4 digits
+	Currency             string                 `json:"currency"`             // ISO 4217 numeric, e.g. "978"
+	Amount               *float64               `json:"amount,omitempty"`
+	CountryCode          string                 `json:"countryCode"` // ISO 3166-1 alpha-2
+	MerchantName         string                 `json:"merchantName"`
+	MerchantCity         string                 `json:"merchantCity"`
+	PostalCode           string                 `json:"postalCode,omitempty"`
+	AdditionalData       *EMVCoAdditionalData   `json:"additionalData,omitempty"`
+}
+
+// EMVCoMerchantAccount is a merchant account information object (IDs 02-51).
+// IDs 02-25 carry a primitive Value; IDs 26-51 are templates with a GUID and sub-fields.
+type EMVCoMerchantAccount struct {
+	ID     string       `json:"id"`
+	Value  string       `json:"value,omitempty"`
+	GUID   string       `json:"guid,omitempty"`
+	Fields []EMVCoField `json:"fields,omitempty"` // Sub-field IDs 01-99
+}
+
+// EMVCoField is a single ID/value data object.
+type EMVCoField struct {
+	ID    string `json:"id"`
+	Value string `json:"value"`
+}
+
+// EMVCoAdditionalData holds the additional data field template (ID 62).
+type EMVCoAdditionalData struct {
+	BillNumber     string `json:"billNumber,omitempty"`
+	MobileNumber   string `json:"mobileNumber,omitempty"`
+	StoreLabel     string `json:"storeLabel,omitempty"`
+	LoyaltyNumber  string `json:"loyaltyNumber,omitempty"`
+	ReferenceLabel string `json:"referenceLabel,omitempty"`
+	CustomerLabel  string `json:"customerLabel,omitempty"`
+	TerminalLabel  string `json:"terminalLabel,omitempty"`
+	Purpose        string `json:"purpose,omitempty"`
+}
+
+// GS1DigitalLink describes a product identified by GS1 application identifiers.
+type GS1DigitalLink struct {
+	Domain string `json:"domain,omitempty"` // Resolver domain, defaults to the deep link host
+	GTIN   string `json:"gtin"`             // AI 01: GTIN-8, -12, -13 or -14
+	Batch  string `json:"batch,omitempty"`  // AI 10: batch/lot number
+	Serial string `json:"serial,omitempty"` // AI 21: serial number
+	Expiry string `json:"expiry,omitempty"` // AI 17: expiration date, YYYY-MM-DD
+}
+
+// Value implements the `driver.Valuer` interface for QRContent.
+func (c QRContent) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+// Scan implements the `sql.Scanner` interface for QRContent.
+func (c *QRContent) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan QRContent: expected []byte")
+	}
+
+	return json.Unmarshal(bytes, c)
+}
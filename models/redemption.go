@@ -0,0 +1,92 @@
+package models
+
+import "time"
+
+// StaffDevice is a door scanner or phone that staff of a client app check tickets and vouchers
+// in with. It authenticates with the token issued when it was registered.
+type StaffDevice struct {
+	ID          string     `gorm:"primaryKey" json:"id"`
+	ClientAppID string     `gorm:"not null;index" json:"clientAppId"`
+	Name        string     `json:"name"`
+	TokenHash   string     `gorm:"not null" json:"-"` // SHA-256 of the token secret, hex encoded
+	Active      bool       `gorm:"not null;default:true" json:"active"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastSeenAt  *time.Time `json:"lastSeenAt,omitempty"`
+}
+
+// StaffDeviceCreateRequest registers a staff device.
+type StaffDeviceCreateRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// StaffDeviceCreateResponse returns the token of a new staff device. The token is not stored
+// and cannot be retrieved again; devices send it as "Authorization: Bearer <token>".
+type StaffDeviceCreateResponse struct {
+	Device StaffDevice `json:"device"`
+	Token  string      `json:"token"`
+}
+
+// RedemptionStatus is the outcome of a redemption attempt.
+type RedemptionStatus string
+
+const (
+	RedemptionStatusRedeemed        RedemptionStatus = "REDEEMED"
+	RedemptionStatusAlreadyRedeemed RedemptionStatus = "ALREADY_REDEEMED" // Another redemption came first
+	RedemptionStatusRejected        RedemptionStatus = "REJECTED"         // Unknown, foreign, inactive or expired code
+)
+
+// Redemption records a check-in of a QR code by a staff device. Attempts on codes that were
+// already redeemed are recorded too, as ALREADY_REDEEMED.
+type Redemption struct {
+	ID                 string           `gorm:"primaryKey" json:"id"`
+	QRCodeID           string           `gorm:"not null;index" json:"qrCodeId"`
+	ClientAppID        string           `gorm:"not null;index" json:"clientAppId"`
+	DeviceID           string           `gorm:"not null;uniqueIndex:idx_redemption_device_client_id" json:"deviceId"`
+	ClientRedemptionID *string          `gorm:"uniqueIndex:idx_redemption_device_client_id" json:"clientRedemptionId,omitempty"` // Device's ID for offline redemptions
+	Status             RedemptionStatus `gorm:"not null" json:"status"`
+	Operator           string           `json:"operator,omitempty"`
+	Latitude           *float64         `json:"latitude,omitempty"`
+	Longitude          *float64         `json:"longitude,omitempty"`
+	Location           string           `json:"location,omitempty"`         // e.g. "Gate B"
+	RedeemedAt         time.Time        `gorm:"not null" json:"redeemedAt"` // When the code was scanned at the door
+	ReceivedAt         time.Time        `gorm:"not null" json:"receivedAt"` // When the service received it
+	Offline            bool             `json:"offline,omitempty"`
+}
+
+// RedemptionRequest is a code scanned by a staff device.
+type RedemptionRequest struct {
+	Payload   string   `json:"payload" binding:"required"` // Content read from the QR code
+	Operator  string   `json:"operator,omitempty" binding:"max=100"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	Location  string   `json:"location,omitempty" binding:"max=200"`
+}
+
+// OfflineRedemption is a code a staff device scanned while offline and uploads later.
+type OfflineRedemption struct {
+	RedemptionRequest
+	ClientRedemptionID string    `json:"clientRedemptionId" binding:"required,max=100"` // Uploading it again returns the first result
+	RedeemedAt         time.Time `json:"redeemedAt" binding:"required"`
+}
+
+// RedemptionSyncRequest uploads the redemptions of a staff device that lost connectivity.
+type RedemptionSyncRequest struct {
+	Redemptions []OfflineRedemption `json:"redemptions" binding:"required,max=500,dive"`
+}
+
+// RedemptionResult reports the outcome of a redemption attempt.
+type RedemptionResult struct {
+	Status             RedemptionStatus `json:"status"`
+	ClientRedemptionID string           `json:"clientRedemptionId,omitempty"`
+	QRCodeID           string           `json:"qrCodeId,omitempty"`
+	Redemption         *Redemption      `json:"redemption,omitempty"`
+	Original           *Redemption      `json:"original,omitempty"`   // First redemption, for ALREADY_REDEEMED
+	Superseded         *Redemption      `json:"superseded,omitempty"` // Later redemption an earlier offline one took over from
+	Code               string           `json:"code,omitempty"`       // Reason for REJECTED, as returned by the resolver
+	Error              string           `json:"error,omitempty"`
+}
+
+// RedemptionSyncResponse reports the outcome of each uploaded redemption, in upload order.
+type RedemptionSyncResponse struct {
+	Results []RedemptionResult `json:"results"`
+}
@@ -0,0 +1,278 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DeviceOS is the operating system of the device scanning a QR code.
+type DeviceOS string
+
+const (
+	DeviceOSIOS     DeviceOS = "ios"
+	DeviceOSAndroid DeviceOS = "android"
+	DeviceOSDesktop DeviceOS = "desktop"
+)
+
+func (o DeviceOS) IsValid() bool {
+	switch o {
+	case DeviceOSIOS, DeviceOSAndroid, DeviceOSDesktop:
+		return true
+	default:
+		return false
+	}
+}
+
+// Weekday is a day of the week as used by routing rules: mon, tue, wed, thu, fri, sat or sun.
+type Weekday string
+
+var weekdays = map[Weekday]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func (d Weekday) IsValid() bool {
+	_, ok := weekdays[d]
+	return ok
+}
+
+// TimeWindow is a daily window of local time, e.g. {"from": "09:00", "to": "18:00"}. Windows
+// ending before they start run past midnight.
+type TimeWindow struct {
+	From string `json:"from"` // HH:MM, inclusive
+	To   string `json:"to"`   // HH:MM, exclusive
+}
+
+// Minutes returns the start and end of the window in minutes after midnight.
+func (w *TimeWindow) Minutes() (from, to int, err error) {
+	if from, err = clockMinutes(w.From); err != nil {
+		return 0, 0, fmt.Errorf("invalid from: %w", err)
+	}
+	if to, err = clockMinutes(w.To); err != nil {
+		return 0, 0, fmt.Errorf("invalid to: %w", err)
+	}
+	return from, to, nil
+}
+
+func clockMinutes(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, errors.New("must be a time of day as HH:MM")
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// RoutingRule sends the scans matching all of its conditions to Destination, e.g.
+// {"os": ["ios"], "destination": "https://apps.apple.com/app/id123"}. Conditions left out match any scan.
+type RoutingRule struct {
+	Name         string            `json:"name,omitempty"`         // Identifies the rule to the client app
+	OS           []DeviceOS        `json:"os,omitempty"`           // Any of the operating systems
+	Languages    []string          `json:"languages,omitempty"`    // Preferred language, e.g. "pt" matches pt-BR and pt-PT
+	TimeWindow   *TimeWindow       `json:"timeWindow,omitempty"`   // Local time of day
+	Weekdays     []Weekday         `json:"weekdays,omitempty"`     // Any of the local days of the week
+	Timezone     string            `json:"timezone,omitempty"`     // IANA name for TimeWindow and Weekdays, defaults to UTC
+	MinScanCount int64             `json:"minScanCount,omitempty"` // Scans from this one on, counting the current scan
+	MaxScanCount int64             `json:"maxScanCount,omitempty"` // Scans up to this one, e.g. 100 for the first hundred
+	Query        map[string]string `json:"query,omitempty"`        // Query parameters of the scanned URL; "*" matches any value
+	Destination  string            `json:"destination"`
+}
+
+// Variant is one of the destinations an A/B test splits scans between, e.g.
+// {"name": "b", "url": "https://example.com/spring-b", "weight": 50}.
+type Variant struct {
+	Name   string `json:"name"` // Reported with the scans and conversions of the variant
+	URL    string `json:"url"`
+	Weight int    `json:"weight"` // Percentage of new scanners; the weights add up to 100
+}
+
+// Routing is the ordered list of rules a DYNAMIC QR code redirects its scans with. The first
+// matching rule wins; scans matching none are split between Variants, or else go to
+// DefaultURL, or resolve as if there were no routing when it is empty.
+type Routing struct {
+	Rules      []RoutingRule `json:"rules"`
+	Variants   []Variant     `json:"variants,omitempty"`
+	DefaultURL string        `json:"defaultUrl,omitempty"`
+}
+
+// Route is where a scan is sent.
+type Route struct {
+	Destination string       // Empty when the scan has none
+	Rule        *RoutingRule // Rule that matched, if any
+	Variant     *Variant     // Variant the scanner was assigned, if any
+}
+
+// Value implements the `driver.Valuer` interface for Routing.
+func (r Routing) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+// Scan implements the `sql.Scanner` interface for Routing.
+func (r *Routing) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan Routing: expected []byte")
+	}
+
+	return json.Unmarshal(bytes, r)
+}
+
+// ScanContext describes a scan for routing.
+type ScanContext struct {
+	OS        DeviceOS // Empty for devices other than iOS, Android and desktop browsers
+	Language  string   // Preferred language tag from Accept-Language, e.g. "pt-BR"
+	Time      time.Time
+	ScanCount int64 // Including this scan
+	Query     url.Values
+	VisitorID string // Stable for a scanner, see utils.VisitorID
+	Variant   string // Variant the scanner was assigned before
+}
+
+// Resolve returns where a scan is sent.
+func (r *Routing) Resolve(scan ScanContext) Route {
+	for i := range r.Rules {
+		if r.Rules[i].Matches(scan) {
+			return Route{Destination: r.Rules[i].Destination, Rule: &r.Rules[i]}
+		}
+	}
+	if variant := r.assignVariant(scan); variant != nil {
+		return Route{Destination: variant.URL, Variant: variant}
+	}
+	return Route{Destination: r.DefaultURL}
+}
+
+// assignVariant keeps scanners on the variant they were assigned before, and spreads new ones
+// by weight on a hash of their visitor ID, so they land on the same variant when scanning again.
+func (r *Routing) assignVariant(scan ScanContext) *Variant {
+	total := 0
+	for i, variant := range r.Variants {
+		if variant.Name == scan.Variant && variant.Weight > 0 {
+			return &r.Variants[i]
+		}
+		total += variant.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(scan.VisitorID))
+	bucket := int(h.Sum64() % uint64(total))
+	for i, variant := range r.Variants {
+		if bucket < variant.Weight {
+			return &r.Variants[i]
+		}
+		bucket -= variant.Weight
+	}
+	return nil
+}
+
+// Matches reports whether a scan meets all of the rule's conditions.
+func (r *RoutingRule) Matches(scan ScanContext) bool {
+	if len(r.OS) > 0 && !containsOS(r.OS, scan.OS) {
+		return false
+	}
+	if len(r.Languages) > 0 && !matchesLanguage(r.Languages, scan.Language) {
+		return false
+	}
+	if r.MinScanCount > 0 && scan.ScanCount < r.MinScanCount {
+		return false
+	}
+	if r.MaxScanCount > 0 && scan.ScanCount > r.MaxScanCount {
+		return false
+	}
+	for key, want := range r.Query {
+		values, ok := scan.Query[key]
+		if !ok || (want != "*" && !containsString(values, want)) {
+			return false
+		}
+	}
+
+	if r.TimeWindow == nil && len(r.Weekdays) == 0 {
+		return true
+	}
+	location, err := r.location()
+	if err != nil {
+		return false // Rejected when the rule was saved
+	}
+	local := scan.Time.In(location)
+	if len(r.Weekdays) > 0 {
+		found := false
+		for _, day := range r.Weekdays {
+			if weekdays[day] == local.Weekday() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.TimeWindow != nil {
+		from, to, err := r.TimeWindow.Minutes()
+		if err != nil {
+			return false
+		}
+		now := local.Hour()*60 + local.Minute()
+		if from <= to {
+			return now >= from && now < to
+		}
+		return now >= from || now < to
+	}
+	return true
+}
+
+// location returns the time zone of the rule's time conditions.
+func (r *RoutingRule) location() (*time.Location, error) {
+	if r.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(r.Timezone)
+}
+
+// ValidateTimezone checks that the rule's time zone is known.
+func (r *RoutingRule) ValidateTimezone() error {
+	if _, err := r.location(); err != nil {
+		return fmt.Errorf("unknown timezone: %s", r.Timezone)
+	}
+	return nil
+}
+
+func containsOS(list []DeviceOS, os DeviceOS) bool {
+	for _, o := range list {
+		if o == os {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesLanguage reports whether a language tag is one of tags or a regional variant of one.
+func matchesLanguage(tags []string, language string) bool {
+	language = strings.ToLower(language)
+	for _, tag := range tags {
+		tag = strings.ToLower(tag)
+		if language == tag || strings.HasPrefix(language, tag+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// ScanEvent records a scan sent to an A/B test variant, and whether it converted.
+type ScanEvent struct {
+	ID          string     `gorm:"primaryKey" json:"id"`
+	QRCodeID    string     `gorm:"not null;index" json:"qrCodeId"`
+	Variant     string     `gorm:"index" json:"variant"`
+	VisitorID   string     `json:"-"` // Hash identifying the scanner, for counting unique visitors
+	CreatedAt   time.Time  `json:"createdAt"`
+	ConvertedAt *time.Time `json:"convertedAt,omitempty"`
+}
+
+// ConversionRequest reports that a scan converted on the destination it was sent to. Variant
+// destinations receive the scan ID in the qrScanId query parameter.
+type ConversionRequest struct {
+	ScanID string `json:"scanId" binding:"required"`
+}
+
+// VariantStats compares the scans and conversions of an A/B test variant.
+type VariantStats struct {
+	Variant        string  `json:"variant"`
+	Weight         int     `json:"weight"` // Current weight, 0 for variants no longer in the routing
+	Scans          int64   `json:"scans"`
+	Visitors       int64   `json:"visitors"`
+	Conversions    int64   `json:"conversions"`
+	ConversionRate float64 `json:"conversionRate"` // Conversions per scan
+}
+
+// VariantReport is the A/B test report of a QR code.
+type VariantReport struct {
+	QRCodeID string         `json:"qrCodeId"`
+	Variants []VariantStats `json:"variants"`
+}
@@ -0,0 +1,37 @@
+package models
+
+// ScannabilitySeverity tells whether a scannability issue blocks the template.
+type ScannabilitySeverity string
+
+const (
+	ScannabilityWarning ScannabilitySeverity = "warning" // The code may be hard to read for some scanners
+	ScannabilityError   ScannabilitySeverity = "error"   // The code is not expected to scan; the template is rejected
+)
+
+// ScannabilityIssue is a single finding of a scannability check.
+type ScannabilityIssue struct {
+	Code     string               `json:"code"` // e.g. LOW_CONTRAST, INVERTED_COLORS, LOGO_COVERAGE, DECODE_FAILED
+	Severity ScannabilitySeverity `json:"severity"`
+	Message  string               `json:"message"`
+}
+
+// ScannabilityReport is the result of checking a template's styling for scannability.
+type ScannabilityReport struct {
+	Scannable               bool                  `json:"scannable"`               // False when any issue is an error
+	Contrast                float64               `json:"contrast"`                // Lowest WCAG contrast ratio, 1 to 21
+	Inverted                bool                  `json:"inverted"`                // Light modules on a dark background
+	LogoCoverage            float64               `json:"logoCoverage"`            // Fraction of the modules cleared for the logo
+	ErrorCorrection         QRCodeErrorCorrection `json:"errorCorrection"`         // Level codes are encoded with, raised for the logo if needed
+	ErrorCorrectionCapacity float64               `json:"errorCorrectionCapacity"` // Fraction of codewords the EC level can restore
+	Issues                  []ScannabilityIssue   `json:"issues"`
+}
+
+// HasErrors reports whether the report contains an issue of error severity.
+func (r *ScannabilityReport) HasErrors() bool {
+	for _, issue := range r.Issues {
+		if issue.Severity == ScannabilityError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// SignedPayloadEmbed selects where the signed token goes in the encoded content.
+type SignedPayloadEmbed string
+
+const (
+	SignedPayloadEmbedToken SignedPayloadEmbed = "token" // The QR code holds only the token (default)
+	SignedPayloadEmbedQuery SignedPayloadEmbed = "query" // The token is added to the deep link or url content as ?sig=
+)
+
+func (e SignedPayloadEmbed) IsValid() bool {
+	switch e {
+	case SignedPayloadEmbedToken, SignedPayloadEmbedQuery:
+		return true
+	default:
+		return false
+	}
+}
+
+// SignedPayload makes the QR codes of a template carry a compact signed token (a JWT signed
+// with the service's Ed25519 or ECDSA key) that scanner apps can verify offline against the
+// published keys, e.g. {"fields": ["seat", "holder"], "embed": "token"}.
+// The token holds the code ID, the listed Data fields, and its activation and expiry times.
+type SignedPayload struct {
+	Fields []string           `json:"fields,omitempty"` // Data fields included in the token
+	Embed  SignedPayloadEmbed `json:"embed,omitempty"`  // Defaults to token
+}
+
+// Value implements the `driver.Valuer` interface for SignedPayload.
+func (p SignedPayload) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+// Scan implements the `sql.Scanner` interface for SignedPayload.
+func (p *SignedPayload) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan SignedPayload: expected []byte")
+	}
+
+	return json.Unmarshal(bytes, p)
+}
+
+// SignedPayloadClaims are the claims of the token in a signed payload.
+type SignedPayloadClaims struct {
+	Subject   string                 `json:"sub"`           // QR code ID
+	IssuedAt  int64                  `json:"iat"`           // Unix time the code was created
+	NotBefore int64                  `json:"nbf,omitempty"` // Unix time the code activates
+	ExpiresAt int64                  `json:"exp,omitempty"` // Unix time the code expires
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+// SignedPayloadVerifyRequest is a token read from a QR code.
+type SignedPayloadVerifyRequest struct {
+	Token string `json:"token" binding:"required"` // The token, or content with it in the sig query parameter
+}
+
+// SignedPayloadVerifyResponse reports whether a token is valid, with its claims when the
+// signature verifies.
+type SignedPayloadVerifyResponse struct {
+	Valid  bool                 `json:"valid"`
+	KeyID  string               `json:"kid,omitempty"`
+	Claims *SignedPayloadClaims `json:"claims,omitempty"`
+	Code   string               `json:"code,omitempty"` // Reason the token is invalid
+	Error  string               `json:"error,omitempty"`
+}
@@ -18,6 +18,47 @@ const (
 	ErrorCorrectionH QRCodeErrorCorrection = "H"
 )
 
+// ModuleShape defines how the data modules of the symbol are drawn.
+type ModuleShape string
+
+const (
+	ModuleShapeSquare         ModuleShape = "square"          // Plain squares (default)
+	ModuleShapeCircle         ModuleShape = "circle"          // Circles filling the module
+	ModuleShapeRounded        ModuleShape = "rounded"         // Squares rounded on sides without dark neighbours
+	ModuleShapeDots           ModuleShape = "dots"            // Small separated dots
+	ModuleShapeDiamond        ModuleShape = "diamond"         // Squares rotated by 45 degrees
+	ModuleShapeVerticalBars   ModuleShape = "vertical-bars"   // Vertically adjacent modules joined into bars
+	ModuleShapeHorizontalBars ModuleShape = "horizontal-bars" // Horizontally adjacent modules joined into bars
+)
+
+func (s ModuleShape) IsValid() bool {
+	switch s {
+	case ModuleShapeSquare, ModuleShapeCircle, ModuleShapeRounded, ModuleShapeDots,
+		ModuleShapeDiamond, ModuleShapeVerticalBars, ModuleShapeHorizontalBars:
+		return true
+	default:
+		return false
+	}
+}
+
+// EyeShape defines how the three finder patterns (eyes) are drawn.
+type EyeShape string
+
+const (
+	EyeShapeSquare  EyeShape = "square" // Default
+	EyeShapeRounded EyeShape = "rounded"
+	EyeShapeCircle  EyeShape = "circle"
+)
+
+func (s EyeShape) IsValid() bool {
+	switch s {
+	case EyeShapeSquare, EyeShapeRounded, EyeShapeCircle:
+		return true
+	default:
+		return false
+	}
+}
+
 type FieldType string
 
 const (
@@ -35,13 +76,47 @@ func (ft FieldType) IsValid() bool {
 	}
 }
 
+// ConditionOperator defines how a FieldCondition compares the referenced value.
+type ConditionOperator string
+
+const (
+	ConditionOperatorEquals    ConditionOperator = "eq"
+	ConditionOperatorNotEquals ConditionOperator = "neq"
+	ConditionOperatorIn        ConditionOperator = "in"
+	ConditionOperatorNotIn     ConditionOperator = "notIn"
+	ConditionOperatorExists    ConditionOperator = "exists"
+	ConditionOperatorNotExists ConditionOperator = "notExists"
+)
+
+func (op ConditionOperator) IsValid() bool {
+	switch op {
+	case ConditionOperatorEquals, ConditionOperatorNotEquals, ConditionOperatorIn,
+		ConditionOperatorNotIn, ConditionOperatorExists, ConditionOperatorNotExists:
+		return true
+	default:
+		return false
+	}
+}
+
 // ---------- STRUCTS ----------
 
+// FieldCondition references the value of another field in the same Definition,
+// e.g. {"field": "ticketType", "operator": "eq", "value": "paid"}.
+type FieldCondition struct {
+	Field    string            `json:"field"`
+	Operator ConditionOperator `json:"operator,omitempty"` // Defaults to "eq"
+	Value    interface{}       `json:"value,omitempty"`
+}
+
 // Field represents a single field in the template definition.
 type Field struct {
 	Name        string                 `json:"name"`
 	Type        FieldType              `json:"type"`
 	Validations map[string]interface{} `json:"validations"`
+	Default     interface{}            `json:"default,omitempty"`    // Applied when the key is absent from the data
+	RequiredIf  *FieldCondition        `json:"requiredIf,omitempty"` // Field is required only when the condition holds
+	VisibleIf   *FieldCondition        `json:"visibleIf,omitempty"`  // Field is accepted only when the condition holds
+	Sensitive   bool                   `json:"sensitive,omitempty"`  // Encrypted at rest and masked in responses, e.g. phone numbers and national IDs
 }
 
 // Definition is an array of fields that defines the structure of a template.
@@ -79,9 +154,131 @@ func (f Field) Validate() error {
 	if !f.Type.IsValid() {
 		return fmt.Errorf("invalid field type: %s", f.Type)
 	}
+	if err := f.validateDefault(); err != nil {
+		return err
+	}
+	// Media references must stay readable to serve the file
+	if f.Sensitive && f.Type == FieldTypeMedia {
+		return fmt.Errorf("media field %s cannot be sensitive", f.Name)
+	}
+	if err := f.RequiredIf.validate(f.Name); err != nil {
+		return fmt.Errorf("invalid requiredIf for field %s: %w", f.Name, err)
+	}
+	if err := f.VisibleIf.validate(f.Name); err != nil {
+		return fmt.Errorf("invalid visibleIf for field %s: %w", f.Name, err)
+	}
+	return nil
+}
+
+// validateDefault ensures the default value matches the field type.
+func (f Field) validateDefault() error {
+	if f.Default == nil {
+		return nil
+	}
+	switch f.Type {
+	case FieldTypeText:
+		if _, ok := f.Default.(string); !ok {
+			return fmt.Errorf("default value for field %s must be a string", f.Name)
+		}
+	case FieldTypeNumber:
+		if _, ok := f.Default.(float64); !ok {
+			return fmt.Errorf("default value for field %s must be a number", f.Name)
+		}
+	default:
+		return fmt.Errorf("default values are not supported for %s field %s", f.Type, f.Name)
+	}
 	return nil
 }
 
+// validate checks the shape of a condition declared on the field named owner.
+func (c *FieldCondition) validate(owner string) error {
+	if c == nil {
+		return nil
+	}
+	if c.Field == "" {
+		return errors.New("condition field is required")
+	}
+	if c.Field == owner {
+		return errors.New("condition cannot reference the field itself")
+	}
+	if !c.operator().IsValid() {
+		return fmt.Errorf("invalid condition operator: %s", c.Operator)
+	}
+	switch c.operator() {
+	case ConditionOperatorIn, ConditionOperatorNotIn:
+		if _, ok := c.Value.([]interface{}); !ok {
+			return fmt.Errorf("condition operator %s requires an array value", c.Operator)
+		}
+	case ConditionOperatorEquals, ConditionOperatorNotEquals:
+		if c.Value == nil {
+			return fmt.Errorf("condition operator %s requires a value", c.operator())
+		}
+	}
+	return nil
+}
+
+func (c *FieldCondition) operator() ConditionOperator {
+	if c.Operator == "" {
+		return ConditionOperatorEquals
+	}
+	return c.Operator
+}
+
+// Evaluate reports whether the condition holds for the given data.
+func (c *FieldCondition) Evaluate(data map[string]interface{}) bool {
+	value, exists := data[c.Field]
+	exists = exists && value != nil
+
+	switch c.operator() {
+	case ConditionOperatorExists:
+		return exists
+	case ConditionOperatorNotExists:
+		return !exists
+	case ConditionOperatorEquals:
+		return exists && conditionValuesEqual(value, c.Value)
+	case ConditionOperatorNotEquals:
+		return !exists || !conditionValuesEqual(value, c.Value)
+	case ConditionOperatorIn, ConditionOperatorNotIn:
+		found := false
+		if candidates, ok := c.Value.([]interface{}); ok && exists {
+			for _, candidate := range candidates {
+				if conditionValuesEqual(value, candidate) {
+					found = true
+					break
+				}
+			}
+		}
+		return found == (c.operator() == ConditionOperatorIn)
+	default:
+		return false
+	}
+}
+
+// conditionValuesEqual compares two JSON scalar values.
+func conditionValuesEqual(a, b interface{}) bool {
+	if af, ok := a.(float64); ok {
+		bf, ok := b.(float64)
+		return ok && af == bf
+	}
+	return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+}
+
+// IsVisible reports whether the field applies to the given data.
+func (f Field) IsVisible(data map[string]interface{}) bool {
+	return f.VisibleIf == nil || f.VisibleIf.Evaluate(data)
+}
+
+// IsRequired reports whether the field must be present in the given data.
+func (f Field) IsRequired(data map[string]interface{}) bool {
+	if !f.IsVisible(data) {
+		return false
+	}
+	if required, ok := f.Validations["required"].(bool); ok && required {
+		return true
+	}
+	return f.RequiredIf != nil && f.RequiredIf.Evaluate(data)
+}
+
 // Validate validates the entire Definition.
 func (d Definition) Validate() error {
 	for _, field := range d {
@@ -89,24 +286,81 @@ func (d Definition) Validate() error {
 			return err
 		}
 	}
+	return d.ValidateConditions()
+}
+
+// ValidateConditions ensures every requiredIf/visibleIf references a field of the Definition.
+func (d Definition) ValidateConditions() error {
+	fields := make(map[string]Field, len(d))
+	for _, field := range d {
+		fields[field.Name] = field
+	}
+	for _, field := range d {
+		for _, cond := range []*FieldCondition{field.RequiredIf, field.VisibleIf} {
+			if cond == nil {
+				continue
+			}
+			target, ok := fields[cond.Field]
+			if !ok {
+				return fmt.Errorf("field %s references unknown field in condition: %s", field.Name, cond.Field)
+			}
+			// Stored sensitive values are encrypted and cannot be compared
+			if target.Sensitive {
+				return fmt.Errorf("field %s references sensitive field in condition: %s", field.Name, cond.Field)
+			}
+		}
+	}
 	return nil
 }
 
+// ApplyDefaults returns a copy of data with the default value of every visible
+// field whose key is absent. Unconditional fields are filled first so that
+// visibleIf conditions can see their defaults.
+func (d Definition) ApplyDefaults(data map[string]interface{}) JSONMap {
+	result := make(JSONMap, len(data))
+	for key, value := range data {
+		result[key] = value
+	}
+
+	for _, conditional := range []bool{false, true} {
+		for _, field := range d {
+			if field.Default == nil || (field.VisibleIf != nil) != conditional {
+				continue
+			}
+			if _, exists := result[field.Name]; exists {
+				continue
+			}
+			if field.IsVisible(result) {
+				result[field.Name] = field.Default
+			}
+		}
+	}
+	return result
+}
+
 // ---------- TEMPLATE MODEL ----------
 
 type Template struct {
-	ID              string                `gorm:"primaryKey" json:"id"`
-	Name            string                `json:"name"`
-	Description     string                `json:"description"`
-	ClientAppID     string                `gorm:"not null" json:"clientAppId"`
-	ClientApp       ClientApp             `gorm:"foreignKey:ClientAppID;references:ID" json:"-"`
-	Definition      Definition            `gorm:"type:json" json:"definition"` // Updated to use Definition
-	Shape           string                `json:"shape"`
-	ForegroundColor string                `json:"foregroundColor"`
-	BackgroundColor string                `json:"backgroundColor"`
-	Size            int                   `json:"size"`
-	LogoURL         string                `json:"logoUrl"`
-	ErrorCorrection QRCodeErrorCorrection `json:"errorCorrection"`
+	ID                 string                `gorm:"primaryKey" json:"id"`
+	Name               string                `json:"name"`
+	Description        string                `json:"description"`
+	ClientAppID        string                `gorm:"not null" json:"clientAppId"`
+	ClientApp          ClientApp             `gorm:"foreignKey:ClientAppID;references:ID" json:"-"`
+	Definition         Definition            `gorm:"type:json" json:"definition"` // Updated to use Definition
+	Shape              ModuleShape           `json:"shape"`
+	EyeShape           EyeShape              `json:"eyeShape"`
+	EyeColor           string                `json:"eyeColor"` // Defaults to ForegroundColor
+	ForegroundColor    string                `json:"foregroundColor"`
+	ForegroundGradient *Gradient             `gorm:"type:json" json:"foregroundGradient,omitempty"` // Overrides ForegroundColor
+	BackgroundColor    string                `json:"backgroundColor"`                               // Alpha allowed, e.g. #FFFFFF00 for transparent
+	Size               int                   `json:"size"`
+	LogoURL            string                `json:"logoUrl"`                                  // Uploaded logo, or an http(s) URL it is fetched from
+	LogoOptions        *LogoOptions          `gorm:"type:json" json:"logoOptions,omitempty"`   // Logo scale, padding and plate
+	ErrorCorrection    QRCodeErrorCorrection `json:"errorCorrection"`                          // Raised when the logo covers too much for it
+	Content            *ContentMapping       `gorm:"type:json" json:"content,omitempty"`       // How Data is assembled into the encoded content
+	Frame              *Frame                `gorm:"type:json" json:"frame,omitempty"`         // Border, card or banner with a caption
+	LandingPage        *LandingPage          `gorm:"type:json" json:"landingPage,omitempty"`   // Page DYNAMIC codes open on
+	SignedPayload      *SignedPayload        `gorm:"type:json" json:"signedPayload,omitempty"` // Signed token scanner apps verify offline
 
 	Active    bool      `json:"active"`
 	CreatedAt time.Time `json:"createdAt"`
@@ -116,27 +370,45 @@ type Template struct {
 // ---------- REQUEST STRUCTS ----------
 
 type TemplateCreateRequest struct {
-	Name            string                `json:"name" binding:"required"`
-	Description     string                `json:"description"`
-	ClientAppID     string                `json:"clientAppId" binding:"required"`
-	Definition      Definition            `json:"definition"` // Updated to use Definition
-	Shape           string                `json:"shape"`
-	ForegroundColor string                `json:"foregroundColor"`
-	BackgroundColor string                `json:"backgroundColor"`
-	Size            int                   `json:"size"`
-	LogoURL         string                `json:"logoUrl"`
-	ErrorCorrection QRCodeErrorCorrection `json:"errorCorrection"`
+	Name               string                `json:"name" binding:"required"`
+	Description        string                `json:"description"`
+	ClientAppID        string                `json:"clientAppId" binding:"required"`
+	Definition         Definition            `json:"definition"` // Updated to use Definition
+	Shape              ModuleShape           `json:"shape"`
+	EyeShape           EyeShape              `json:"eyeShape"`
+	EyeColor           string                `json:"eyeColor"` // Defaults to ForegroundColor
+	ForegroundColor    string                `json:"foregroundColor"`
+	ForegroundGradient *Gradient             `json:"foregroundGradient,omitempty"` // Overrides ForegroundColor
+	BackgroundColor    string                `json:"backgroundColor"`
+	Size               int                   `json:"size"`
+	LogoURL            string                `json:"logoUrl"`
+	LogoOptions        *LogoOptions          `json:"logoOptions,omitempty"`
+	LogoData           string                `json:"logoData,omitempty"` // Logo uploaded inline, as base64 or a data: URI
+	ErrorCorrection    QRCodeErrorCorrection `json:"errorCorrection"`
+	Content            *ContentMapping       `json:"content,omitempty"`
+	Frame              *Frame                `json:"frame,omitempty"`
+	LandingPage        *LandingPage          `json:"landingPage,omitempty"`
+	SignedPayload      *SignedPayload        `json:"signedPayload,omitempty"`
 }
 
 type TemplateUpdateRequest struct {
-	Name            string                `json:"name" binding:"required"`
-	Description     string                `json:"description"`
-	ClientAppID     string                `json:"clientAppId" binding:"required"`
-	Definition      Definition            `json:"definition"` // Updated to use Definition
-	Shape           string                `json:"shape"`
-	ForegroundColor string                `json:"foregroundColor"`
-	BackgroundColor string                `json:"backgroundColor"`
-	Size            int                   `json:"size"`
-	LogoURL         string                `json:"logoUrl"`
-	ErrorCorrection QRCodeErrorCorrection `json:"errorCorrection"`
+	Name               string                `json:"name" binding:"required"`
+	Description        string                `json:"description"`
+	ClientAppID        string                `json:"clientAppId" binding:"required"`
+	Definition         Definition            `json:"definition"` // Updated to use Definition
+	Shape              ModuleShape           `json:"shape"`
+	EyeShape           EyeShape              `json:"eyeShape"`
+	EyeColor           string                `json:"eyeColor"` // Defaults to ForegroundColor
+	ForegroundColor    string                `json:"foregroundColor"`
+	ForegroundGradient *Gradient             `json:"foregroundGradient,omitempty"` // Overrides ForegroundColor
+	BackgroundColor    string                `json:"backgroundColor"`
+	Size               int                   `json:"size"`
+	LogoURL            string                `json:"logoUrl"`
+	LogoOptions        *LogoOptions          `json:"logoOptions,omitempty"`
+	LogoData           string                `json:"logoData,omitempty"` // Logo uploaded inline, as base64 or a data: URI
+	ErrorCorrection    QRCodeErrorCorrection `json:"errorCorrection"`
+	Content            *ContentMapping       `json:"content,omitempty"`
+	Frame              *Frame                `json:"frame,omitempty"`
+	LandingPage        *LandingPage          `json:"landingPage,omitempty"`
+	SignedPayload      *SignedPayload        `json:"signedPayload,omitempty"`
 }
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mca93/qrcode_service/controllers"
+	"github.com/mca93/qrcode_service/middleware"
+)
+
+// RegisterAdminRoutes registers the service-wide routes operators call with the admin API key.
+func RegisterAdminRoutes(router *gin.Engine) {
+	admin := router.Group("/v1/admin", middleware.AdminAuthMiddleware())
+	{
+		admin.GET("/keys", controllers.ListKeys)
+		admin.POST("/keys/:purpose/rotate", controllers.RotateKey)
+		// Key client apps read the sensitive data of their QR codes with
+		admin.POST("/clientapps/:id/reveal-key", controllers.IssueRevealKey)
+	}
+}
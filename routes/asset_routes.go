@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mca93/qrcode_service/controllers"
+)
+
+// RegisterAssetRoutes registers the route local assets are downloaded from through signed URLs.
+func RegisterAssetRoutes(router *gin.Engine) {
+	router.GET("/assets/*key", controllers.ServeAsset)
+}
@@ -12,12 +12,22 @@ func RegisterQRCodeRoutes(router *gin.Engine) {
 		// QR code routes with middleware to validate API key and ClientAppID
 		v1.GET("/qrcodes", middleware.QRCodeAuthMiddleware(), controllers.ListQRCodes)
 		v1.POST("/qrcodes", middleware.QRCodeAuthMiddleware(), controllers.CreateQRCode)
+		// Report encoded content, length and QR version before creation
+		v1.POST("/qrcodes/content-preview", middleware.QRCodeAuthMiddleware(), controllers.PreviewQRCodeContent)
+		// Read the QR codes in an uploaded photo and identify ours
+		v1.POST("/qrcodes/decode", middleware.QRCodeAuthMiddleware(), controllers.DecodeQRCodes)
 		v1.GET("/qrcodes/:id", middleware.QRCodeAuthMiddleware(), controllers.GetQRCode)
 		// Preview QR code image
 		v1.GET("/qrcodes/:id/preview", controllers.GetQRCodeImage)
-		// Download QR code image
-		// v1.GET("/qrcodes/:id/download", middleware.ApiKeyAuthMiddleware(), controllers.DownloadQRCode)
+		// Export the QR code image and get a signed URL to download it from
+		v1.GET("/qrcodes/:id/download", middleware.QRCodeAuthMiddleware(), controllers.DownloadQRCode)
+		// Compare the scans and conversions of A/B test variants
+		v1.GET("/qrcodes/:id/variants/report", middleware.QRCodeAuthMiddleware(), controllers.GetVariantReport)
+		// Redemptions staff devices recorded for the QR code
+		v1.GET("/qrcodes/:id/redemptions", middleware.QRCodeAuthMiddleware(), controllers.ListQRCodeRedemptions)
 		v1.PUT("/qrcodes/:id", middleware.QRCodeAuthMiddleware(), controllers.UpdateQRCode)
+		// Upload the file of a Media field
+		v1.POST("/qrcodes/:id/media/:field", middleware.QRCodeAuthMiddleware(), controllers.UploadQRCodeMedia)
 		v1.DELETE("/qrcodes/:id", middleware.QRCodeAuthMiddleware(), controllers.DeleteQRCode)
 	}
 }
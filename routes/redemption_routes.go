@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mca93/qrcode_service/controllers"
+	"github.com/mca93/qrcode_service/middleware"
+)
+
+// RegisterRedemptionRoutes registers the staff device management routes of client apps and the
+// check-in routes staff devices call with their token.
+func RegisterRedemptionRoutes(router *gin.Engine) {
+	v1 := router.Group("/v1")
+	{
+		v1.POST("/devices", middleware.QRCodeAuthMiddleware(), controllers.CreateStaffDevice)
+		v1.GET("/devices", middleware.QRCodeAuthMiddleware(), controllers.ListStaffDevices)
+		v1.DELETE("/devices/:id", middleware.QRCodeAuthMiddleware(), controllers.DeactivateStaffDevice)
+
+		v1.POST("/redemptions", middleware.StaffDeviceAuthMiddleware(), controllers.Redeem)
+		// Redemptions door scanners made while offline
+		v1.POST("/redemptions/sync", middleware.StaffDeviceAuthMiddleware(), controllers.SyncRedemptions)
+	}
+}
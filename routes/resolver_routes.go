@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mca93/qrcode_service/controllers"
+)
+
+// RegisterResolverRoutes registers the public routes that scanned QR codes point to.
+func RegisterResolverRoutes(router *gin.Engine) {
+	// Links by ID or short ID, see models.QRCode.ResolverURL
+	router.GET("/qrcodes/:id", controllers.ScanQRCode)
+	// Deep links built by models.QRCode.BuildDeepLinkURL, and custom links under the client
+	// app's slug
+	router.GET("/q/:ref", controllers.ResolveShortLink)
+	router.GET("/q/:ref/:slug", controllers.ResolveSlugLink)
+	// Media in the QR code's data, linked from its landing page
+	router.GET("/qrcodes/:id/media/:field", controllers.ServeQRCodeMedia)
+	// PIN or password entered on the prompt of protected QR codes
+	router.POST("/qrcodes/:id/unlock", controllers.UnlockQRCode)
+	// Conversions reported by A/B test destinations for the qrScanId they were sent
+	router.POST("/qrcodes/:id/conversions", controllers.RecordConversion)
+	// GS1 Digital Link URIs, e.g. /01/09506000134352/10/ABC123
+	router.GET("/01/*path", controllers.ResolveDigitalLink)
+	router.GET("/gtin/*path", controllers.ResolveDigitalLink)
+}
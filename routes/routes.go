@@ -15,4 +15,19 @@ func SetupRoutes(router *gin.Engine) {
 	// Regista as rotas do QRCode
 	RegisterQRCodeRoutes(router)
 
+	// Regista as rotas dos dispositivos de check-in e dos resgates
+	RegisterRedemptionRoutes(router)
+
+	// Regista as rotas públicas de resolução dos QR codes
+	RegisterResolverRoutes(router)
+
+	// Regista as rotas públicas das chaves e da verificação dos payloads assinados
+	RegisterSigningRoutes(router)
+
+	// Regista as rotas de administração do serviço, como a rotação das chaves
+	RegisterAdminRoutes(router)
+
+	// Regista a rota dos ficheiros com URLs assinados
+	RegisterAssetRoutes(router)
+
 }
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mca93/qrcode_service/controllers"
+)
+
+// RegisterSigningRoutes registers the public routes scanner apps validate signed payloads with.
+func RegisterSigningRoutes(router *gin.Engine) {
+	// Public keys, cached by scanner apps to verify codes offline
+	router.GET("/.well-known/jwks.json", controllers.GetSigningKeys)
+	router.POST("/v1/signed-payloads/verify", controllers.VerifySignedPayload)
+}
@@ -14,5 +14,6 @@ func RegisterTemplateRoutes(r *gin.Engine) {
 		templateRoutes.GET("/:id", controllers.NewTemplateController().GetTemplate)                    // Get a specific template by ID
 		templateRoutes.PATCH("/:id", controllers.NewTemplateController().UpdateTemplate)               // Update a specific template by ID
 		templateRoutes.POST("/:id/deactivate", controllers.NewTemplateController().DeactivateTemplate) // Deactivate a specific template by ID
+		templateRoutes.POST("/:id/check", controllers.NewTemplateController().CheckTemplate)           // Check a template for scannability issues
 	}
 }
@@ -0,0 +1,408 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+)
+
+// Algorithms of the keys payloads are signed with, as named in JWS headers.
+const (
+	AlgorithmEdDSA = "EdDSA" // Ed25519
+	AlgorithmES256 = "ES256" // ECDSA on P-256 with SHA-256
+	AlgorithmES384 = "ES384" // ECDSA on P-384 with SHA-384
+)
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed or whose signature does not verify.
+	ErrInvalidToken = errors.New("invalid signed token")
+	// ErrUnknownKey is returned for tokens signed with a key that is not in the key set.
+	ErrUnknownKey = errors.New("token signed with an unknown key")
+)
+
+// Key is a private key payloads are signed with, identified by the kid in token headers.
+type Key struct {
+	ID        string
+	Algorithm string
+	private   crypto.Signer
+}
+
+// NewKey wraps an Ed25519, P-256 or P-384 private key. Without an id the key is identified by
+// its RFC 7638 thumbprint.
+func NewKey(id string, private crypto.Signer) (*Key, error) {
+	key := &Key{ID: id, private: private}
+	switch k := private.(type) {
+	case ed25519.PrivateKey:
+		key.Algorithm = AlgorithmEdDSA
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			key.Algorithm = AlgorithmES256
+		case elliptic.P384():
+			key.Algorithm = AlgorithmES384
+		default:
+			return nil, errors.New("unsupported ECDSA curve, must be P-256 or P-384")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T, must be Ed25519 or ECDSA", private)
+	}
+	if key.ID == "" {
+		thumbprint, err := key.PublicJWK().Thumbprint()
+		if err != nil {
+			return nil, err
+		}
+		key.ID = thumbprint
+	}
+	return key, nil
+}
+
+// GenerateKey generates a key for algorithm.
+func GenerateKey(id, algorithm string) (*Key, error) {
+	var private crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case AlgorithmES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmES384:
+		private, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewKey(id, private)
+}
+
+// ParsePrivateKey reads a PKCS#8 ("PRIVATE KEY") or SEC 1 ("EC PRIVATE KEY") PEM private key.
+func ParsePrivateKey(id string, pemData []byte) (*Key, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("signing key is not PEM encoded")
+	}
+	var private interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %w", err)
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported signing key type %T", private)
+	}
+	return NewKey(id, signer)
+}
+
+// MarshalPrivateKey encodes the key as a PKCS#8 PEM block.
+func (k *Key) MarshalPrivateKey() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.private)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// Public returns the public key.
+func (k *Key) Public() crypto.PublicKey {
+	return k.private.Public()
+}
+
+// KeySet holds the key new payloads are signed with and the public keys tokens are verified
+// against, which include retired keys so codes already printed keep verifying.
+type KeySet struct {
+	signing *Key
+	public  map[string]*PublicKey
+	order   []string
+}
+
+// NewKeySet returns a key set signing with signingKey and verifying with it and the other keys.
+func NewKeySet(signingKey *Key, others ...*PublicKey) *KeySet {
+	set := &KeySet{signing: signingKey, public: make(map[string]*PublicKey)}
+	if signingKey != nil {
+		set.add(&PublicKey{ID: signingKey.ID, Algorithm: signingKey.Algorithm, Key: signingKey.Public()})
+	}
+	for _, key := range others {
+		set.add(key)
+	}
+	return set
+}
+
+func (s *KeySet) add(key *PublicKey) {
+	if _, exists := s.public[key.ID]; !exists {
+		s.order = append(s.order, key.ID)
+	}
+	s.public[key.ID] = key
+}
+
+// SigningKey returns the key new payloads are signed with, or nil when there is none.
+func (s *KeySet) SigningKey() *Key {
+	return s.signing
+}
+
+// JWKS returns the public keys as a JSON Web Key Set.
+func (s *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: make([]JWK, 0, len(s.order))}
+	for _, id := range s.order {
+		jwks.Keys = append(jwks.Keys, s.public[id].JWK())
+	}
+	return jwks
+}
+
+// PublicKey is a key tokens are verified with.
+type PublicKey struct {
+	ID        string
+	Algorithm string
+	Key       crypto.PublicKey
+}
+
+// PublicJWK returns the public half of the key as a JWK.
+func (k *Key) PublicJWK() JWK {
+	return (&PublicKey{ID: k.ID, Algorithm: k.Algorithm, Key: k.Public()}).JWK()
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK returns the key in JSON Web Key format.
+func (k *PublicKey) JWK() JWK {
+	jwk := JWK{Kid: k.ID, Alg: k.Algorithm, Use: "sig"}
+	switch key := k.Key.(type) {
+	case ed25519.PublicKey:
+		jwk.Kty, jwk.Crv, jwk.X = "OKP", "Ed25519", b64(key)
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		jwk.Kty, jwk.Crv = "EC", key.Curve.Params().Name
+		jwk.X, jwk.Y = b64(key.X.FillBytes(make([]byte, size))), b64(key.Y.FillBytes(make([]byte, size)))
+	}
+	return jwk
+}
+
+// Thumbprint returns the RFC 7638 SHA-256 thumbprint of the key, base64url encoded.
+func (j JWK) Thumbprint() (string, error) {
+	// The required members in lexicographic order, without whitespace
+	var members string
+	switch j.Kty {
+	case "OKP":
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q}`, j.Crv, j.Kty, j.X)
+	case "EC":
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, j.Crv, j.Kty, j.X, j.Y)
+	default:
+		return "", fmt.Errorf("unsupported key type: %s", j.Kty)
+	}
+	sum := sha256.Sum256([]byte(members))
+	return b64(sum[:]), nil
+}
+
+// ParseJWK reads a public key from its JSON Web Key.
+func ParseJWK(jwk JWK) (*PublicKey, error) {
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, errors.New("invalid JWK x")
+	}
+	key := &PublicKey{ID: jwk.Kid}
+	switch {
+	case jwk.Kty == "OKP" && jwk.Crv == "Ed25519" && len(x) == ed25519.PublicKeySize:
+		key.Algorithm, key.Key = AlgorithmEdDSA, ed25519.PublicKey(x)
+	case jwk.Kty == "EC" && (jwk.Crv == "P-256" || jwk.Crv == "P-384"):
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, errors.New("invalid JWK y")
+		}
+		curve, algorithm := elliptic.P256(), AlgorithmES256
+		if jwk.Crv == "P-384" {
+			curve, algorithm = elliptic.P384(), AlgorithmES384
+		}
+		public := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(public.X, public.Y) {
+			return nil, errors.New("JWK point is not on the curve")
+		}
+		key.Algorithm, key.Key = algorithm, public
+	default:
+		return nil, fmt.Errorf("unsupported JWK %s %s", jwk.Kty, jwk.Crv)
+	}
+	if key.ID == "" {
+		if key.ID, err = key.JWK().Thumbprint(); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// header is the protected header of the tokens this package issues.
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// Sign returns claims as a compact JWS (a JWT when claims are JWT claims) signed with key.
+func Sign(key *Key, claims interface{}) (string, error) {
+	h, err := json.Marshal(header{Alg: key.Algorithm, Kid: key.ID, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := b64(h) + "." + b64(payload)
+
+	var signature []byte
+	switch private := key.private.(type) {
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(private, []byte(signingInput))
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, private, digest(key.Algorithm, signingInput))
+		if err != nil {
+			return "", err
+		}
+		// JWS uses the fixed-size concatenation of r and s rather than ASN.1
+		size := (private.Curve.Params().BitSize + 7) / 8
+		signature = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	}
+	return signingInput + "." + b64(signature), nil
+}
+
+// Verify checks the signature of a compact JWS against the key set, decodes its payload into
+// claims and returns the ID of the key it was signed with.
+func (s *KeySet) Verify(token string, claims interface{}) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrInvalidToken
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	var h header
+	if err := json.Unmarshal(rawHeader, &h); err != nil {
+		return "", ErrInvalidToken
+	}
+	key, ok := s.public[h.Kid]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+	// The key decides the algorithm, never the token
+	if h.Alg != key.Algorithm {
+		return "", ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+
+	signingInput := parts[0] + "." + parts[1]
+	switch public := key.Key.(type) {
+	case ed25519.PublicKey:
+		if !ed25519.Verify(public, []byte(signingInput), signature) {
+			return "", ErrInvalidToken
+		}
+	case *ecdsa.PublicKey:
+		size := (public.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return "", ErrInvalidToken
+		}
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(public, digest(key.Algorithm, signingInput), r, s) {
+			return "", ErrInvalidToken
+		}
+	default:
+		return "", ErrUnknownKey
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return "", ErrInvalidToken
+	}
+	return key.ID, nil
+}
+
+// KeyID returns the kid in the header of a compact JWS, without verifying it, or "" when
+// there is none.
+func KeyID(token string) string {
+	encoded, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return ""
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ""
+	}
+	var h header
+	if err := json.Unmarshal(rawHeader, &h); err != nil {
+		return ""
+	}
+	return h.Kid
+}
+
+// IsCompact reports whether s has the form of a compact JWS: three non-empty base64url
+// segments separated by dots.
+func IsCompact(s string) bool {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" {
+			return false
+		}
+		for i := 0; i < len(part); i++ {
+			c := part[i]
+			if !('A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func digest(algorithm, signingInput string) []byte {
+	var h hash.Hash = sha256.New()
+	if algorithm == AlgorithmES384 {
+		h = sha512.New384()
+	}
+	h.Write([]byte(signingInput))
+	return h.Sum(nil)
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,89 @@
+package signing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testClaims struct {
+	Subject string `json:"sub"`
+}
+
+func TestSignAndVerify(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmEdDSA, AlgorithmES256, AlgorithmES384} {
+		key, err := GenerateKey("", algorithm)
+		assert.NoError(t, err)
+		set := NewKeySet(key)
+
+		token, err := Sign(key, testClaims{Subject: "qr-1"})
+		assert.NoError(t, err)
+
+		var claims testClaims
+		kid, err := set.Verify(token, &claims)
+		assert.NoError(t, err, algorithm)
+		assert.Equal(t, key.ID, kid)
+		assert.Equal(t, "qr-1", claims.Subject)
+
+		// Any change to the payload breaks the signature
+		parts := strings.Split(token, ".")
+		other, _ := Sign(key, testClaims{Subject: "qr-2"})
+		forged := parts[0] + "." + strings.Split(other, ".")[1] + "." + parts[2]
+		_, err = set.Verify(forged, &claims)
+		assert.ErrorIs(t, err, ErrInvalidToken, algorithm)
+	}
+}
+
+func TestVerifyWithRetiredKey(t *testing.T) {
+	retired, _ := GenerateKey("old", AlgorithmES256)
+	current, _ := GenerateKey("new", AlgorithmEdDSA)
+	token, _ := Sign(retired, testClaims{Subject: "qr-1"})
+
+	var claims testClaims
+	_, err := NewKeySet(current).Verify(token, &claims)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	// Retired keys are published and verified from their JWK
+	public, err := ParseJWK(retired.PublicJWK())
+	assert.NoError(t, err)
+	set := NewKeySet(current, public)
+	kid, err := set.Verify(token, &claims)
+	assert.NoError(t, err)
+	assert.Equal(t, "old", kid)
+	assert.Len(t, set.JWKS().Keys, 2)
+}
+
+func TestKeyRoundTrip(t *testing.T) {
+	key, _ := GenerateKey("", AlgorithmEdDSA)
+	pemData, err := key.MarshalPrivateKey()
+	assert.NoError(t, err)
+
+	parsed, err := ParsePrivateKey("", pemData)
+	assert.NoError(t, err)
+	assert.Equal(t, key.ID, parsed.ID) // Identified by the same thumbprint
+	assert.Equal(t, AlgorithmEdDSA, parsed.Algorithm)
+}
+
+func TestThumbprint(t *testing.T) {
+	// RFC 8037 appendix A.3
+	jwk := JWK{Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}
+	thumbprint, err := jwk.Thumbprint()
+	assert.NoError(t, err)
+	assert.Equal(t, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", thumbprint)
+}
+
+func TestIsCompact(t *testing.T) {
+	assert.True(t, IsCompact("eyJhbGciOiJFZERTQSJ9.eyJzdWIiOiJxci0xIn0.c2ln-_"))
+	assert.False(t, IsCompact("0b6c2f0e-5d1a-4c59-9c0e-1f2a3b4c5d6e"))
+	assert.False(t, IsCompact("a..c"))
+	assert.False(t, IsCompact("a.b.c.d"))
+	assert.False(t, IsCompact("a.b+.c"))
+}
+
+func TestKeyID(t *testing.T) {
+	key, _ := GenerateKey("signing-v2", AlgorithmEdDSA)
+	token, _ := Sign(key, map[string]string{"sub": "qr-1"})
+	assert.Equal(t, "signing-v2", KeyID(token))
+	assert.Empty(t, KeyID("not-a-token"))
+}
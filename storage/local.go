@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AssetRoutePrefix is the path the service serves local assets from through signed URLs.
+const AssetRoutePrefix = "/assets/"
+
+// tempPrefix names files being written, which are not objects yet.
+const tempPrefix = ".upload-"
+
+// Local stores assets as files under a root directory. Signed URLs point to the service
+// itself, which checks them with VerifySignedURL before serving the file.
+type Local struct {
+	root        string
+	baseURL     string // Scheme and host the service is reached at
+	signingKeys SigningKeys
+}
+
+// SigningKeys provides the HMAC keys signed URLs are signed with. URLs name the key they were
+// signed with, so they keep working until they expire when the keys rotate.
+type SigningKeys interface {
+	Current() (id string, key []byte, err error)
+	Key(id string) ([]byte, error)
+}
+
+// StaticKey is a single signing key that never rotates.
+type StaticKey []byte
+
+func (k StaticKey) Current() (string, []byte, error) { return "", k, nil }
+
+func (k StaticKey) Key(id string) ([]byte, error) {
+	if id != "" {
+		return nil, errors.New("unknown signing key")
+	}
+	return k, nil
+}
+
+// NewLocal returns a storage keeping files under root, whose signed URLs start with baseURL
+// and are signed with signingKeys.
+func NewLocal(root, baseURL string, signingKeys SigningKeys) *Local {
+	return &Local{root: root, baseURL: strings.TrimSuffix(baseURL, "/"), signingKeys: signingKeys}
+}
+
+func (l *Local) path(key string) (string, error) {
+	if !ValidKey(key) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes the file under a temporary name first, so a file with the key's name is always complete.
+func (l *Local) Put(_ context.Context, key string, data []byte, _ string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(p)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create asset directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, tempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("failed to store asset: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to store asset: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to store asset: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return fmt.Errorf("failed to store asset: %w", err)
+	}
+	return nil
+}
+
+func (l *Local) Get(_ context.Context, key string) ([]byte, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read asset: %w", err)
+	}
+	return data, nil
+}
+
+func (l *Local) Stat(_ context.Context, key string) (Object, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return Object{}, err
+	}
+	info, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+		return Object{}, ErrNotFound
+	}
+	if err != nil {
+		return Object{}, fmt.Errorf("failed to read asset: %w", err)
+	}
+	return Object{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (l *Local) Delete(_ context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete asset: %w", err)
+	}
+	return nil
+}
+
+func (l *Local) List(_ context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	err := filepath.WalkDir(l.root, func(p string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == l.root {
+			return filepath.SkipDir // Nothing stored yet
+		}
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), tempPrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(l.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // Deleted while listing
+		}
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list assets: %w", err)
+	}
+	return objects, nil
+}
+
+// SignedURL returns a URL to the service's asset route, e.g.
+// https://qr.example.com/assets/exports/a.png?expires=1700000000&kid=url-signing-v1&signature=...
+func (l *Local) SignedURL(_ context.Context, key string, expiry time.Duration) (string, error) {
+	if !ValidKey(key) {
+		return "", ErrInvalidKey
+	}
+	if err := checkExpiry(expiry); err != nil {
+		return "", err
+	}
+	kid, signingKey, err := l.signingKeys.Current()
+	if err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	query := url.Values{"expires": {expires}, "signature": {sign(signingKey, key, expires)}}
+	if kid != "" {
+		query.Set("kid", kid)
+	}
+	return l.baseURL + AssetRoutePrefix + key + "?" + query.Encode(), nil
+}
+
+// VerifySignedURL checks the expires and signature query parameters of a signed URL for key.
+func (l *Local) VerifySignedURL(key string, query url.Values) error {
+	expires := query.Get("expires")
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return errors.New("invalid signed URL")
+	}
+	signingKey, err := l.signingKeys.Key(query.Get("kid"))
+	if err != nil {
+		return errors.New("invalid signed URL")
+	}
+	if !hmac.Equal([]byte(query.Get("signature")), []byte(sign(signingKey, key, expires))) {
+		return errors.New("invalid signed URL")
+	}
+	if time.Now().Unix() > unix {
+		return errors.New("signed URL has expired")
+	}
+	return nil
+}
+
+func sign(signingKey []byte, key, expires string) string {
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+// MaxSignedURLExpiry is the longest a signed URL can stay valid, the limit S3 puts on presigned URLs.
+const MaxSignedURLExpiry = 7 * 24 * time.Hour
+
+var (
+	// ErrNotFound is returned for keys with no stored object.
+	ErrNotFound = errors.New("asset not found")
+	// ErrInvalidKey is returned for keys that are not relative, clean, slash-separated paths.
+	ErrInvalidKey = errors.New("invalid asset key")
+)
+
+// Storage keeps uploaded and generated assets, such as template logos and exported images,
+// under slash-separated keys like "logos/<sha256>.png". Assets are private; clients download
+// them through signed, time-limited URLs.
+type Storage interface {
+	// Put stores data under key, replacing any object there and refreshing its modification time.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get returns the object stored under key, or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Stat describes the object stored under key, or returns ErrNotFound.
+	Stat(ctx context.Context, key string) (Object, error)
+	// Delete removes the object stored under key. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// List returns the objects whose keys start with prefix.
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// SignedURL returns a URL the object can be downloaded from until expiry has passed.
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// Object describes a stored object.
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// ValidKey reports whether key is a relative, clean, slash-separated path such as "logos/a.png".
+func ValidKey(key string) bool {
+	return key != "" && !strings.HasPrefix(key, "/") && path.Clean(key) == key &&
+		key != ".." && !strings.HasPrefix(key, "../") && !strings.ContainsAny(key, "\\\x00")
+}
+
+// contentTypes are the media types of the extensions stored assets have, which the system's
+// MIME tables may lack.
+var contentTypes = map[string]string{
+	".svg":  "image/svg+xml",
+	".ttf":  "font/ttf",
+	".otf":  "font/otf",
+	".pdf":  "application/pdf",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".mp3":  "audio/mpeg",
+	".wav":  "audio/wav",
+	".ogg":  "application/ogg",
+}
+
+// ContentType returns the media type of an object by its key's extension.
+func ContentType(key string) string {
+	ext := strings.ToLower(path.Ext(key))
+	if contentType, ok := contentTypes[ext]; ok {
+		return contentType
+	}
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+func checkExpiry(expiry time.Duration) error {
+	if expiry <= 0 || expiry > MaxSignedURLExpiry {
+		return errors.New("signed URL expiry must be positive and at most 7 days")
+	}
+	return nil
+}
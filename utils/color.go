@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mca93/qrcode_service/models"
+)
+
+// ParseHexColor parses a #RGB, #RGBA, #RRGGBB or #RRGGBBAA color, returning fallback when s is empty.
+func ParseHexColor(s string, fallback color.Color) (color.Color, error) {
+	if s == "" {
+		return fallback, nil
+	}
+
+	if !strings.HasPrefix(s, "#") {
+		return nil, fmt.Errorf("invalid hex color: %s", s)
+	}
+	hex := s[1:]
+	if len(hex) == 3 || len(hex) == 4 {
+		expanded := make([]byte, 0, 8)
+		for i := 0; i < len(hex); i++ {
+			expanded = append(expanded, hex[i], hex[i])
+		}
+		hex = string(expanded)
+	}
+	if len(hex) == 6 {
+		hex += "FF"
+	}
+	if len(hex) != 8 {
+		return nil, fmt.Errorf("invalid hex color: %s", s)
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex color: %s", s)
+	}
+	return color.NRGBA{R: uint8(value >> 24), G: uint8(value >> 16), B: uint8(value >> 8), A: uint8(value)}, nil
+}
+
+// Composite returns c drawn over an opaque backdrop.
+func Composite(c, backdrop color.Color) color.Color {
+	fg := color.NRGBAModel.Convert(c).(color.NRGBA)
+	bg := color.NRGBAModel.Convert(backdrop).(color.NRGBA)
+	alpha := float64(fg.A) / 255
+	blend := func(f, b uint8) uint8 {
+		return uint8(math.Round(float64(f)*alpha + float64(b)*(1-alpha)))
+	}
+	return color.NRGBA{R: blend(fg.R, bg.R), G: blend(fg.G, bg.G), B: blend(fg.B, bg.B), A: 0xFF}
+}
+
+// RelativeLuminance returns the WCAG 2 relative luminance of an opaque color.
+func RelativeLuminance(c color.Color) float64 {
+	nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	linear := func(v uint8) float64 {
+		s := float64(v) / 255
+		if s <= 0.03928 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(nrgba.R) + 0.7152*linear(nrgba.G) + 0.0722*linear(nrgba.B)
+}
+
+// ContrastRatio returns the WCAG 2 contrast ratio between two opaque colors, from 1 to 21.
+func ContrastRatio(a, b color.Color) float64 {
+	la, lb := RelativeLuminance(a), RelativeLuminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// MinContrastRatio is the lowest foreground/background contrast scanners reliably read.
+const MinContrastRatio = 3.0
+
+// ForegroundContrast returns the lowest contrast between the foreground paint (the gradient
+// when set, else the foreground color) or the eye color and the background. Translucent
+// colors are composited over the background, and the background over white.
+func ForegroundContrast(foreground string, gradient *models.Gradient, eyeColor, background string) (float64, error) {
+	backdrop, err := backdropColor(background)
+	if err != nil {
+		return 0, err
+	}
+
+	var contrast float64
+	if gradient != nil {
+		if contrast, err = GradientMinContrast(gradient, backdrop); err != nil {
+			return 0, err
+		}
+	} else {
+		fg, err := ParseHexColor(foreground, color.Black)
+		if err != nil {
+			return 0, err
+		}
+		contrast = ContrastRatio(Composite(fg, backdrop), backdrop)
+	}
+
+	if eyeColor != "" {
+		eye, err := ParseHexColor(eyeColor, nil)
+		if err != nil {
+			return 0, err
+		}
+		contrast = math.Min(contrast, ContrastRatio(Composite(eye, backdrop), backdrop))
+	}
+	return contrast, nil
+}
+
+// IsInverted reports whether the foreground is lighter than the background on average,
+// i.e. light modules on a dark background, which many scanners cannot read.
+func IsInverted(foreground string, gradient *models.Gradient, background string) (bool, error) {
+	backdrop, err := backdropColor(background)
+	if err != nil {
+		return false, err
+	}
+
+	colors := []string{foreground}
+	if gradient != nil {
+		colors = colors[:0]
+		for _, stop := range gradient.Stops {
+			colors = append(colors, stop.Color)
+		}
+	}
+
+	var total float64
+	for _, hex := range colors {
+		c, err := ParseHexColor(hex, color.Black)
+		if err != nil {
+			return false, err
+		}
+		total += RelativeLuminance(Composite(c, backdrop))
+	}
+	return total/float64(len(colors)) > RelativeLuminance(backdrop), nil
+}
+
+// backdropColor returns the opaque color the symbol is seen on, assuming transparent
+// backgrounds are printed or displayed on white.
+func backdropColor(background string) (color.Color, error) {
+	bg, err := ParseHexColor(background, color.White)
+	if err != nil {
+		return nil, err
+	}
+	return Composite(bg, color.White), nil
+}
+
+// gradientSamplesPerSegment is the number of colors checked between two adjacent stops.
+const gradientSamplesPerSegment = 16
+
+// GradientMinContrast returns the lowest contrast ratio between any color along the
+// gradient and the backdrop, sampling between stops since interpolated colors can
+// have less contrast than either stop.
+func GradientMinContrast(g *models.Gradient, backdrop color.Color) (float64, error) {
+	if len(g.Stops) == 0 {
+		return 0, errors.New("gradient has no stops")
+	}
+
+	stops := make([]color.NRGBA, len(g.Stops))
+	for i, stop := range g.Stops {
+		c, err := ParseHexColor(stop.Color, nil)
+		if err != nil {
+			return 0, err
+		}
+		stops[i] = c.(color.NRGBA)
+	}
+
+	lowest := ContrastRatio(Composite(stops[0], backdrop), backdrop)
+	for i := 1; i < len(stops); i++ {
+		for s := 1; s <= gradientSamplesPerSegment; s++ {
+			c := lerpColor(stops[i-1], stops[i], float64(s)/gradientSamplesPerSegment)
+			lowest = math.Min(lowest, ContrastRatio(Composite(c, backdrop), backdrop))
+		}
+	}
+	return lowest, nil
+}
+
+// lerpColor interpolates between two colors in straight sRGB, as the renderers do.
+func lerpColor(a, b color.NRGBA, t float64) color.NRGBA {
+	lerp := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x) + (float64(y)-float64(x))*t))
+	}
+	return color.NRGBA{R: lerp(a.R, b.R), G: lerp(a.G, b.G), B: lerp(a.B, b.B), A: lerp(a.A, b.A)}
+}
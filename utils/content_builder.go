@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mca93/qrcode_service/models"
+)
+
+// placeholderEscaper escapes a placeholder value given the text that precedes it.
+type placeholderEscaper func(preceding, value string) string
+
+// BuildContent assembles the encoded content of a QR code from its Data using the given mapping.
+func BuildContent(mapping *models.ContentMapping, qr *models.QRCode) (string, error) {
+	if mapping == nil || mapping.Format == "" || mapping.Format == models.ContentFormatDeepLink {
+		return qr.DeepLinkURL, nil
+	}
+
+	switch mapping.Format {
+	case models.ContentFormatURL:
+		return ExpandPlaceholders(mapping.Template, placeholderLookup(qr), escapeURLPlaceholder)
+	case models.ContentFormatKeyValue:
+		return buildKeyValueContent(mapping, qr.Data)
+	case models.ContentFormatJSON:
+		return buildJSONContent(mapping, qr.Data)
+	default:
+		return "", fmt.Errorf("unsupported content format: %s", mapping.Format)
+	}
+}
+
+// PlaceholderNames returns the names of the {placeholders} found in text, in order of appearance.
+func PlaceholderNames(text string) ([]string, error) {
+	var names []string
+	_, err := ExpandPlaceholders(text, func(name string) (string, error) {
+		names = append(names, name)
+		return "", nil
+	}, nil)
+	return names, err
+}
+
+// ExpandPlaceholders replaces every {name} in text with the value returned by lookup.
+// "{{" and "}}" produce literal braces. When escape is nil values are inserted verbatim.
+func ExpandPlaceholders(text string, lookup func(name string) (string, error), escape placeholderEscaper) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case ch == '{' && i+1 < len(text) && text[i+1] == '{':
+			out.WriteByte('{')
+			i++
+		case ch == '}' && i+1 < len(text) && text[i+1] == '}':
+			out.WriteByte('}')
+			i++
+		case ch == '{':
+			end := strings.IndexByte(text[i+1:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated placeholder at position %d", i)
+			}
+			name := strings.TrimSpace(text[i+1 : i+1+end])
+			if name == "" {
+				return "", fmt.Errorf("empty placeholder at position %d", i)
+			}
+			value, err := lookup(name)
+			if err != nil {
+				return "", err
+			}
+			if escape != nil {
+				value = escape(out.String(), value)
+			}
+			out.WriteString(value)
+			i += end + 1
+		case ch == '}':
+			return "", fmt.Errorf("unexpected '}' at position %d", i)
+		default:
+			out.WriteByte(ch)
+		}
+	}
+	return out.String(), nil
+}
+
+// placeholderLookup resolves placeholders against the QR code and its Data.
+func placeholderLookup(qr *models.QRCode) func(name string) (string, error) {
+	return func(name string) (string, error) {
+		switch name {
+		case models.PlaceholderQRCodeID:
+			return qr.ID, nil
+		case models.PlaceholderDeepLinkURL:
+			return qr.DeepLinkURL, nil
+		}
+		value, ok := qr.Data[name]
+		if !ok || value == nil {
+			return "", fmt.Errorf("missing value for placeholder {%s}", name)
+		}
+		return formatDataValue(value), nil
+	}
+}
+
+// expandOptionalPlaceholders fills the placeholders of display text, such as captions and
+// titles, from the QR code. Optional fields without a value leave their placeholder blank.
+func expandOptionalPlaceholders(text string, qr *models.QRCode) (string, error) {
+	lookup := placeholderLookup(qr)
+	return ExpandPlaceholders(text, func(name string) (string, error) {
+		value, err := lookup(name)
+		if err != nil {
+			return "", nil
+		}
+		return value, nil
+	}, nil)
+}
+
+// escapeURLPlaceholder escapes a value for the URL component it is substituted into.
+func escapeURLPlaceholder(preceding, value string) string {
+	if strings.Contains(preceding, "#") {
+		return url.PathEscape(value)
+	}
+	if strings.Contains(preceding, "?") {
+		return url.QueryEscape(value)
+	}
+	return url.PathEscape(value)
+}
+
+// formatDataValue renders a Data value as text, printing whole numbers without decimals.
+func formatDataValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(encoded)
+	}
+}
+
+// contentFields returns the Data keys to encode, in order.
+func contentFields(mapping *models.ContentMapping, data models.JSONMap) []string {
+	if len(mapping.Fields) > 0 {
+		return mapping.Fields
+	}
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func buildKeyValueContent(mapping *models.ContentMapping, data models.JSONMap) (string, error) {
+	separator := mapping.Separator
+	if separator == "" {
+		separator = ";"
+	}
+	escaper := strings.NewReplacer(`\`, `\\`, "=", `\=`, separator, `\`+separator)
+
+	pairs := make([]string, 0, len(data))
+	for _, key := range contentFields(mapping, data) {
+		value, ok := data[key]
+		if !ok || value == nil {
+			continue
+		}
+		pairs = append(pairs, escaper.Replace(key)+"="+escaper.Replace(formatDataValue(value)))
+	}
+	return strings.Join(pairs, separator), nil
+}
+
+func buildJSONContent(mapping *models.ContentMapping, data models.JSONMap) (string, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	written := 0
+	for _, key := range contentFields(mapping, data) {
+		value, ok := data[key]
+		if !ok || value == nil {
+			continue
+		}
+		encodedKey, err := json.Marshal(key)
+		if err != nil {
+			return "", err
+		}
+		encodedValue, err := json.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode field %s: %w", key, err)
+		}
+		if written > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(encodedKey)
+		buf.WriteByte(':')
+		buf.Write(encodedValue)
+		written++
+	}
+	buf.WriteByte('}')
+	return buf.String(), nil
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildContentURLEscapesPlaceholders(t *testing.T) {
+	qr := &models.QRCode{
+		ID:   "abc",
+		Data: models.JSONMap{"sku": "A/B 1", "lot": "x&y=z", "qty": 3.0},
+	}
+	mapping := &models.ContentMapping{
+		Format:   models.ContentFormatURL,
+		Template: "https://shop.example/p/{sku}?lot={lot}&n={qty}&id={qr.id}",
+	}
+
+	content, err := BuildContent(mapping, qr)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://shop.example/p/A%2FB%201?lot=x%26y%3Dz&n=3&id=abc", content)
+}
+
+func TestBuildContentMissingPlaceholder(t *testing.T) {
+	qr := &models.QRCode{Data: models.JSONMap{}}
+	mapping := &models.ContentMapping{Format: models.ContentFormatURL, Template: "https://shop.example/p/{sku}"}
+
+	_, err := BuildContent(mapping, qr)
+	assert.EqualError(t, err, "missing value for placeholder {sku}")
+}
+
+func TestBuildContentKeyValueAndJSON(t *testing.T) {
+	qr := &models.QRCode{Data: models.JSONMap{"sku": "a;b", "lot": "7"}}
+
+	content, err := BuildContent(&models.ContentMapping{Format: models.ContentFormatKeyValue, Fields: []string{"sku", "lot"}}, qr)
+	assert.NoError(t, err)
+	assert.Equal(t, `sku=a\;b;lot=7`, content)
+
+	content, err = BuildContent(&models.ContentMapping{Format: models.ContentFormatJSON, Fields: []string{"lot", "sku"}}, qr)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"lot":"7","sku":"a;b"}`, content)
+}
@@ -1,45 +1,89 @@
 package utils
 
 import (
-    "crypto/aes"
-    "crypto/cipher"
-    "crypto/rand"
-    "encoding/base64"
-    "io"
-)
-
-var secretKey = []byte("a_very_secret_key_123!") // 24 bytes (AES-192)
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
 
-func Encrypt(text string) (string, error) {
-    block, err := aes.NewCipher(secretKey)
-    if err != nil {
-        return "", err
-    }
+	"github.com/mca93/qrcode_service/config"
+	"github.com/mca93/qrcode_service/models"
+)
 
-    b := []byte(text)
-    ciphertext := make([]byte, aes.BlockSize+len(b))
-    iv := ciphertext[:aes.BlockSize]
-    if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-        return "", err
-    }
+// ErrInvalidCiphertext is returned for ciphertexts that are malformed, were tampered with or
+// were encrypted for other additional data.
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
 
-    stream := cipher.NewCFBEncrypter(block, iv)
-    stream.XORKeyStream(ciphertext[aes.BlockSize:], b)
+// Encrypt encrypts plaintext with AES-256-GCM under the current encryption key of the key
+// store. additionalData is authenticated but not encrypted; Decrypt must be given the same.
+// The result names the key, "<kid>.<nonce and ciphertext>", so it decrypts after rotation.
+func Encrypt(plaintext, additionalData []byte) (string, error) {
+	if config.Keys == nil {
+		return "", errors.New("key store is not open")
+	}
+	key, err := config.Keys.Current(models.KeyPurposeEncryption)
+	if err != nil {
+		return "", err
+	}
+	return sealWithKey(key.ID, key.Material, plaintext, additionalData)
+}
 
-    return base64.URLEncoding.EncodeToString(ciphertext), nil
+// Decrypt decrypts a ciphertext returned by Encrypt, with the key version it names.
+func Decrypt(ciphertext string, additionalData []byte) ([]byte, error) {
+	if config.Keys == nil {
+		return nil, errors.New("key store is not open")
+	}
+	kid, _, ok := strings.Cut(ciphertext, ".")
+	if !ok {
+		return nil, ErrInvalidCiphertext
+	}
+	key, err := config.Keys.Key(models.KeyPurposeEncryption, kid)
+	if err != nil {
+		return nil, err
+	}
+	return openWithKey(key.Material, ciphertext, additionalData)
 }
 
-func Decrypt(cryptoText string) (string, error) {
-    ciphertext, _ := base64.URLEncoding.DecodeString(cryptoText)
-    block, err := aes.NewCipher(secretKey)
-    if err != nil {
-        return "", err
-    }
+func sealWithKey(kid string, key, plaintext, additionalData []byte) (string, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, additionalData)
+	return kid + "." + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
 
-    iv := ciphertext[:aes.BlockSize]
-    ciphertext = ciphertext[aes.BlockSize:]
-    stream := cipher.NewCFBDecrypter(block, iv)
+func openWithKey(key []byte, ciphertext string, additionalData []byte) ([]byte, error) {
+	_, encoded, _ := strings.Cut(ciphertext, ".")
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return plaintext, nil
+}
 
-    stream.XORKeyStream(ciphertext, ciphertext)
-    return string(ciphertext), nil
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
 }
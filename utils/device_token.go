@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// NewDeviceToken issues the token a staff device authenticates with, "<deviceID>.<secret>",
+// and returns it with the hash of its secret, which is all that is stored.
+func NewDeviceToken(deviceID string) (token, secretHash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	return deviceID + "." + encoded, hashSecret(encoded), nil
+}
+
+// ParseDeviceToken splits a device token into the device ID and its secret.
+func ParseDeviceToken(token string) (deviceID, secret string, ok bool) {
+	deviceID, secret, ok = strings.Cut(token, ".")
+	return deviceID, secret, ok && deviceID != "" && secret != ""
+}
+
+// CheckDeviceSecret reports whether secret hashes to secretHash, in constant time.
+func CheckDeviceSecret(secret, secretHash string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(secretHash)) == 1
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/mca93/qrcode_service/config"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomedium"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/gofont/gosmallcaps"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	DefaultFont  = "go-bold" // Bundled font captions use when the frame names none
+	MaxFontBytes = 10 << 20  // Largest font file uploaded
+)
+
+// ErrFontTooLarge is returned for font files over MaxFontBytes.
+var ErrFontTooLarge = fmt.Errorf("font must be at most %d MB", MaxFontBytes>>20)
+
+// bundledFonts are the TTF fonts shipped with the service, by the names frames refer to them with.
+var bundledFonts = map[string][]byte{
+	"go-regular":     goregular.TTF,
+	"go-medium":      gomedium.TTF,
+	"go-bold":        gobold.TTF,
+	"go-italic":      goitalic.TTF,
+	"go-bold-italic": gobolditalic.TTF,
+	"go-mono":        gomono.TTF,
+	"go-mono-bold":   gomonobold.TTF,
+	"go-smallcaps":   gosmallcaps.TTF,
+}
+
+// IsBundledFont reports whether name is one of the bundled fonts.
+func IsBundledFont(name string) bool {
+	_, ok := bundledFonts[name]
+	return ok
+}
+
+// BundledFontNames returns the names of the bundled fonts in alphabetical order.
+func BundledFontNames() []string {
+	names := make([]string, 0, len(bundledFonts))
+	for name := range bundledFonts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadFont parses the uploaded font stored at ref, or else the bundled font name.
+func loadFont(name, ref string) (*sfnt.Font, error) {
+	if ref != "" {
+		data, err := config.Storage.Get(context.Background(), AssetKey(ref))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read font file: %w", err)
+		}
+		return parseFont(data)
+	}
+
+	if name == "" {
+		name = DefaultFont
+	}
+	data, ok := bundledFonts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown font: %s", name)
+	}
+	return sfnt.Parse(data)
+}
+
+func parseFont(data []byte) (*sfnt.Font, error) {
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font file: %w", err)
+	}
+	return f, nil
+}
+
+// layoutText walks the glyphs of text at size pixels, calling glyph with each glyph's pen
+// position, and returns the advance width of the whole text.
+func layoutText(f *sfnt.Font, text string, size float64, glyph func(index sfnt.GlyphIndex, x float64) error) (float64, error) {
+	var buf sfnt.Buffer
+	ppem := fixed.Int26_6(math.Round(size * 64))
+	x := 0.0
+	var previous sfnt.GlyphIndex
+	for i, r := range []rune(text) {
+		// Characters the font lacks map to glyph 0, drawn as its missing-glyph box
+		index, err := f.GlyphIndex(&buf, r)
+		if err != nil {
+			return 0, err
+		}
+		if i > 0 {
+			// Fonts without kerning report an error, which is not one for us
+			if kern, err := f.Kern(&buf, previous, index, ppem, font.HintingNone); err == nil {
+				x += float64(kern) / 64
+			}
+		}
+		if glyph != nil {
+			if err := glyph(index, x); err != nil {
+				return 0, err
+			}
+		}
+		advance, err := f.GlyphAdvance(&buf, index, ppem, font.HintingNone)
+		if err != nil {
+			return 0, err
+		}
+		x += float64(advance) / 64
+		previous = index
+	}
+	return x, nil
+}
+
+// measureText returns the advance width of text at size pixels.
+func measureText(f *sfnt.Font, text string, size float64) (float64, error) {
+	return layoutText(f, text, size, nil)
+}
+
+// textMetrics returns the ascent and descent of f at size pixels, both positive.
+func textMetrics(f *sfnt.Font, size float64) (float64, float64, error) {
+	var buf sfnt.Buffer
+	metrics, err := f.Metrics(&buf, fixed.Int26_6(math.Round(size*64)), font.HintingNone)
+	if err != nil {
+		return 0, 0, err
+	}
+	return float64(metrics.Ascent) / 64, float64(metrics.Descent) / 64, nil
+}
+
+// drawText fills the outlines of text at size pixels with its baseline starting at (x, y).
+// Drawing glyphs as paths gives SVG output the same lettering as PNG without embedding fonts.
+func drawText(c canvas, f *sfnt.Font, text string, x, y, size float64, p paint) error {
+	var buf sfnt.Buffer
+	ppem := fixed.Int26_6(math.Round(size * 64))
+	_, err := layoutText(f, text, size, func(index sfnt.GlyphIndex, gx float64) error {
+		segments, err := f.LoadGlyph(&buf, index, ppem, nil)
+		if err != nil {
+			return err
+		}
+		point := func(p fixed.Point26_6) (float64, float64) {
+			return x + gx + float64(p.X)/64, y + float64(p.Y)/64
+		}
+
+		open := false
+		for _, segment := range segments {
+			x0, y0 := point(segment.Args[0])
+			x1, y1 := point(segment.Args[1])
+			x2, y2 := point(segment.Args[2])
+			switch segment.Op {
+			case sfnt.SegmentOpMoveTo:
+				if open {
+					c.closePath()
+				}
+				c.moveTo(x0, y0)
+				open = true
+			case sfnt.SegmentOpLineTo:
+				c.lineTo(x0, y0)
+			case sfnt.SegmentOpQuadTo:
+				c.quadraticTo(x0, y0, x1, y1)
+			case sfnt.SegmentOpCubeTo:
+				c.cubicTo(x0, y0, x1, y1, x2, y2)
+			}
+		}
+		if open {
+			c.closePath()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c.fill(p, false)
+	return nil
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mca93/qrcode_service/models"
+)
+
+// GS1 application identifiers supported in Digital Link URIs.
+const (
+	GS1AIGTIN   = "01"
+	GS1AIBatch  = "10"
+	GS1AIExpiry = "17"
+	GS1AISerial = "21"
+)
+
+// gs1AIAliases maps the convenience alphas accepted in non-canonical URIs to their AI.
+var gs1AIAliases = map[string]string{
+	"gtin": GS1AIGTIN,
+	"lot":  GS1AIBatch,
+	"ser":  GS1AISerial,
+	"exp":  GS1AIExpiry,
+}
+
+// gs1AlphanumericRegex matches the GS1 AI encodable character set 82, up to 20 characters.
+var gs1AlphanumericRegex = regexp.MustCompile(`^[!"%&'()*+,\-./0-9:;<=>?A-Z_a-z]{1,20}$`)
+
+var gtinRegex = regexp.MustCompile(`^([0-9]{8}|[0-9]{12,14})$`)
+
+// ValidGTIN reports whether gtin is a GTIN-8, -12, -13 or -14 with a correct check digit.
+func ValidGTIN(gtin string) bool {
+	if !gtinRegex.MatchString(gtin) {
+		return false
+	}
+	sum := 0
+	for i := len(gtin) - 2; i >= 0; i-- {
+		digit := int(gtin[i] - '0')
+		// Weights alternate 3, 1, 3, ... starting from the digit left of the check digit
+		if (len(gtin)-2-i)%2 == 0 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return (10-sum%10)%10 == int(gtin[len(gtin)-1]-'0')
+}
+
+// ValidGS1Alphanumeric reports whether value can be carried by AI 10 or AI 21.
+func ValidGS1Alphanumeric(value string) bool {
+	return gs1AlphanumericRegex.MatchString(value)
+}
+
+// normalizeGTIN left-pads a GTIN to 14 digits as required by canonical Digital Link URIs.
+func normalizeGTIN(gtin string) string {
+	return strings.Repeat("0", 14-len(gtin)) + gtin
+}
+
+// GS1DigitalLinkPath returns the canonical path of a Digital Link, e.g. /01/09506000134352/10/ABC.
+func GS1DigitalLinkPath(link *models.GS1DigitalLink) string {
+	path := "/" + GS1AIGTIN + "/" + normalizeGTIN(link.GTIN)
+	if link.Batch != "" {
+		path += "/" + GS1AIBatch + "/" + percentEncode(link.Batch)
+	}
+	if link.Serial != "" {
+		path += "/" + GS1AISerial + "/" + percentEncode(link.Serial)
+	}
+	return path
+}
+
+// formatGS1DigitalLink builds the full Digital Link URI, carrying the expiry date as a data attribute.
+func formatGS1DigitalLink(link *models.GS1DigitalLink) string {
+	domain := link.Domain
+	if domain == "" {
+		domain = models.DeepLinkBaseURL()
+	}
+
+	uri := strings.TrimRight(domain, "/") + GS1DigitalLinkPath(link)
+	if link.Expiry != "" {
+		if expiry, err := time.Parse("2006-01-02", link.Expiry); err == nil {
+			uri += "?" + GS1AIExpiry + "=" + expiry.Format("060102")
+		}
+	}
+	return uri
+}
+
+// ParseGS1DigitalLinkPath parses an incoming Digital Link path, which may use convenience
+// alphas, non-padded GTINs or a different qualifier order, and returns its canonical form.
+func ParseGS1DigitalLinkPath(path string) (string, error) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) < 2 || len(segments)%2 != 0 {
+		return "", errors.New("digital link path must consist of AI/value pairs")
+	}
+
+	values := make(map[string]string)
+	for i := 0; i < len(segments); i += 2 {
+		ai := segments[i]
+		if alias, ok := gs1AIAliases[ai]; ok {
+			ai = alias
+		}
+		if i == 0 && ai != GS1AIGTIN {
+			return "", errors.New("digital link path must start with a GTIN (AI 01)")
+		}
+		value, err := url.PathUnescape(segments[i+1])
+		if err != nil {
+			return "", fmt.Errorf("invalid value for AI %s: %w", ai, err)
+		}
+		if _, duplicate := values[ai]; duplicate {
+			return "", fmt.Errorf("duplicate AI in digital link path: %s", ai)
+		}
+		values[ai] = value
+	}
+
+	for ai, value := range values {
+		switch ai {
+		case GS1AIGTIN:
+			if !ValidGTIN(value) {
+				return "", errors.New("invalid GTIN in digital link path")
+			}
+		case GS1AIBatch, GS1AISerial:
+			if !ValidGS1Alphanumeric(value) {
+				return "", fmt.Errorf("invalid value for AI %s", ai)
+			}
+		default:
+			return "", fmt.Errorf("unsupported AI in digital link path: %s", ai)
+		}
+	}
+
+	return GS1DigitalLinkPath(&models.GS1DigitalLink{
+		GTIN:   values[GS1AIGTIN],
+		Batch:  values[GS1AIBatch],
+		Serial: values[GS1AISerial],
+	}), nil
+}
+
+// GS1DigitalLinkFallbacks returns a canonical path followed by its less specific forms,
+// dropping key qualifiers from the end, so /01/x/10/y/21/z also tries /01/x/10/y and /01/x.
+func GS1DigitalLinkFallbacks(canonicalPath string) []string {
+	paths := []string{canonicalPath}
+	segments := strings.Split(strings.Trim(canonicalPath, "/"), "/")
+	for len(segments) > 2 {
+		segments = segments[:len(segments)-2]
+		paths = append(paths, "/"+strings.Join(segments, "/"))
+	}
+	return paths
+}
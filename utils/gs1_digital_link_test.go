@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidGTIN(t *testing.T) {
+	assert.True(t, ValidGTIN("09506000134352"))
+	assert.True(t, ValidGTIN("9506000134352"))
+	assert.True(t, ValidGTIN("96385074"))
+	assert.True(t, ValidGTIN("036000291452"))
+	assert.False(t, ValidGTIN("9506000134353"))
+	assert.False(t, ValidGTIN("950600013435"))
+	assert.False(t, ValidGTIN("95060001343A2"))
+}
+
+func TestFormatGS1DigitalLink(t *testing.T) {
+	payload, err := FormatPayload(models.QRContentTypeGS1, &models.QRContent{GS1: &models.GS1DigitalLink{
+		Domain: "https://id.example.com/",
+		GTIN:   "9506000134352",
+		Batch:  "AB/12",
+		Serial: "12345",
+		Expiry: "2027-03-31",
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://id.example.com/01/09506000134352/10/AB%2F12/21/12345?17=270331", payload)
+}
+
+func TestParseGS1DigitalLinkPath(t *testing.T) {
+	path, err := ParseGS1DigitalLinkPath("/gtin/9506000134352/ser/12345/lot/AB%2F12")
+	assert.NoError(t, err)
+	assert.Equal(t, "/01/09506000134352/10/AB%2F12/21/12345", path)
+
+	assert.Equal(t, []string{
+		"/01/09506000134352/10/AB%2F12/21/12345",
+		"/01/09506000134352/10/AB%2F12",
+		"/01/09506000134352",
+	}, GS1DigitalLinkFallbacks(path))
+
+	_, err = ParseGS1DigitalLinkPath("/01/9506000134353")
+	assert.Error(t, err)
+	_, err = ParseGS1DigitalLinkPath("/10/ABC/01/9506000134352")
+	assert.Error(t, err)
+	_, err = ParseGS1DigitalLinkPath("/01/9506000134352/22/ABC")
+	assert.Error(t, err)
+}
@@ -0,0 +1,259 @@
+package utils
+
+import (
+	"bytes"
+	_ "embed" // Landing page layouts
+	"fmt"
+	"html/template"
+	"image/color"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/mca93/qrcode_service/models"
+)
+
+// minAccentContrast is the contrast accent colours need against the page background; below it
+// headings and links use the text colour.
+const minAccentContrast = 3.0
+
+//go:embed landing_page.html
+var landingPageHTML string
+
+var landingPageTemplate = template.Must(template.New("landing").Funcs(template.FuncMap{
+	"safeURL": safeLandingURL,
+}).Parse(landingPageHTML))
+
+// phonePattern matches phone numbers written in international format, e.g. +351 912 345 678.
+var phonePattern = regexp.MustCompile(`^\+[0-9][0-9 ().-]{5,}[0-9]$`)
+
+// BuildLandingPage resolves the template's landing page for a QR code: the fields to show with
+// their labels and display values, and the page colours.
+func BuildLandingPage(qr *models.QRCode, tmpl *models.Template) (*models.LandingPageView, error) {
+	page := tmpl.LandingPage
+	if page == nil {
+		page = &models.LandingPage{}
+	}
+	// Landing pages are public: sensitive values are masked in the title and never listed
+	masked := *qr
+	masked.Data = MaskSensitiveData(qr.Data)
+	qr = &masked
+
+	view := &models.LandingPageView{QRCodeID: qr.ID, Layout: page.Layout, Fields: []models.LandingPageField{}}
+	if view.Layout == "" {
+		view.Layout = models.LandingLayoutSheet
+	}
+
+	var err error
+	view.Title = tmpl.Name
+	if page.Title != "" {
+		if view.Title, err = expandOptionalPlaceholders(page.Title, qr); err != nil {
+			return nil, fmt.Errorf("invalid landing page title: %w", err)
+		}
+	}
+	if view.Subtitle, err = expandOptionalPlaceholders(page.Subtitle, qr); err != nil {
+		return nil, fmt.Errorf("invalid landing page subtitle: %w", err)
+	}
+
+	fields := make(map[string]models.Field, len(tmpl.Definition))
+	names := page.Fields
+	for _, field := range tmpl.Definition {
+		fields[field.Name] = field
+		if len(page.Fields) == 0 {
+			names = append(names, field.Name)
+		}
+	}
+	for _, name := range names {
+		field, ok := fields[name]
+		value, exists := qr.Data[name]
+		if !ok || !exists || value == nil || field.Sensitive || !field.IsVisible(qr.Data) {
+			continue
+		}
+		if f, ok := landingPageField(qr, field, value, page.Labels[name]); ok {
+			view.Fields = append(view.Fields, f)
+		}
+	}
+
+	view.Colors, err = landingPageColors(page, tmpl)
+	if err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+// landingPageField resolves how a value of the QR code's data is shown, or reports false for
+// values with nothing to show.
+func landingPageField(qr *models.QRCode, field models.Field, value interface{}, label string) (models.LandingPageField, bool) {
+	if label == "" {
+		label = fieldLabel(field.Name)
+	}
+	f := models.LandingPageField{Name: field.Name, Label: label, Kind: models.LandingFieldText, Value: value}
+
+	if field.Type == models.FieldTypeMedia {
+		ref, err := models.ParseMediaReference(value)
+		if err != nil || !ref.BelongsTo(qr.ID) {
+			return f, false
+		}
+		ref.URL = qr.MediaURL(field.Name)
+		f.Media, f.Link, f.Text = ref, ref.URL, label
+		switch {
+		case strings.HasPrefix(ref.ContentType, "image/"):
+			f.Kind = models.LandingFieldImage
+		case strings.HasPrefix(ref.ContentType, "video/"):
+			f.Kind = models.LandingFieldVideo
+		case strings.HasPrefix(ref.ContentType, "audio/"):
+			f.Kind = models.LandingFieldAudio
+		default:
+			f.Kind = models.LandingFieldFile
+		}
+		return f, true
+	}
+
+	f.Text = strings.TrimSpace(formatDataValue(value))
+	if f.Text == "" {
+		return f, false
+	}
+	if link := textLink(f.Text); link != "" {
+		f.Kind, f.Link = models.LandingFieldLink, link
+	}
+	return f, true
+}
+
+// textLink returns the URL a text value links to: web addresses, email addresses and phone
+// numbers in international format. Other text has none.
+func textLink(text string) string {
+	if u, err := url.Parse(text); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		return u.String()
+	}
+	if addr, err := mail.ParseAddress(text); err == nil && addr.Name == "" && !strings.ContainsAny(text, "<>") {
+		return "mailto:" + addr.Address
+	}
+	if phonePattern.MatchString(text) {
+		return "tel:" + strings.Map(func(r rune) rune {
+			if r == '+' || unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, text)
+	}
+	return ""
+}
+
+// safeLandingURL marks links built by textLink and media URLs as safe for href and src
+// attributes; html/template would otherwise refuse tel: URLs.
+func safeLandingURL(link string) template.URL {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "#"
+	}
+	switch u.Scheme {
+	case "http", "https", "mailto", "tel":
+		return template.URL(u.String())
+	default:
+		return "#"
+	}
+}
+
+// fieldLabel turns a field name such as "refundPolicy" or "refund_policy" into "Refund policy".
+func fieldLabel(name string) string {
+	var words []string
+	var word []rune
+	for i, r := range name {
+		switch {
+		case r == '_' || r == '-' || r == ' ':
+			words, word = appendWord(words, word), nil
+		case unicode.IsUpper(r) && i > 0 && len(word) > 0 && !unicode.IsUpper(word[len(word)-1]):
+			words, word = appendWord(words, word), []rune{unicode.ToLower(r)}
+		default:
+			word = append(word, r)
+		}
+	}
+	words = appendWord(words, word)
+	label := strings.Join(words, " ")
+	if label == "" {
+		return name
+	}
+	runes := []rune(label)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+func appendWord(words []string, word []rune) []string {
+	if len(word) == 0 {
+		return words
+	}
+	return append(words, string(word))
+}
+
+// landingPageColors picks the page colours: the page's own, or else the template's, with black
+// or white text, whichever contrasts more with the background.
+func landingPageColors(page *models.LandingPage, tmpl *models.Template) (models.LandingPageColors, error) {
+	background := page.BackgroundColor
+	if background == "" {
+		background = tmpl.BackgroundColor
+	}
+	bg, err := ParseHexColor(background, color.White)
+	if err != nil {
+		return models.LandingPageColors{}, err
+	}
+	bg = Composite(bg, color.White)
+
+	accent := page.AccentColor
+	if accent == "" {
+		accent = tmpl.ForegroundColor
+		if g := tmpl.ForegroundGradient; g != nil && len(g.Stops) > 0 {
+			accent = g.Stops[0].Color
+		}
+	}
+	fg, err := ParseHexColor(accent, color.Black)
+	if err != nil {
+		return models.LandingPageColors{}, err
+	}
+	fg = Composite(fg, bg)
+
+	text := contrastingTextColor(bg)
+	if ContrastRatio(fg, bg) < minAccentContrast {
+		fg = text
+	}
+	return models.LandingPageColors{Accent: cssHexColor(fg), Background: cssHexColor(bg), Text: cssHexColor(text)}, nil
+}
+
+func cssHexColor(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02X%02X%02X", n.R, n.G, n.B)
+}
+
+// landingPageData is what the layouts in landing_page.html are executed with.
+type landingPageData struct {
+	*models.LandingPageView
+	Accent, Background, Text template.CSS
+	Hero                     *models.LandingPageField // First image, shown on top by the product and card layouts
+	Rest                     []models.LandingPageField
+}
+
+// RenderLandingPage renders a landing page as an HTML document.
+func RenderLandingPage(view *models.LandingPageView) ([]byte, error) {
+	// The colours were formatted by cssHexColor, so they are safe in the stylesheet
+	data := landingPageData{
+		LandingPageView: view,
+		Accent:          template.CSS(view.Colors.Accent),
+		Background:      template.CSS(view.Colors.Background),
+		Text:            template.CSS(view.Colors.Text),
+		Rest:            view.Fields,
+	}
+	for i, field := range view.Fields {
+		if field.Kind == models.LandingFieldImage {
+			data.Hero = &view.Fields[i]
+			data.Rest = append(append([]models.LandingPageField{}, view.Fields[:i]...), view.Fields[i+1:]...)
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := landingPageTemplate.ExecuteTemplate(&buf, "page", data); err != nil {
+		return nil, fmt.Errorf("failed to render landing page: %w", err)
+	}
+	return buf.Bytes(), nil
+}
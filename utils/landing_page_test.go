@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func landingPageQRCode() (*models.QRCode, *models.Template) {
+	template := &models.Template{
+		Name:            "Product",
+		ForegroundColor: "#FFEE58", // Too light to read on white
+		BackgroundColor: "#FFFFFF",
+		Definition: models.Definition{
+			{Name: "name", Type: models.FieldTypeText},
+			{Name: "photo", Type: models.FieldTypeMedia},
+			{Name: "supportPhone", Type: models.FieldTypeText},
+			{Name: "website", Type: models.FieldTypeText},
+			{Name: "discontinued", Type: models.FieldTypeText},
+			{Name: "replacement", Type: models.FieldTypeText, VisibleIf: &models.FieldCondition{Field: "discontinued", Value: "yes"}},
+		},
+		LandingPage: &models.LandingPage{
+			Layout: models.LandingLayoutProduct,
+			Title:  "{name}",
+			Fields: []string{"photo", "supportPhone", "website", "replacement"},
+			Labels: map[string]string{"website": "Product page"},
+		},
+	}
+	qr := &models.QRCode{
+		ID: "qr-1",
+		Data: models.JSONMap{
+			"name":         "Kettle <script>alert(1)</script>",
+			"photo":        map[string]interface{}{"asset": "media/qr-1/photo/a.png", "contentType": "image/png", "size": 500.0},
+			"supportPhone": "+351 912 345 678",
+			"website":      "https://example.com/kettle",
+			"discontinued": "no",
+			"replacement":  "Kettle 2",
+		},
+	}
+	return qr, template
+}
+
+func TestBuildLandingPage(t *testing.T) {
+	qr, template := landingPageQRCode()
+	view, err := BuildLandingPage(qr, template)
+	assert.NoError(t, err)
+	assert.Equal(t, "Kettle <script>alert(1)</script>", view.Title)
+	assert.Equal(t, models.LandingLayoutProduct, view.Layout)
+
+	// The replacement is hidden while the product is sold
+	if assert.Len(t, view.Fields, 3) {
+		assert.Equal(t, models.LandingFieldImage, view.Fields[0].Kind)
+		assert.True(t, strings.HasSuffix(view.Fields[0].Link, "/media/photo"))
+		assert.Equal(t, "Support phone", view.Fields[1].Label)
+		assert.Equal(t, "tel:+351912345678", view.Fields[1].Link)
+		assert.Equal(t, "Product page", view.Fields[2].Label)
+		assert.Equal(t, models.LandingFieldLink, view.Fields[2].Kind)
+	}
+
+	assert.Equal(t, "#FFFFFF", view.Colors.Background)
+	assert.Equal(t, "#000000", view.Colors.Text)
+	assert.Equal(t, view.Colors.Text, view.Colors.Accent)
+
+	// Media uploaded for another QR code is not shown
+	qr.Data["photo"] = map[string]interface{}{"asset": "media/qr-2/photo/a.png", "contentType": "image/png"}
+	view, err = BuildLandingPage(qr, template)
+	assert.NoError(t, err)
+	assert.Len(t, view.Fields, 2)
+}
+
+func TestRenderLandingPage(t *testing.T) {
+	qr, template := landingPageQRCode()
+	view, err := BuildLandingPage(qr, template)
+	assert.NoError(t, err)
+	page, err := RenderLandingPage(view)
+	assert.NoError(t, err)
+
+	html := string(page)
+	assert.NotContains(t, html, "<script>")
+	assert.Contains(t, html, "Kettle &lt;script&gt;")
+	assert.Contains(t, html, `href="tel:&#43;351912345678"`)
+	assert.Contains(t, html, `class="hero"`)
+
+	// Only web, email and phone links are kept
+	assert.Equal(t, "#", string(safeLandingURL("javascript:alert(1)")))
+}
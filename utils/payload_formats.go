@@ -0,0 +1,344 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mca93/qrcode_service/models"
+)
+
+const (
+	crlf               = "\r\n"
+	maxContentLineSize = 75 // RFC 6350 / RFC 5545 line length in octets
+)
+
+// FormatPayload returns the string encoded in the symbol for a typed content payload.
+func FormatPayload(contentType models.QRContentType, content *models.QRContent) (string, error) {
+	if content == nil {
+		return "", fmt.Errorf("content is required for content type %s", contentType)
+	}
+
+	switch contentType {
+	case models.QRContentTypeVCard:
+		if content.Contact != nil {
+			return formatVCard(content.Contact), nil
+		}
+	case models.QRContentTypeMeCard:
+		if content.Contact != nil {
+			return formatMeCard(content.Contact), nil
+		}
+	case models.QRContentTypeWiFi:
+		if content.WiFi != nil {
+			return formatWiFi(content.WiFi), nil
+		}
+	case models.QRContentTypeEmail:
+		if content.Email != nil {
+			return formatMailto(content.Email), nil
+		}
+	case models.QRContentTypeSMS:
+		if content.SMS != nil {
+			return formatSMS(content.SMS), nil
+		}
+	case models.QRContentTypeTel:
+		if content.Tel != nil {
+			return "tel:" + NormalizePhoneNumber(content.Tel.Number), nil
+		}
+	case models.QRContentTypeGeo:
+		if content.Geo != nil {
+			return formatGeo(content.Geo), nil
+		}
+	case models.QRContentTypeEvent:
+		if content.Event != nil {
+			return formatEvent(content.Event), nil
+		}
+	case models.QRContentTypeEPC:
+		if content.SEPA != nil {
+			return formatSEPAPayment(content.SEPA), nil
+		}
+	case models.QRContentTypeEMVCo:
+		if content.EMVCo != nil {
+			return formatEMVCoPayment(content.EMVCo), nil
+		}
+	case models.QRContentTypeGS1:
+		if content.GS1 != nil {
+			return formatGS1DigitalLink(content.GS1), nil
+		}
+	default:
+		return "", fmt.Errorf("unsupported content type: %s", contentType)
+	}
+	return "", fmt.Errorf("content for content type %s is missing", contentType)
+}
+
+// NormalizePhoneNumber strips the visual separators that are not allowed in tel/sms URIs.
+func NormalizePhoneNumber(number string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(number))
+}
+
+// ---------- vCard / iCalendar ----------
+
+// textEscaper escapes TEXT values in vCard and iCalendar content lines.
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// uriEscaper escapes the line breaks of URI values, whose commas and semicolons are not escaped.
+var uriEscaper = strings.NewReplacer("\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// vCardPhoneTypes are the TYPE values of vCard TEL properties (RFC 2426 and RFC 6350).
+var vCardPhoneTypes = map[string]bool{
+	"CELL": true, "FAX": true, "HOME": true, "PAGER": true, "TEXT": true, "TEXTPHONE": true,
+	"VIDEO": true, "VOICE": true, "WORK": true,
+}
+
+// ValidPhoneType reports whether t, in any case, is a vCard phone type.
+func ValidPhoneType(t string) bool {
+	return vCardPhoneTypes[strings.ToUpper(t)]
+}
+
+// contentLines joins content lines with CRLF, folding each at 75 octets.
+type contentLines []string
+
+func (l *contentLines) add(line string) {
+	*l = append(*l, line)
+}
+
+func (l contentLines) String() string {
+	var b strings.Builder
+	for i, line := range l {
+		if i > 0 {
+			b.WriteString(crlf)
+		}
+		b.WriteString(foldContentLine(line))
+	}
+	return b.String()
+}
+
+// foldContentLine splits a line longer than 75 octets into CRLF + space continuations
+// without breaking multi-byte UTF-8 sequences.
+func foldContentLine(line string) string {
+	if len(line) <= maxContentLineSize {
+		return line
+	}
+	var b strings.Builder
+	limit := maxContentLineSize
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString(crlf + " ")
+		line = line[cut:]
+		limit = maxContentLineSize - 1 // the leading space counts towards the limit
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+func formatVCard(c *models.ContactContent) string {
+	v4 := c.Version == "4.0"
+	version := "3.0"
+	if v4 {
+		version = "4.0"
+	}
+
+	lines := contentLines{"BEGIN:VCARD", "VERSION:" + version}
+	lines.add("N:" + textEscaper.Replace(c.LastName) + ";" + textEscaper.Replace(c.FirstName) + ";;;")
+	lines.add("FN:" + textEscaper.Replace(strings.TrimSpace(c.FirstName+" "+c.LastName)))
+	if c.Organization != "" {
+		lines.add("ORG:" + textEscaper.Replace(c.Organization))
+	}
+	if c.Title != "" {
+		lines.add("TITLE:" + textEscaper.Replace(c.Title))
+	}
+	for _, phone := range c.Phones {
+		number := uriEscaper.Replace(NormalizePhoneNumber(phone.Number))
+		// Types are parameters, which cannot be escaped, so unknown ones are left out
+		hasType := ValidPhoneType(phone.Type)
+		switch {
+		case v4 && hasType:
+			lines.add("TEL;VALUE=uri;TYPE=" + strings.ToLower(phone.Type) + ":tel:" + number)
+		case v4:
+			lines.add("TEL;VALUE=uri:tel:" + number)
+		case hasType:
+			lines.add("TEL;TYPE=" + strings.ToUpper(phone.Type) + ":" + number)
+		default:
+			lines.add("TEL:" + number)
+		}
+	}
+	for _, email := range c.Emails {
+		if v4 {
+			lines.add("EMAIL:" + textEscaper.Replace(email))
+		} else {
+			lines.add("EMAIL;TYPE=INTERNET:" + textEscaper.Replace(email))
+		}
+	}
+	if c.URL != "" {
+		lines.add("URL:" + uriEscaper.Replace(c.URL))
+	}
+	if a := c.Address; a != nil {
+		lines.add("ADR:;;" + strings.Join([]string{
+			textEscaper.Replace(a.Street), textEscaper.Replace(a.City), textEscaper.Replace(a.Region),
+			textEscaper.Replace(a.PostalCode), textEscaper.Replace(a.Country),
+		}, ";"))
+	}
+	if c.Birthday != "" {
+		lines.add("BDAY:" + strings.ReplaceAll(c.Birthday, "-", ""))
+	}
+	if c.Note != "" {
+		lines.add("NOTE:" + textEscaper.Replace(c.Note))
+	}
+	lines.add("END:VCARD")
+	return lines.String()
+}
+
+func formatEvent(e *models.EventContent) string {
+	lines := contentLines{"BEGIN:VEVENT"}
+	lines.add("SUMMARY:" + textEscaper.Replace(e.Summary))
+	if e.AllDay {
+		lines.add("DTSTART;VALUE=DATE:" + e.Start.Format("20060102"))
+		if e.End != nil {
+			lines.add("DTEND;VALUE=DATE:" + e.End.Format("20060102"))
+		}
+	} else {
+		lines.add("DTSTART:" + e.Start.UTC().Format("20060102T150405Z"))
+		if e.End != nil {
+			lines.add("DTEND:" + e.End.UTC().Format("20060102T150405Z"))
+		}
+	}
+	if e.Location != "" {
+		lines.add("LOCATION:" + textEscaper.Replace(e.Location))
+	}
+	if e.Description != "" {
+		lines.add("DESCRIPTION:" + textEscaper.Replace(e.Description))
+	}
+	lines.add("END:VEVENT")
+	return lines.String()
+}
+
+// ---------- MeCard / WiFi ----------
+
+// meCardEscaper escapes the reserved characters of MeCard and WiFi fields.
+var meCardEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, ":", `\:`, `"`, `\"`)
+
+func formatMeCard(c *models.ContactContent) string {
+	var b strings.Builder
+	b.WriteString("MECARD:N:" + meCardEscaper.Replace(c.LastName) + "," + meCardEscaper.Replace(c.FirstName) + ";")
+	if c.Organization != "" {
+		b.WriteString("ORG:" + meCardEscaper.Replace(c.Organization) + ";")
+	}
+	for _, phone := range c.Phones {
+		b.WriteString("TEL:" + meCardEscaper.Replace(NormalizePhoneNumber(phone.Number)) + ";")
+	}
+	for _, email := range c.Emails {
+		b.WriteString("EMAIL:" + meCardEscaper.Replace(email) + ";")
+	}
+	if c.URL != "" {
+		b.WriteString("URL:" + meCardEscaper.Replace(c.URL) + ";")
+	}
+	if a := c.Address; a != nil {
+		parts := make([]string, 0, 5)
+		for _, part := range []string{a.Street, a.City, a.Region, a.PostalCode, a.Country} {
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		b.WriteString("ADR:" + meCardEscaper.Replace(strings.Join(parts, " ")) + ";")
+	}
+	if c.Birthday != "" {
+		b.WriteString("BDAY:" + strings.ReplaceAll(c.Birthday, "-", "") + ";")
+	}
+	if c.Note != "" {
+		b.WriteString("NOTE:" + meCardEscaper.Replace(c.Note) + ";")
+	}
+	b.WriteString(";")
+	return b.String()
+}
+
+func formatWiFi(w *models.WiFiContent) string {
+	security := w.Security
+	if security == "" {
+		security = models.WiFiSecurityWPA
+	}
+
+	var b strings.Builder
+	b.WriteString("WIFI:T:" + security + ";S:" + meCardEscaper.Replace(w.SSID) + ";")
+	if security != models.WiFiSecurityNoPass {
+		b.WriteString("P:" + meCardEscaper.Replace(w.Password) + ";")
+	}
+	if w.Hidden {
+		b.WriteString("H:true;")
+	}
+	b.WriteString(";")
+	return b.String()
+}
+
+// ---------- URIs ----------
+
+// percentEncode encodes everything except RFC 3986 unreserved characters,
+// so spaces become %20 rather than "+".
+func percentEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ('A' <= ch && ch <= 'Z') || ('a' <= ch && ch <= 'z') || ('0' <= ch && ch <= '9') ||
+			ch == '-' || ch == '.' || ch == '_' || ch == '~' {
+			b.WriteByte(ch)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", ch)
+	}
+	return b.String()
+}
+
+// normalizeNewlines converts bare LF and CR to CRLF, as required for mailto bodies.
+func normalizeNewlines(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
+
+func formatMailto(e *models.EmailContent) string {
+	var params []string
+	if e.Subject != "" {
+		params = append(params, "subject="+percentEncode(e.Subject))
+	}
+	if e.Body != "" {
+		params = append(params, "body="+percentEncode(normalizeNewlines(e.Body)))
+	}
+
+	uri := "mailto:" + e.To
+	if len(params) > 0 {
+		uri += "?" + strings.Join(params, "&")
+	}
+	return uri
+}
+
+func formatSMS(s *models.SMSContent) string {
+	uri := "sms:" + NormalizePhoneNumber(s.Number)
+	if s.Message != "" {
+		uri += "?body=" + percentEncode(s.Message)
+	}
+	return uri
+}
+
+func formatGeo(g *models.GeoContent) string {
+	uri := "geo:" + formatCoordinate(g.Latitude) + "," + formatCoordinate(g.Longitude)
+	if g.Altitude != nil {
+		uri += "," + formatCoordinate(*g.Altitude)
+	}
+	if g.Query != "" {
+		uri += "?q=" + percentEncode(g.Query)
+	}
+	return uri
+}
+
+func formatCoordinate(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
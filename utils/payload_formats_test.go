@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatPayloadWiFiEscapesSpecialCharacters(t *testing.T) {
+	content := &models.QRContent{WiFi: &models.WiFiContent{SSID: `Cafe;"Net"`, Password: `p:a,s\s`}}
+
+	payload, err := FormatPayload(models.QRContentTypeWiFi, content)
+	assert.NoError(t, err)
+	assert.Equal(t, `WIFI:T:WPA;S:Cafe\;\"Net\";P:p\:a\,s\\s;;`, payload)
+}
+
+func TestFormatPayloadVCard(t *testing.T) {
+	content := &models.QRContent{Contact: &models.ContactContent{
+		FirstName:    "Ana",
+		LastName:     "Silva, Jr.",
+		Organization: "ACME; Lda",
+		Phones:       []models.ContactPhone{{Type: "cell", Number: "+258 84 123 4567"}},
+		Emails:       []string{"ana@example.com"},
+	}}
+
+	payload, err := FormatPayload(models.QRContentTypeVCard, content)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"BEGIN:VCARD",
+		"VERSION:3.0",
+		`N:Silva\, Jr.;Ana;;;`,
+		`FN:Ana Silva\, Jr.`,
+		`ORG:ACME\; Lda`,
+		"TEL;TYPE=CELL:+258841234567",
+		"EMAIL;TYPE=INTERNET:ana@example.com",
+		"END:VCARD",
+	}, "\r\n"), payload)
+}
+
+func TestFormatPayloadVCardIgnoresUnknownPhoneTypes(t *testing.T) {
+	content := &models.QRContent{Contact: &models.ContactContent{
+		FirstName: "Ana",
+		Phones:    []models.ContactPhone{{Type: "work:+1\r\nNOTE:injected", Number: "+258841234567"}},
+	}}
+
+	payload, err := FormatPayload(models.QRContentTypeVCard, content)
+	assert.NoError(t, err)
+	assert.Contains(t, payload, "\r\nTEL:+258841234567\r\n")
+	assert.NotContains(t, payload, "injected")
+}
+
+func TestFoldContentLine(t *testing.T) {
+	folded := foldContentLine("NOTE:" + strings.Repeat("é", 60))
+	for _, line := range strings.Split(folded, "\r\n") {
+		assert.LessOrEqual(t, len(line), maxContentLineSize)
+	}
+	assert.Equal(t, "NOTE:"+strings.Repeat("é", 60), strings.ReplaceAll(folded, "\r\n ", ""))
+}
+
+func TestFormatPayloadURIs(t *testing.T) {
+	payload, err := FormatPayload(models.QRContentTypeEmail, &models.QRContent{Email: &models.EmailContent{
+		To: "info@example.com", Subject: "Hello & welcome", Body: "Line 1\nLine 2",
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, "mailto:info@example.com?subject=Hello%20%26%20welcome&body=Line%201%0D%0ALine%202", payload)
+
+	payload, err = FormatPayload(models.QRContentTypeGeo, &models.QRContent{Geo: &models.GeoContent{
+		Latitude: -25.9692, Longitude: 32.5732, Query: "Maputo",
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, "geo:-25.9692,32.5732?q=Maputo", payload)
+
+	start := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	payload, err = FormatPayload(models.QRContentTypeEvent, &models.QRContent{Event: &models.EventContent{
+		Summary: "Launch; party", Start: start,
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, "BEGIN:VEVENT\r\nSUMMARY:Launch\\; party\r\nDTSTART:20261019T100000Z\r\nEND:VEVENT", payload)
+}
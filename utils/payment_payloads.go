@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/mca93/qrcode_service/models"
+)
+
+// ibanLengths maps IBAN country codes to the total IBAN length.
+var ibanLengths = map[string]int{
+	"AD": 24, "AE": 23, "AL": 28, "AT": 20, "AZ": 28, "BA": 20, "BE": 16, "BG": 22, "BH": 22, "BR": 29,
+	"BY": 28, "CH": 21, "CR": 22, "CY": 28, "CZ": 24, "DE": 22, "DK": 18, "DO": 28, "EE": 20, "EG": 29,
+	"ES": 24, "FI": 18, "FO": 18, "FR": 27, "GB": 22, "GE": 22, "GI": 23, "GL": 18, "GR": 27, "GT": 28,
+	"HR": 21, "HU": 28, "IE": 22, "IL": 23, "IQ": 23, "IS": 26, "IT": 27, "JO": 30, "KW": 30, "KZ": 20,
+	"LB": 28, "LC": 32, "LI": 21, "LT": 20, "LU": 20, "LV": 21, "MC": 27, "MD": 24, "ME": 22, "MK": 19,
+	"MR": 27, "MT": 31, "MU": 30, "NL": 18, "NO": 15, "PK": 24, "PL": 28, "PS": 29, "PT": 25, "QA": 29,
+	"RO": 24, "RS": 22, "SA": 24, "SC": 31, "SE": 24, "SI": 19, "SK": 24, "SM": 27, "ST": 25, "SV": 28,
+	"TL": 23, "TN": 24, "TR": 26, "UA": 29, "VA": 22, "VG": 24, "XK": 20,
+}
+
+// NormalizeIBAN removes spaces and upper-cases an IBAN.
+func NormalizeIBAN(iban string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(iban), " ", ""))
+}
+
+// ValidIBAN reports whether iban has the right length for its country and a valid ISO 7064 mod 97-10 checksum.
+func ValidIBAN(iban string) bool {
+	iban = NormalizeIBAN(iban)
+	if len(iban) < 4 || ibanLengths[iban[:2]] != len(iban) {
+		return false
+	}
+	return mod97(iban[4:]+iban[:4]) == 1
+}
+
+// ValidCreditorReference reports whether ref is a valid ISO 11649 structured creditor reference.
+func ValidCreditorReference(ref string) bool {
+	ref = NormalizeIBAN(ref)
+	if len(ref) < 5 || len(ref) > 25 || !strings.HasPrefix(ref, "RF") {
+		return false
+	}
+	return mod97(ref[4:]+ref[:4]) == 1
+}
+
+// mod97 converts letters to numbers (A=10 ... Z=35) and returns the remainder modulo 97,
+// or -1 if s contains characters other than ASCII letters and digits.
+func mod97(s string) int {
+	var digits strings.Builder
+	for _, ch := range s {
+		switch {
+		case ch >= '0' && ch <= '9':
+			digits.WriteRune(ch)
+		case ch >= 'A' && ch <= 'Z':
+			digits.WriteString(strconv.Itoa(int(ch-'A') + 10))
+		default:
+			return -1
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	if !ok {
+		return -1
+	}
+	return int(new(big.Int).Mod(n, big.NewInt(97)).Int64())
+}
+
+// CRC16CCITT computes CRC-16/CCITT-FALSE (polynomial 0x1021, initial value 0xFFFF)
+// as required by the EMVCo payload checksum.
+func CRC16CCITT(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// formatAmount prints an amount with exactly two decimals.
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// ---------- EPC069-12 ----------
+
+// formatSEPAPayment builds an EPC069-12 version 002 payload, omitting trailing empty lines.
+func formatSEPAPayment(p *models.SEPAPayment) string {
+	amount := ""
+	if p.Amount != nil {
+		amount = "EUR" + formatAmount(*p.Amount)
+	}
+
+	lines := []string{
+		"BCD",                               // Service tag
+		"002",                               // Version
+		"1",                                 // Character set: UTF-8
+		"SCT",                               // Identification: SEPA credit transfer
+		strings.ToUpper(p.BIC),              // BIC of the beneficiary bank
+		strings.TrimSpace(p.Name),           // Name of the beneficiary
+		NormalizeIBAN(p.IBAN),               // Account of the beneficiary
+		amount,                              // Amount of the transfer
+		strings.ToUpper(p.Purpose),          // Purpose of the transfer
+		NormalizeIBAN(p.Reference),          // Structured remittance information
+		strings.TrimSpace(p.RemittanceText), // Unstructured remittance information
+		strings.TrimSpace(p.Information),    // Beneficiary to originator information
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ---------- EMVCo merchant-presented mode ----------
+
+// EMVCo data object IDs.
+const (
+	emvPayloadFormatIndicator = "00"
+	emvPointOfInitiation      = "01"
+	emvMerchantCategoryCode   = "52"
+	emvTransactionCurrency    = "53"
+	emvTransactionAmount      = "54"
+	emvCountryCode            = "58"
+	emvMerchantName           = "59"
+	emvMerchantCity           = "60"
+	emvPostalCode             = "61"
+	emvAdditionalData         = "62"
+	emvCRC                    = "63"
+)
+
+// emvTLV encodes a data object as ID, two-digit length and value.
+func emvTLV(id, value string) string {
+	return fmt.Sprintf("%s%02d%s", id, len(value), value)
+}
+
+// formatEMVCoPayment builds an EMVCo merchant-presented payload with its CRC.
+func formatEMVCoPayment(p *models.EMVCoPayment) string {
+	var b strings.Builder
+	b.WriteString(emvTLV(emvPayloadFormatIndicator, "01"))
+	if p.PointOfInitiation == models.EMVCoInitiationDynamic {
+		b.WriteString(emvTLV(emvPointOfInitiation, "12"))
+	} else {
+		b.WriteString(emvTLV(emvPointOfInitiation, "11"))
+	}
+
+	for _, account := range p.MerchantAccounts {
+		if account.GUID == "" && len(account.Fields) == 0 {
+			b.WriteString(emvTLV(account.ID, account.Value))
+			continue
+		}
+		var template strings.Builder
+		if account.GUID != "" {
+			template.WriteString(emvTLV("00", account.GUID))
+		}
+		for _, field := range account.Fields {
+			template.WriteString(emvTLV(field.ID, field.Value))
+		}
+		b.WriteString(emvTLV(account.ID, template.String()))
+	}
+
+	b.WriteString(emvTLV(emvMerchantCategoryCode, p.MerchantCategoryCode))
+	b.WriteString(emvTLV(emvTransactionCurrency, p.Currency))
+	if p.Amount != nil {
+		b.WriteString(emvTLV(emvTransactionAmount, formatEMVCoAmount(*p.Amount)))
+	}
+	b.WriteString(emvTLV(emvCountryCode, strings.ToUpper(p.CountryCode)))
+	b.WriteString(emvTLV(emvMerchantName, p.MerchantName))
+	b.WriteString(emvTLV(emvMerchantCity, p.MerchantCity))
+	if p.PostalCode != "" {
+		b.WriteString(emvTLV(emvPostalCode, p.PostalCode))
+	}
+	if additional := formatEMVCoAdditionalData(p.AdditionalData); additional != "" {
+		b.WriteString(emvTLV(emvAdditionalData, additional))
+	}
+
+	// The CRC covers the whole payload including its own ID and length
+	b.WriteString(emvCRC + "04")
+	return b.String() + fmt.Sprintf("%04X", CRC16CCITT([]byte(b.String())))
+}
+
+// formatEMVCoAmount prints an amount without trailing zeros, e.g. "10" or "10.5".
+func formatEMVCoAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
+
+func formatEMVCoAdditionalData(d *models.EMVCoAdditionalData) string {
+	if d == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, field := range []models.EMVCoField{
+		{ID: "01", Value: d.BillNumber},
+		{ID: "02", Value: d.MobileNumber},
+		{ID: "03", Value: d.StoreLabel},
+		{ID: "04", Value: d.LoyaltyNumber},
+		{ID: "05", Value: d.ReferenceLabel},
+		{ID: "06", Value: d.CustomerLabel},
+		{ID: "07", Value: d.TerminalLabel},
+		{ID: "08", Value: d.Purpose},
+	} {
+		if field.Value != "" {
+			b.WriteString(emvTLV(field.ID, field.Value))
+		}
+	}
+	return b.String()
+}
+
+// EMVCoPayloadLength returns the length of the encoded EMVCo payload.
+func EMVCoPayloadLength(p *models.EMVCoPayment) int {
+	return len(formatEMVCoPayment(p))
+}
+
+// SEPAPayloadLength returns the length in bytes of the encoded EPC payload.
+func SEPAPayloadLength(p *models.SEPAPayment) int {
+	return len(formatSEPAPayment(p))
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksums(t *testing.T) {
+	assert.Equal(t, uint16(0x29B1), CRC16CCITT([]byte("123456789")))
+
+	assert.True(t, ValidIBAN("GB82 WEST 1234 5698 7654 32"))
+	assert.True(t, ValidIBAN("DE89370400440532013000"))
+	assert.False(t, ValidIBAN("DE89370400440532013001"))
+	assert.False(t, ValidIBAN("DE8937040044053201300"))
+
+	assert.True(t, ValidCreditorReference("RF18 5390 0754 7034"))
+	assert.False(t, ValidCreditorReference("RF19539007547034"))
+}
+
+func TestFormatSEPAPayment(t *testing.T) {
+	amount := 12.3
+	payload, err := FormatPayload(models.QRContentTypeEPC, &models.QRContent{SEPA: &models.SEPAPayment{
+		Name:           "Red Cross",
+		IBAN:           "BE72 0000 0001 6116",
+		BIC:            "bpotbeb1",
+		Amount:         &amount,
+		RemittanceText: "Donation",
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, "BCD\n002\n1\nSCT\nBPOTBEB1\nRed Cross\nBE72000000016116\nEUR12.30\n\n\nDonation", payload)
+}
+
+func TestFormatEMVCoPayment(t *testing.T) {
+	payload, err := FormatPayload(models.QRContentTypeEMVCo, &models.QRContent{EMVCo: &models.EMVCoPayment{
+		MerchantAccounts: []models.EMVCoMerchantAccount{
+			{ID: "26", GUID: "com.example.pay", Fields: []models.EMVCoField{{ID: "01", Value: "123456"}}},
+		},
+		MerchantCategoryCode: "5812",
+		Currency:             "943",
+		CountryCode:          "mz",
+		MerchantName:         "Cafe Central",
+		MerchantCity:         "Maputo",
+	}})
+	assert.NoError(t, err)
+
+	body := "000201010211" + "2629" + "0015com.example.pay" + "0106123456" +
+		"52045812" + "5303943" + "5802MZ" + "5912Cafe Central" + "6006Maputo" + "6304"
+	assert.True(t, strings.HasPrefix(payload, body))
+	assert.Len(t, payload, len(body)+4)
+	assert.Equal(t, fmt.Sprintf("%04X", CRC16CCITT([]byte(body))), payload[len(body):])
+}
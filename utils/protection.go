@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	_ "embed" // PIN and password prompt
+	"encoding/hex"
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+//go:embed unlock_page.html
+var unlockPageHTML string
+
+var unlockPageTemplate = template.Must(template.New("unlock").Parse(unlockPageHTML))
+
+// HashProtectionSecret returns the bcrypt hash a QR code's PIN or password is stored as.
+func HashProtectionSecret(secret string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// CheckProtectionSecret reports whether secret is the PIN or password hashed to hash.
+func CheckProtectionSecret(secret, hash string) bool {
+	return hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) == nil
+}
+
+// UnlockCookieName is the cookie that holds the unlock token of a QR code.
+func UnlockCookieName(qrCodeID string) string {
+	return "qr_unlock_" + qrCodeID
+}
+
+// NewUnlockToken returns the token that lets a scanner open a protected QR code until expires,
+// "<kid>.<expires>.<signature>". Changing the PIN or password invalidates it.
+func NewUnlockToken(keys storage.SigningKeys, qr *models.QRCode, expires time.Time) (string, error) {
+	kid, key, err := keys.Current()
+	if err != nil {
+		return "", err
+	}
+	unix := strconv.FormatInt(expires.Unix(), 10)
+	return kid + "." + unix + "." + signUnlockToken(key, qr, unix), nil
+}
+
+// CheckUnlockToken reports whether token unlocks the QR code at now.
+func CheckUnlockToken(keys storage.SigningKeys, qr *models.QRCode, token string, now time.Time) bool {
+	parts := strings.SplitN(token, ".", 3)
+	if len(parts) != 3 {
+		return false
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return false
+	}
+	key, err := keys.Key(parts[0])
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(parts[2]), []byte(signUnlockToken(key, qr, parts[1])))
+}
+
+func signUnlockToken(key []byte, qr *models.QRCode, expires string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(qr.ID + "\n" + expires + "\n" + qr.ProtectionHash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// UnlockClientHash identifies the client guessing a PIN or password without storing its address.
+func UnlockClientHash(clientIP string) string {
+	sum := sha256.Sum256([]byte("unlock\n" + clientIP))
+	return hex.EncodeToString(sum[:16])
+}
+
+// RenderUnlockPage renders the prompt for the PIN or password of a protected QR code.
+func RenderUnlockPage(view models.UnlockPageView) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := unlockPageTemplate.Execute(&buf, view); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
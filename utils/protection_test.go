@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnlockToken(t *testing.T) {
+	hash, err := HashProtectionSecret("4821")
+	assert.NoError(t, err)
+	assert.True(t, CheckProtectionSecret("4821", hash))
+	assert.False(t, CheckProtectionSecret("4822", hash))
+
+	keys := storage.StaticKey("secret")
+	qr := &models.QRCode{ID: "qr-1", Protection: models.ProtectionTypePIN, ProtectionHash: hash}
+	now := time.Now()
+	token, err := NewUnlockToken(keys, qr, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, CheckUnlockToken(keys, qr, token, now))
+	assert.False(t, CheckUnlockToken(keys, qr, token, now.Add(time.Minute)))
+	assert.False(t, CheckUnlockToken(keys, &models.QRCode{ID: "qr-2", ProtectionHash: hash}, token, now))
+
+	// Changing the PIN locks scanners out again
+	qr.ProtectionHash, _ = HashProtectionSecret("1111")
+	assert.False(t, CheckUnlockToken(keys, qr, token, now))
+}
+
+func TestRenderUnlockPage(t *testing.T) {
+	page, err := RenderUnlockPage(models.UnlockPageView{
+		QRCodeID: "qr-1",
+		Type:     models.ProtectionTypePIN,
+		Action:   "/qrcodes/qr-1/unlock",
+		Next:     `/01/09506000134352"><script>`,
+		Error:    "Wrong PIN",
+	})
+	assert.NoError(t, err)
+	html := string(page)
+	assert.Contains(t, html, `inputmode="numeric"`)
+	assert.Contains(t, html, "Wrong PIN")
+	assert.False(t, strings.Contains(html, "<script>"))
+}
@@ -0,0 +1,568 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mca93/qrcode_service/models"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// DecodedQRCode is a QR code read back from an image.
+type DecodedQRCode struct {
+	Content         string                       `json:"content"`
+	Version         int                          `json:"version"`
+	ErrorCorrection models.QRCodeErrorCorrection `json:"errorCorrection"`
+	ErrorsCorrected int                          `json:"errorsCorrected"` // Codewords restored by error correction
+}
+
+var (
+	errInvalidFormatInfo = errors.New("unreadable format information")
+	errInvalidBitstream  = errors.New("malformed QR code data")
+)
+
+// Error correction levels in the order of the tables below, and their two format information bits.
+var (
+	eccLevels     = []models.QRCodeErrorCorrection{models.ErrorCorrectionL, models.ErrorCorrectionM, models.ErrorCorrectionQ, models.ErrorCorrectionH}
+	eccFormatBits = []int{1, 0, 3, 2}
+)
+
+// eccCodewordsPerBlock and eccBlockCount are indexed by level (L, M, Q, H) and version (1-40), per ISO/IEC 18004 table 9.
+var eccCodewordsPerBlock = [4][41]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var eccBlockCount = [4][41]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// alignmentPositions returns the row and column coordinates of the alignment pattern centres of a version.
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	count := version/7 + 2
+	step := (version*4 + count*2 + 1) / (count*2 - 2) * 2
+	if version == 32 {
+		step = 26
+	}
+	positions := make([]int, count)
+	positions[0] = 6
+	for i, pos := count-1, version*4+10; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+// rawDataModules returns the number of modules of a version that hold codewords and remainder bits.
+func rawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		count := version/7 + 2
+		result -= (25*count-10)*count - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+// functionModules marks the finder, separator, timing, alignment, format and version modules of a version.
+func functionModules(version int) moduleGrid {
+	dim := 17 + 4*version
+	grid := make(moduleGrid, dim)
+	for y := range grid {
+		grid[y] = make([]bool, dim)
+	}
+	fill := func(x0, y0, w, h int) {
+		for y := y0; y < y0+h; y++ {
+			for x := x0; x < x0+w; x++ {
+				if x >= 0 && y >= 0 && x < dim && y < dim {
+					grid[y][x] = true
+				}
+			}
+		}
+	}
+
+	// Finders with separators and format information, and the dark module
+	fill(0, 0, 9, 9)
+	fill(dim-8, 0, 8, 9)
+	fill(0, dim-8, 9, 8)
+	// Timing patterns
+	fill(6, 0, 1, dim)
+	fill(0, 6, dim, 1)
+
+	positions := alignmentPositions(version)
+	last := len(positions) - 1
+	for i, ay := range positions {
+		for j, ax := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue // overlaps a finder
+			}
+			fill(ax-2, ay-2, 5, 5)
+		}
+	}
+
+	if version >= 7 {
+		fill(dim-11, 0, 3, 6)
+		fill(0, dim-11, 6, 3)
+	}
+	return grid
+}
+
+// formatInfoBits returns the masked 15-bit format information for an error correction level and mask.
+func formatInfoBits(eccIndex, mask int) int {
+	data := eccFormatBits[eccIndex]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// versionInfoBits returns the 18-bit version information of versions 7 and up.
+func versionInfoBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return version<<12 | rem
+}
+
+// readFormatInfo returns the error correction level index and mask from the two copies of the
+// format information, accepting up to three wrong bits.
+func readFormatInfo(grid moduleGrid) (int, int, error) {
+	dim := grid.dimension()
+	bit := func(x, y int) int {
+		if grid[y][x] {
+			return 1
+		}
+		return 0
+	}
+
+	first, second := 0, 0
+	for i := 0; i <= 5; i++ {
+		first |= bit(8, i) << i
+	}
+	first |= bit(8, 7)<<6 | bit(8, 8)<<7 | bit(7, 8)<<8
+	for i := 9; i < 15; i++ {
+		first |= bit(14-i, 8) << i
+	}
+	for i := 0; i < 8; i++ {
+		second |= bit(dim-1-i, 8) << i
+	}
+	for i := 8; i < 15; i++ {
+		second |= bit(8, dim-15+i) << i
+	}
+
+	bestDistance, bestECC, bestMask := 4, 0, 0
+	for ecc := range eccLevels {
+		for mask := 0; mask < 8; mask++ {
+			code := formatInfoBits(ecc, mask)
+			for _, read := range []int{first, second} {
+				if d := bits.OnesCount(uint(code ^ read)); d < bestDistance {
+					bestDistance, bestECC, bestMask = d, ecc, mask
+				}
+			}
+		}
+	}
+	if bestDistance > 3 {
+		return 0, 0, errInvalidFormatInfo
+	}
+	return bestECC, bestMask, nil
+}
+
+// readVersionInfo returns the version encoded next to the top-right and bottom-left finders,
+// or 0 when neither copy is within three bits of a valid version.
+func readVersionInfo(grid moduleGrid) int {
+	dim := grid.dimension()
+	first, second := 0, 0
+	for i := 0; i < 18; i++ {
+		a, b := dim-11+i%3, i/3
+		if grid[b][a] {
+			first |= 1 << i
+		}
+		if grid[a][b] {
+			second |= 1 << i
+		}
+	}
+
+	bestDistance, bestVersion := 4, 0
+	for version := 7; version <= 40; version++ {
+		code := versionInfoBits(version)
+		for _, read := range []int{first, second} {
+			if d := bits.OnesCount(uint(code ^ read)); d < bestDistance {
+				bestDistance, bestVersion = d, version
+			}
+		}
+	}
+	return bestVersion
+}
+
+// masked reports whether data mask pattern mask inverts the module at (x, y).
+func masked(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// decodeGrid decodes a sampled symbol whose modules are true when dark.
+func decodeGrid(grid moduleGrid) (*DecodedQRCode, error) {
+	dim := grid.dimension()
+	if dim < 21 || dim > 177 || (dim-17)%4 != 0 {
+		return nil, fmt.Errorf("invalid symbol dimension %d", dim)
+	}
+	version := (dim - 17) / 4
+	if version >= 7 && readVersionInfo(grid) != version {
+		return nil, fmt.Errorf("version information does not match dimension %d", dim)
+	}
+
+	eccIndex, mask, err := readFormatInfo(grid)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the codewords in the two-column zigzag, skipping function modules and unmasking
+	function := functionModules(version)
+	raw := make([]byte, rawDataModules(version)/8)
+	bitIndex := 0
+	for right := dim - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // skip the vertical timing pattern
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < dim; vert++ {
+			y := vert
+			if upward {
+				y = dim - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if function[y][x] || bitIndex >= len(raw)*8 {
+					continue
+				}
+				if grid[y][x] != masked(mask, x, y) {
+					raw[bitIndex>>3] |= 0x80 >> (bitIndex & 7)
+				}
+				bitIndex++
+			}
+		}
+	}
+
+	data, corrected, err := correctCodewords(raw, version, eccIndex)
+	if err != nil {
+		return nil, err
+	}
+	content, err := decodeSegments(data, version)
+	if err != nil {
+		return nil, err
+	}
+	return &DecodedQRCode{Content: content, Version: version, ErrorCorrection: eccLevels[eccIndex], ErrorsCorrected: corrected}, nil
+}
+
+// correctCodewords de-interleaves the codeword blocks, corrects each and returns the data codewords.
+func correctCodewords(raw []byte, version, eccIndex int) ([]byte, int, error) {
+	blockCount := eccBlockCount[eccIndex][version]
+	eccLen := eccCodewordsPerBlock[eccIndex][version]
+	shortBlocks := blockCount - len(raw)%blockCount
+	shortLen := len(raw) / blockCount
+	shortData := shortLen - eccLen
+
+	// Long blocks carry one more data codeword; the short blocks' slot for it is skipped
+	blocks := make([][]byte, blockCount)
+	for j := range blocks {
+		length := shortLen
+		if j >= shortBlocks {
+			length++
+		}
+		blocks[j] = make([]byte, 0, length)
+	}
+	k := 0
+	for i := 0; i <= shortLen; i++ {
+		for j := range blocks {
+			if i == shortData && j < shortBlocks {
+				continue
+			}
+			blocks[j] = append(blocks[j], raw[k])
+			k++
+		}
+	}
+
+	var data []byte
+	corrected := 0
+	for _, block := range blocks {
+		n, err := reedSolomonCorrect(block, eccLen)
+		if err != nil {
+			return nil, 0, err
+		}
+		corrected += n
+		data = append(data, block[:len(block)-eccLen]...)
+	}
+	return data, corrected, nil
+}
+
+// bitReader reads big-endian bit fields from a byte slice.
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) available() int {
+	return len(r.data)*8 - r.pos
+}
+
+func (r *bitReader) read(n int) (int, error) {
+	if n > r.available() {
+		return 0, errInvalidBitstream
+	}
+	value := 0
+	for i := 0; i < n; i++ {
+		value = value<<1 | int(r.data[r.pos>>3]>>(7-r.pos&7)&1)
+		r.pos++
+	}
+	return value, nil
+}
+
+// Segment modes
+const (
+	modeTerminator        = 0x0
+	modeNumeric           = 0x1
+	modeAlphanumeric      = 0x2
+	modeStructuredAppend  = 0x3
+	modeByte              = 0x4
+	modeFNC1First         = 0x5
+	modeECI               = 0x7
+	modeKanji             = 0x8
+	modeFNC1Second        = 0x9
+	eciUTF8               = 26
+	eciISO88591           = 3
+	alphanumericCharset   = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+	gs1GroupSeparator     = "\x1D"
+	numericDigitsPerGroup = 3
+)
+
+// charCountBits returns the length of a segment's character count field.
+func charCountBits(mode, version int) int {
+	group := 0
+	switch {
+	case version >= 27:
+		group = 2
+	case version >= 10:
+		group = 1
+	}
+	switch mode {
+	case modeNumeric:
+		return []int{10, 12, 14}[group]
+	case modeAlphanumeric:
+		return []int{9, 11, 13}[group]
+	case modeByte:
+		return []int{8, 16, 16}[group]
+	default:
+		return []int{8, 10, 12}[group]
+	}
+}
+
+// decodeSegments parses the data codewords into text. Byte segments are read as UTF-8 unless
+// an ECI designates ISO-8859-1 or the bytes are not valid UTF-8.
+func decodeSegments(data []byte, version int) (string, error) {
+	r := &bitReader{data: data}
+	var out strings.Builder
+	eci := -1
+	fnc1 := false
+
+	for r.available() >= 4 {
+		mode, _ := r.read(4)
+		switch mode {
+		case modeTerminator:
+			return out.String(), nil
+		case modeFNC1First, modeFNC1Second:
+			fnc1 = true
+			if mode == modeFNC1Second {
+				if _, err := r.read(8); err != nil {
+					return "", err
+				}
+			}
+			continue
+		case modeStructuredAppend:
+			if _, err := r.read(16); err != nil {
+				return "", err
+			}
+			continue
+		case modeECI:
+			value, err := readECI(r)
+			if err != nil {
+				return "", err
+			}
+			eci = value
+			continue
+		case modeNumeric, modeAlphanumeric, modeByte, modeKanji:
+		default:
+			return "", errInvalidBitstream
+		}
+
+		count, err := r.read(charCountBits(mode, version))
+		if err != nil {
+			return "", err
+		}
+		var segment string
+		switch mode {
+		case modeNumeric:
+			segment, err = readNumeric(r, count)
+		case modeAlphanumeric:
+			segment, err = readAlphanumeric(r, count, fnc1)
+		case modeByte:
+			segment, err = readBytes(r, count, eci)
+		case modeKanji:
+			segment, err = readKanji(r, count)
+		}
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(segment)
+	}
+	return out.String(), nil
+}
+
+func readECI(r *bitReader) (int, error) {
+	first, err := r.read(8)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case first&0x80 == 0:
+		return first, nil
+	case first&0xC0 == 0x80:
+		rest, err := r.read(8)
+		return (first&0x3F)<<8 | rest, err
+	case first&0xE0 == 0xC0:
+		rest, err := r.read(16)
+		return (first&0x1F)<<16 | rest, err
+	default:
+		return 0, errInvalidBitstream
+	}
+}
+
+func readNumeric(r *bitReader, count int) (string, error) {
+	var b strings.Builder
+	for count > 0 {
+		digits := numericDigitsPerGroup
+		if count < digits {
+			digits = count
+		}
+		value, err := r.read([]int{0, 4, 7, 10}[digits])
+		if err != nil {
+			return "", err
+		}
+		group := fmt.Sprintf("%0*d", digits, value)
+		if len(group) != digits {
+			return "", errInvalidBitstream
+		}
+		b.WriteString(group)
+		count -= digits
+	}
+	return b.String(), nil
+}
+
+// readAlphanumeric reads an alphanumeric segment. In GS1 (FNC1) symbols "%" stands for the
+// group separator and "%%" for a literal percent sign.
+func readAlphanumeric(r *bitReader, count int, fnc1 bool) (string, error) {
+	chars := make([]byte, 0, count)
+	for count > 0 {
+		if count >= 2 {
+			value, err := r.read(11)
+			if err != nil {
+				return "", err
+			}
+			if value/45 >= 45 {
+				return "", errInvalidBitstream
+			}
+			chars = append(chars, alphanumericCharset[value/45], alphanumericCharset[value%45])
+			count -= 2
+			continue
+		}
+		value, err := r.read(6)
+		if err != nil {
+			return "", err
+		}
+		if value >= 45 {
+			return "", errInvalidBitstream
+		}
+		chars = append(chars, alphanumericCharset[value])
+		count--
+	}
+
+	text := string(chars)
+	if fnc1 {
+		text = strings.ReplaceAll(text, "%%", "\x00")
+		text = strings.ReplaceAll(text, "%", gs1GroupSeparator)
+		text = strings.ReplaceAll(text, "\x00", "%")
+	}
+	return text, nil
+}
+
+func readBytes(r *bitReader, count, eci int) (string, error) {
+	raw := make([]byte, count)
+	for i := range raw {
+		value, err := r.read(8)
+		if err != nil {
+			return "", err
+		}
+		raw[i] = byte(value)
+	}
+
+	if eci == eciUTF8 || (eci != eciISO88591 && utf8.Valid(raw)) {
+		return string(raw), nil
+	}
+	// ISO-8859-1 maps each byte to the code point of the same value
+	runes := make([]rune, len(raw))
+	for i, b := range raw {
+		runes[i] = rune(b)
+	}
+	return string(runes), nil
+}
+
+func readKanji(r *bitReader, count int) (string, error) {
+	sjis := make([]byte, 0, 2*count)
+	for i := 0; i < count; i++ {
+		value, err := r.read(13)
+		if err != nil {
+			return "", err
+		}
+		assembled := (value/0xC0)<<8 | value%0xC0
+		if assembled < 0x1F00 {
+			assembled += 0x8140
+		} else {
+			assembled += 0xC140
+		}
+		sjis = append(sjis, byte(assembled>>8), byte(assembled))
+	}
+	text, err := japanese.ShiftJIS.NewDecoder().Bytes(sjis)
+	if err != nil {
+		return "", errInvalidBitstream
+	}
+	return string(text), nil
+}
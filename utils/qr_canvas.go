@@ -0,0 +1,272 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// ---------- Raster ----------
+
+// rasterCanvas draws with gg onto an RGBA image that starts fully transparent.
+type rasterCanvas struct {
+	dc *gg.Context
+}
+
+func newRasterCanvas(width, height int) *rasterCanvas {
+	return &rasterCanvas{dc: gg.NewContext(width, height)}
+}
+
+func (c *rasterCanvas) Image() image.Image {
+	return c.dc.Image()
+}
+
+func (c *rasterCanvas) moveTo(x, y float64) {
+	c.dc.MoveTo(x, y)
+}
+
+func (c *rasterCanvas) lineTo(x, y float64) {
+	c.dc.LineTo(x, y)
+}
+
+func (c *rasterCanvas) quadraticTo(cx, cy, x, y float64) {
+	c.dc.QuadraticTo(cx, cy, x, y)
+}
+
+func (c *rasterCanvas) cubicTo(c1x, c1y, c2x, c2y, x, y float64) {
+	c.dc.CubicTo(c1x, c1y, c2x, c2y, x, y)
+}
+
+func (c *rasterCanvas) closePath() {
+	c.dc.ClosePath()
+}
+
+func (c *rasterCanvas) rectangle(x, y, w, h float64) {
+	c.dc.DrawRectangle(x, y, w, h)
+}
+
+func (c *rasterCanvas) roundedRectangle(x, y, w, h, r float64) {
+	c.dc.DrawRoundedRectangle(x, y, w, h, r)
+}
+
+func (c *rasterCanvas) circle(cx, cy, r float64) {
+	c.dc.DrawCircle(cx, cy, r)
+}
+
+func (c *rasterCanvas) fill(p paint, evenOdd bool) {
+	if g := p.gradient; g != nil {
+		var pattern gg.Gradient
+		if g.Linear {
+			pattern = gg.NewLinearGradient(g.X0, g.Y0, g.X1, g.Y1)
+		} else {
+			pattern = gg.NewRadialGradient(g.X0, g.Y0, 0, g.X0, g.Y0, g.R)
+		}
+		for _, stop := range g.Stops {
+			pattern.AddColorStop(stop.Offset, stop.Color)
+		}
+		c.dc.SetFillStyle(pattern)
+	} else {
+		c.dc.SetColor(p.color)
+	}
+
+	if evenOdd {
+		c.dc.SetFillRule(gg.FillRuleEvenOdd)
+		defer c.dc.SetFillRule(gg.FillRuleWinding)
+	}
+	c.dc.Fill()
+}
+
+func (c *rasterCanvas) drawImage(img *logoImage, x, y, w, h float64) {
+	width, height := int(math.Round(w)), int(math.Round(h))
+	if width <= 0 || height <= 0 {
+		return
+	}
+	c.dc.DrawImage(img.render(width, height), int(math.Round(x)), int(math.Round(y)))
+}
+
+// ---------- SVG ----------
+
+// svgCanvas collects paths into an SVG document. Every fill emits one <path> element.
+type svgCanvas struct {
+	width     int
+	height    int
+	path      strings.Builder
+	body      strings.Builder
+	defs      strings.Builder
+	gradients map[*gradientPaint]string
+}
+
+func newSVGCanvas(width, height int) *svgCanvas {
+	return &svgCanvas{width: width, height: height, gradients: make(map[*gradientPaint]string)}
+}
+
+// String returns the complete SVG document.
+func (c *svgCanvas) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		c.width, c.height, c.width, c.height)
+	if c.defs.Len() > 0 {
+		b.WriteString("<defs>" + c.defs.String() + "</defs>")
+	}
+	b.WriteString(c.body.String())
+	b.WriteString("</svg>")
+	return b.String()
+}
+
+// svgNumber prints a coordinate rounded to two decimals without trailing zeros.
+func svgNumber(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+func (c *svgCanvas) pathf(command string, values ...float64) {
+	c.path.WriteString(command)
+	for i, v := range values {
+		if i > 0 {
+			c.path.WriteByte(' ')
+		}
+		c.path.WriteString(svgNumber(v))
+	}
+}
+
+func (c *svgCanvas) moveTo(x, y float64) {
+	c.pathf("M", x, y)
+}
+
+func (c *svgCanvas) lineTo(x, y float64) {
+	c.pathf("L", x, y)
+}
+
+func (c *svgCanvas) quadraticTo(cx, cy, x, y float64) {
+	c.pathf("Q", cx, cy, x, y)
+}
+
+func (c *svgCanvas) cubicTo(c1x, c1y, c2x, c2y, x, y float64) {
+	c.pathf("C", c1x, c1y, c2x, c2y, x, y)
+}
+
+func (c *svgCanvas) closePath() {
+	c.path.WriteString("Z")
+}
+
+func (c *svgCanvas) rectangle(x, y, w, h float64) {
+	c.pathf("M", x, y)
+	c.pathf("H", x+w)
+	c.pathf("V", y+h)
+	c.pathf("H", x)
+	c.closePath()
+}
+
+// arcTo adds a clockwise quarter arc of radius r ending at (x, y).
+func (c *svgCanvas) arcTo(r, x, y float64) {
+	c.pathf("A", r, r)
+	c.path.WriteString(" 0 0 1 ")
+	c.pathf("", x, y)
+}
+
+func (c *svgCanvas) roundedRectangle(x, y, w, h, r float64) {
+	r = math.Min(r, math.Min(w, h)/2)
+	c.pathf("M", x+r, y)
+	c.pathf("H", x+w-r)
+	c.arcTo(r, x+w, y+r)
+	c.pathf("V", y+h-r)
+	c.arcTo(r, x+w-r, y+h)
+	c.pathf("H", x+r)
+	c.arcTo(r, x, y+h-r)
+	c.pathf("V", y+r)
+	c.arcTo(r, x+r, y)
+	c.closePath()
+}
+
+func (c *svgCanvas) circle(cx, cy, r float64) {
+	// Two half-circle arcs, since a single arc cannot end where it starts
+	c.pathf("M", cx-r, cy)
+	c.pathf("A", r, r)
+	c.path.WriteString(" 0 1 0 ")
+	c.pathf("", cx+r, cy)
+	c.pathf("A", r, r)
+	c.path.WriteString(" 0 1 0 ")
+	c.pathf("", cx-r, cy)
+	c.closePath()
+}
+
+func (c *svgCanvas) fill(p paint, evenOdd bool) {
+	if c.path.Len() == 0 {
+		return
+	}
+
+	fmt.Fprintf(&c.body, `<path d="%s" %s`, c.path.String(), c.fillAttributes(p))
+	if evenOdd {
+		c.body.WriteString(` fill-rule="evenodd"`)
+	}
+	c.body.WriteString("/>")
+	c.path.Reset()
+}
+
+// fillAttributes returns the fill of a path, adding gradient definitions on first use.
+func (c *svgCanvas) fillAttributes(p paint) string {
+	g := p.gradient
+	if g == nil {
+		hex, opacity := svgColor(p.color)
+		return fmt.Sprintf(`fill="%s"%s`, hex, svgOpacity("fill-opacity", opacity))
+	}
+
+	id, ok := c.gradients[g]
+	if !ok {
+		id = fmt.Sprintf("gradient%d", len(c.gradients)+1)
+		c.gradients[g] = id
+		if g.Linear {
+			fmt.Fprintf(&c.defs, `<linearGradient id="%s" gradientUnits="userSpaceOnUse" x1="%s" y1="%s" x2="%s" y2="%s">`,
+				id, svgNumber(g.X0), svgNumber(g.Y0), svgNumber(g.X1), svgNumber(g.Y1))
+		} else {
+			fmt.Fprintf(&c.defs, `<radialGradient id="%s" gradientUnits="userSpaceOnUse" cx="%s" cy="%s" r="%s">`,
+				id, svgNumber(g.X0), svgNumber(g.Y0), svgNumber(g.R))
+		}
+		for _, stop := range g.Stops {
+			hex, opacity := svgColor(stop.Color)
+			fmt.Fprintf(&c.defs, `<stop offset="%s" stop-color="%s"%s/>`,
+				svgNumber(stop.Offset), hex, svgOpacity("stop-opacity", opacity))
+		}
+		if g.Linear {
+			c.defs.WriteString("</linearGradient>")
+		} else {
+			c.defs.WriteString("</radialGradient>")
+		}
+	}
+	return fmt.Sprintf(`fill="url(#%s)"`, id)
+}
+
+// drawImage embeds SVG logos as they are and raster logos at their own resolution.
+func (c *svgCanvas) drawImage(img *logoImage, x, y, w, h float64) {
+	mediaType, data := "image/svg+xml", img.svg
+	if data == nil {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img.raster); err != nil {
+			return
+		}
+		mediaType, data = "image/png", buf.Bytes()
+	}
+	fmt.Fprintf(&c.body, `<image x="%s" y="%s" width="%s" height="%s" preserveAspectRatio="none" xlink:href="data:%s;base64,%s"/>`,
+		svgNumber(x), svgNumber(y), svgNumber(w), svgNumber(h), mediaType, base64.StdEncoding.EncodeToString(data))
+}
+
+// svgColor splits a color into its #RRGGBB form and opacity.
+func svgColor(c color.Color) (string, float64) {
+	nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02X%02X%02X", nrgba.R, nrgba.G, nrgba.B), float64(nrgba.A) / 255
+}
+
+// svgOpacity returns an opacity attribute, or "" for fully opaque colors.
+func svgOpacity(name string, opacity float64) string {
+	if opacity >= 1 {
+		return ""
+	}
+	return fmt.Sprintf(` %s="%s"`, name, svgNumber(opacity))
+}
@@ -3,13 +3,14 @@ package utils
 import (
 	"bytes"
 	"encoding/base64"
+	"errors"
 	"fmt"
-	"image"
-	"os"
+	"image/color"
+	"image/png"
 
+	"github.com/mca93/qrcode_service/config"
 	"github.com/mca93/qrcode_service/models"
 	qrcode "github.com/yeqown/go-qrcode/v2"
-	qs "github.com/yeqown/go-qrcode/writer/standard"
 )
 
 // QRCodeService handles QR code generation for a specific QRCode instance.
@@ -28,153 +29,312 @@ func NewQRCodeService(qr *models.QRCode, template *models.Template) *QRCodeServi
 
 // GenerateBase64Image generates a base64-encoded PNG image of the QR code.
 func (s *QRCodeService) GenerateBase64Image() (string, error) {
-	dataToEncode := s.getDataToEncode()
+	return s.GenerateBase64ImageFormat(ImageFormatPNG)
+}
 
-	_, imageOptions, err := s.getQRCodeOptions()
+// GenerateBase64ImageFormat generates a base64-encoded PNG or SVG image of the QR code.
+func (s *QRCodeService) GenerateBase64ImageFormat(format ImageFormat) (string, error) {
+	imageBytes, err := s.GenerateImage(format)
 	if err != nil {
-		return "", fmt.Errorf("failed to get QR code options: %w", err)
+		return "", err
 	}
 
-	// qrCode, err := qrcode.New(dataToEncode)
-	qrCode, err := qrcode.NewWith(dataToEncode, qrcode.WithErrorCorrectionLevel(qrcode.ErrorCorrectionHighest))
+	return base64.StdEncoding.EncodeToString(imageBytes), nil
+}
+
+// GenerateImage renders the QR code in the given format.
+func (s *QRCodeService) GenerateImage(format ImageFormat) ([]byte, error) {
+	dataToEncode, err := s.getDataToEncode()
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("failed to build QR code content: %w", err)
 	}
 
-	imageBytes, err := s.generateQRCodeImage(qrCode, imageOptions)
+	style, err := s.getRenderStyle()
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("failed to get QR code options: %w", err)
 	}
+	style.Format = format
 
-	return base64.StdEncoding.EncodeToString(imageBytes), nil
+	qrCode, _, err := s.encode(dataToEncode, style.Logo)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.generateQRCodeImage(qrCode, style)
 }
 
-// getDataToEncode returns the data to be encoded in the QR code.
-func (s *QRCodeService) getDataToEncode() string {
-	if s.QRCode.DeepLinkURL != "" {
-		return s.QRCode.DeepLinkURL
+// ErrVerificationFailed is returned when a rendered QR code does not read back as its content.
+var ErrVerificationFailed = errors.New("rendered QR code does not read back as its content")
+
+// Verify renders the QR code as a PNG and checks that it decodes to the content it encodes.
+// SVG output has the same geometry, so a verified PNG vouches for it too.
+func (s *QRCodeService) Verify() (*DecodedQRCode, error) {
+	imageBytes, err := s.GenerateImage(ImageFormatPNG)
+	if err != nil {
+		return nil, err
 	}
-	return fmt.Sprintf("qrcode/%s", s.QRCode.ID)
+	return s.VerifyImage(imageBytes)
 }
 
-type Option interface{}
+// VerifyImage decodes a PNG rendering of the QR code and compares it with getDataToEncode.
+func (s *QRCodeService) VerifyImage(pngBytes []byte) (*DecodedQRCode, error) {
+	expected, err := s.getDataToEncode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build QR code content: %w", err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(pngBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rendered image: %w", err)
+	}
 
-// getQRCodeOptions initializes QR code options based on the style from the Template model.
-func (s *QRCodeService) getQRCodeOptions() ([]qrcode.EncodeOption, []qs.ImageOption, error) {
-	// Retrieve the style object from the metadata
-	style, err := s.getStyleMetadata()
+	decoded, err := DecodeQRImage(img)
 	if err != nil {
-		return nil, nil, err
+		return nil, fmt.Errorf("%w: %v", ErrVerificationFailed, err)
 	}
+	if decoded.Content != expected {
+		return decoded, fmt.Errorf("%w: read %q", ErrVerificationFailed, decoded.Content)
+	}
+	return decoded, nil
+}
 
-	imgOptions := []qs.ImageOption{
-		// qrcode.WithErrorCorrectionLevel(qrcode.ErrorCorrectionHighest),
-		qs.WithBorderWidth(8),
-		qs.WithFgColorRGBHex(style["foregroundColor"].(string)),
-		qs.WithBgColorRGBHex(style["backgroundColor"].(string)),
+// EncodedContentInfo reports the content, its length and the QR version and error correction
+// level it would be encoded with.
+func (s *QRCodeService) EncodedContentInfo() (*models.QRCodeContentPreview, error) {
+	dataToEncode, err := s.getDataToEncode()
+	if err != nil {
+		return nil, err
 	}
 
-	qrCodeOptions := []qrcode.EncodeOption{
-		qrcode.WithErrorCorrectionLevel(qrcode.ErrorCorrectionHighest),
+	logo, err := s.getLogoStyle()
+	if err != nil {
+		return nil, err
+	}
+	qrCode, level, err := s.encode(dataToEncode, logo)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.applyShapeOption(style, &imgOptions); err != nil {
-		return nil, nil, err
+	return &models.QRCodeContentPreview{
+		Content:         dataToEncode,
+		Length:          len(dataToEncode),
+		Version:         (qrCode.Dimension() - 17) / 4,
+		Dimension:       qrCode.Dimension(),
+		ErrorCorrection: level,
+	}, nil
+}
+
+// getDataToEncode returns the data to be encoded in the QR code.
+func (s *QRCodeService) getDataToEncode() (string, error) {
+	if s.QRCode.ContentType != "" && s.QRCode.ContentType != models.QRContentTypeURL {
+		return FormatPayload(s.QRCode.ContentType, s.QRCode.Content)
 	}
+	qr := s.publicQRCode()
+	content, err := s.getContent(qr)
+	if err != nil {
+		return "", err
+	}
+	if s.Template != nil && s.Template.SignedPayload != nil {
+		return SignPayload(config.SigningKeys(), s.Template.SignedPayload, qr, content)
+	}
+	return content, nil
+}
 
-	if err := s.applyLogoOption(style, &imgOptions); err != nil {
-		return nil, nil, err
+// getContent returns the content of URL QR codes, built by the template's content mapping.
+func (s *QRCodeService) getContent(qr *models.QRCode) (string, error) {
+	if s.Template != nil && s.Template.Content != nil {
+		return BuildContent(s.Template.Content, qr)
+	}
+	if qr.DeepLinkURL != "" {
+		return qr.DeepLinkURL, nil
 	}
+	return fmt.Sprintf("qrcode/%s", qr.ID), nil
+}
 
-	return qrCodeOptions, imgOptions, nil
+// publicQRCode returns the QR code without its sensitive data, for the content and caption the
+// template encodes and prints it in. Anyone holding the symbol can read those.
+func (s *QRCodeService) publicQRCode() *models.QRCode {
+	var definition models.Definition
+	if s.Template != nil {
+		definition = s.Template.Definition
+	}
+	qr := *s.QRCode
+	qr.Data = OmitSensitiveData(s.QRCode.Data, definition)
+	return &qr
 }
 
-// getStyleMetadata retrieves the metadata object of type "Style" from the Template.
-func (s *QRCodeService) getStyleMetadata() (map[string]interface{}, error) {
+// errorCorrection returns the template's error correction level, defaulting to H.
+// EPC069-12 mandates level M for SEPA payment codes.
+func (s *QRCodeService) errorCorrection() models.QRCodeErrorCorrection {
+	if s.QRCode.ContentType == models.QRContentTypeEPC {
+		return models.ErrorCorrectionM
+	}
+	if s.Template == nil || s.Template.ErrorCorrection == "" {
+		return models.ErrorCorrectionH
+	}
+	return s.Template.ErrorCorrection
+}
 
-	// Convert the fields into a map for easier access
-	style := make(map[string]interface{})
-	style["foregroundColor"] = s.Template.ForegroundColor
-	style["backgroundColor"] = s.Template.BackgroundColor
-	style["shape"] = s.Template.Shape                     // Default shape if not specified in the template
-	style["logoUrl"] = s.Template.LogoURL                 // Default logo URL if not specified in the template
-	style["size"] = s.Template.Size                       // Default size if not specified in the template
-	style["errorCorrection"] = s.Template.ErrorCorrection // Default error correction if not specified in the template
+// encode encodes content at the template's error correction level. With a logo the level is
+// raised, up to H, until the modules cleared for the logo are at most half of what it restores.
+// Payment codes keep their mandated level.
+func (s *QRCodeService) encode(content string, logo *logoStyle) (*qrcode.QRCode, models.QRCodeErrorCorrection, error) {
+	level := s.errorCorrection()
+	for {
+		qrCode, err := qrcode.NewWith(content, errorCorrectionOption(level))
+		if err != nil {
+			return nil, level, err
+		}
+		if logo == nil || level == models.ErrorCorrectionH || s.QRCode.ContentType == models.QRContentTypeEPC ||
+			logo.coverage(qrCode.Dimension()) <= errorCorrectionCapacity[level]/2 {
+			return qrCode, level, nil
+		}
+		level = nextErrorCorrection(level)
+	}
+}
 
-	return style, nil
+// nextErrorCorrection returns the level above level, or H for H.
+func nextErrorCorrection(level models.QRCodeErrorCorrection) models.QRCodeErrorCorrection {
+	for i, l := range eccLevels[:len(eccLevels)-1] {
+		if l == level {
+			return eccLevels[i+1]
+		}
+	}
+	return models.ErrorCorrectionH
+}
 
-	//return nil, fmt.Errorf("style metadata not found in template")
+// errorCorrectionOption maps an error correction level to an encode option.
+func errorCorrectionOption(level models.QRCodeErrorCorrection) qrcode.EncodeOption {
+	switch level {
+	case models.ErrorCorrectionL:
+		return qrcode.WithErrorCorrectionLevel(qrcode.ErrorCorrectionLow)
+	case models.ErrorCorrectionM:
+		return qrcode.WithErrorCorrectionLevel(qrcode.ErrorCorrectionMedium)
+	case models.ErrorCorrectionQ:
+		return qrcode.WithErrorCorrectionLevel(qrcode.ErrorCorrectionQuart)
+	default:
+		return qrcode.WithErrorCorrectionLevel(qrcode.ErrorCorrectionHighest)
+	}
 }
 
-// applyShapeOption applies the shape option if specified in the style.
-func (s *QRCodeService) applyShapeOption(style map[string]interface{}, options *[]qs.ImageOption) error {
-	if shape, ok := style["shape"].(string); ok {
-		switch shape {
-		case "circle":
-			*options = append(*options, qs.WithCircleShape())
-		case "square":
-			// *options = append(*options, qs.WithCustomShape())
-		default:
-			return fmt.Errorf("unsupported shape: %s", shape)
+// getRenderStyle resolves the Template's shapes, colors, size and logo into a render style.
+func (s *QRCodeService) getRenderStyle() (renderStyle, error) {
+	style := renderStyle{
+		ModuleShape:        s.Template.Shape,
+		EyeShape:           s.Template.EyeShape,
+		ForegroundGradient: s.Template.ForegroundGradient,
+		Size:               s.Template.Size,
+	}
+	if style.ModuleShape != "" && !style.ModuleShape.IsValid() {
+		return style, fmt.Errorf("unsupported shape: %s", style.ModuleShape)
+	}
+	if style.EyeShape != "" && !style.EyeShape.IsValid() {
+		return style, fmt.Errorf("unsupported eye shape: %s", style.EyeShape)
+	}
+
+	var err error
+	if style.Foreground, err = ParseHexColor(s.Template.ForegroundColor, color.Black); err != nil {
+		return style, err
+	}
+	if style.Background, err = ParseHexColor(s.Template.BackgroundColor, color.White); err != nil {
+		return style, err
+	}
+	// Eyes use the foreground paint unless styled separately
+	if style.EyeColor, err = ParseHexColor(s.Template.EyeColor, nil); err != nil {
+		return style, err
+	}
+
+	if style.Logo, err = s.getLogoStyle(); err != nil {
+		return style, err
+	}
+
+	if s.Template.Frame != nil {
+		if style.Frame, err = s.getFrameStyle(style); err != nil {
+			return style, err
 		}
 	}
-	return nil
+
+	return style, nil
 }
 
-// applyLogoOption applies the logo option if specified in the style.
-func (s *QRCodeService) applyLogoOption(style map[string]interface{}, options *[]qs.ImageOption) error {
-	// Check if the logo URL is provided in the style
-	logoURL, ok := style["logoUrl"].(string)
-	if !ok || logoURL == "" {
-		return nil // No logo specified, skip
+// getFrameStyle resolves the Template's frame. It defaults to the foreground color, or the
+// first gradient stop, and fills the caption's placeholders from the QR code.
+func (s *QRCodeService) getFrameStyle(style renderStyle) (*frameStyle, error) {
+	frame := s.Template.Frame
+	if frame.Style != "" && !frame.Style.IsValid() {
+		return nil, fmt.Errorf("unsupported frame style: %s", frame.Style)
 	}
 
-	// Verify the file exists
-	if _, err := os.Stat(logoURL); os.IsNotExist(err) {
-		return fmt.Errorf("logo file does not exist: %s", logoURL)
+	fallback := style.Foreground
+	if g := style.ForegroundGradient; g != nil && len(g.Stops) > 0 {
+		if first, err := ParseHexColor(g.Stops[0].Color, nil); err == nil {
+			fallback = first
+		}
+	}
+	frameColor, err := ParseHexColor(frame.Color, fallback)
+	if err != nil {
+		return nil, err
 	}
-	//logoURL = "./uploads/logos/Gmail_icon.png"
-	// Read the logo file
-	logoBytes, err := os.ReadFile(logoURL)
+	textColor, err := ParseHexColor(frame.TextColor, nil)
 	if err != nil {
-		return fmt.Errorf("failed to read logo file: %w", err)
+		return nil, err
 	}
 
-	// Decode the logo image
-	img, _, err := image.Decode(bytes.NewBuffer(logoBytes))
+	caption, err := expandOptionalPlaceholders(frame.Caption, s.publicQRCode())
 	if err != nil {
-		return fmt.Errorf("failed to decode logo image: %w", err)
+		return nil, fmt.Errorf("invalid frame caption: %w", err)
 	}
 
-	// Append the logo option
-	*options = append(*options, qs.WithLogoImage(img))
-	return nil
-}
+	f, err := loadFont(frame.Font, frame.FontURL)
+	if err != nil {
+		return nil, err
+	}
 
-type CustomWriteCloser interface {
-	Close() error
-	Write(p []byte) error
+	return &frameStyle{
+		Style:     frame.Style,
+		Color:     frameColor,
+		Caption:   caption,
+		Position:  frame.CaptionPosition,
+		Font:      f,
+		FontSize:  frame.FontSize,
+		TextColor: textColor,
+	}, nil
 }
 
-type customWriteCloser struct {
-	buffer *bytes.Buffer
-}
+// getLogoStyle loads the Template's logo and resolves its options, or returns nil without a logo.
+func (s *QRCodeService) getLogoStyle() (*logoStyle, error) {
+	img, err := loadLogo(s.Template.LogoURL)
+	if err != nil || img == nil {
+		return nil, err
+	}
 
-func (c *customWriteCloser) Close() error {
-	return nil
-}
-func (c *customWriteCloser) Write(p []byte) (int, error) {
-	return c.buffer.Write(p)
+	logo := &logoStyle{Image: img, Scale: defaultLogoScale, Padding: defaultLogoPadding, Plate: models.LogoPlateRounded}
+	opts := s.Template.LogoOptions
+	if opts == nil {
+		return logo, nil
+	}
+	if opts.Scale > 0 {
+		logo.Scale = opts.Scale
+	}
+	if opts.Padding != nil {
+		logo.Padding = *opts.Padding
+	}
+	if opts.Plate != "" {
+		if !opts.Plate.IsValid() {
+			return nil, fmt.Errorf("unsupported logo plate: %s", opts.Plate)
+		}
+		logo.Plate = opts.Plate
+	}
+	if logo.PlateColor, err = ParseHexColor(opts.PlateColor, nil); err != nil {
+		return nil, err
+	}
+	return logo, nil
 }
 
-// generateQRCodeImage generates the QR code image and returns the bytes.
-func (s *QRCodeService) generateQRCodeImage(qrCode *qrcode.QRCode, imageOptions []qs.ImageOption) ([]byte, error) {
+// generateQRCodeImage renders the QR code in the style's format and returns the bytes.
+func (s *QRCodeService) generateQRCodeImage(qrCode *qrcode.QRCode, style renderStyle) ([]byte, error) {
 	buf := new(bytes.Buffer)
-	customWriteCloser := &customWriteCloser{buffer: buf}
-	writer := qs.NewWithWriter(customWriteCloser, imageOptions...)
-
-	// Finally save with both configurations
-	if err := qrCode.Save(writer); err != nil {
+	if err := qrCode.Save(newQRRenderer(buf, style)); err != nil {
 		return nil, fmt.Errorf("failed to save QR code: %w", err)
 	}
 
@@ -0,0 +1,812 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Register JPEG photos with image.Decode
+	_ "image/png"
+	"math"
+	"net/http"
+	"sort"
+)
+
+var (
+	// ErrQRCodeNotFound is returned when an image contains no readable QR code.
+	ErrQRCodeNotFound = errors.New("no QR code found in the image")
+	// ErrUnsupportedImage is returned for uploads that are not PNG or JPEG images.
+	ErrUnsupportedImage = errors.New("unsupported image, must be PNG or JPEG")
+	// ErrImageTooLarge is returned for images with more pixels than are decoded.
+	ErrImageTooLarge = errors.New("image is too large to scan")
+)
+
+const (
+	binarizerBlockSize  = 8        // Pixels per side of the blocks local thresholds are computed for
+	minDynamicRange     = 24       // Blocks with less luminance spread are taken as uniform
+	maxFinderCandidates = 30       // Finder patterns considered when pairing them into symbols
+	maxTripleAttempts   = 100      // Finder triples sampled per binarized image
+	downsampleMinSize   = 400      // Images are halved for a second pass only down to this size
+	maxDecodePixels     = 40 << 20 // Largest uploaded image decoded, about 40 megapixels
+)
+
+// DecodeQRImage finds and decodes a QR code in img. Translucent pixels are read over white,
+// and light-on-dark codes are read as well.
+func DecodeQRImage(img image.Image) (*DecodedQRCode, error) {
+	codes, err := decodeImage(img, false)
+	if err != nil {
+		return nil, err
+	}
+	return codes[0], nil
+}
+
+// DecodeQRImageAll decodes every QR code in img, such as a photo of several labels. Codes
+// with the same content are reported once.
+func DecodeQRImageAll(img image.Image) ([]*DecodedQRCode, error) {
+	return decodeImage(img, true)
+}
+
+// DecodeQRImageData decodes every QR code in a PNG or JPEG image.
+func DecodeQRImageData(data []byte) ([]*DecodedQRCode, error) {
+	switch http.DetectContentType(data) {
+	case "image/png", "image/jpeg":
+	default:
+		return nil, ErrUnsupportedImage
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	if config.Width*config.Height > maxDecodePixels {
+		return nil, fmt.Errorf("%w: %dx%d pixels", ErrImageTooLarge, config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	return DecodeQRImageAll(img)
+}
+
+// decodeImage tries local then global thresholds, each also inverted, and finally a
+// half-size copy of large images, whose averaging smooths out photo noise. Unless all is
+// set it stops at the first code found.
+func decodeImage(img image.Image, all bool) ([]*DecodedQRCode, error) {
+	lum, w, h := luminance(img)
+	var codes []*DecodedQRCode
+	seen := make(map[string]bool)
+	var lastErr error = ErrQRCodeNotFound
+
+	for pass := 0; pass < 2; pass++ {
+		if pass == 1 {
+			if len(codes) > 0 || w < 2*downsampleMinSize || h < 2*downsampleMinSize {
+				break
+			}
+			lum, w, h = downsample(lum, w, h)
+		}
+		for _, binarize := range []func([]uint8, int, int) *bitMatrix{hybridBinarize, globalBinarize} {
+			m := binarize(lum, w, h)
+			for _, inverted := range []bool{false, true} {
+				if inverted {
+					m.invert()
+				}
+				found, err := decodeBitMatrix(m, all)
+				for _, code := range found {
+					if !seen[code.Content] {
+						seen[code.Content] = true
+						codes = append(codes, code)
+					}
+				}
+				if len(codes) > 0 && !all {
+					return codes, nil
+				}
+				if err != nil && !errors.Is(err, ErrQRCodeNotFound) {
+					lastErr = err
+				}
+			}
+		}
+	}
+
+	if len(codes) == 0 {
+		return nil, lastErr
+	}
+	return codes, nil
+}
+
+// downsample halves the image, averaging each 2x2 block of pixels.
+func downsample(lum []uint8, w, h int) ([]uint8, int, int) {
+	hw, hh := w/2, h/2
+	out := make([]uint8, hw*hh)
+	for y := 0; y < hh; y++ {
+		for x := 0; x < hw; x++ {
+			i := 2*y*w + 2*x
+			out[y*hw+x] = uint8((int(lum[i]) + int(lum[i+1]) + int(lum[i+w]) + int(lum[i+w+1])) / 4)
+		}
+	}
+	return out, hw, hh
+}
+
+// bitMatrix is a binarized image; true is dark.
+type bitMatrix struct {
+	width, height int
+	bits          []bool
+}
+
+func newBitMatrix(w, h int) *bitMatrix {
+	return &bitMatrix{width: w, height: h, bits: make([]bool, w*h)}
+}
+
+func (m *bitMatrix) get(x, y int) bool {
+	return m.bits[y*m.width+x]
+}
+
+func (m *bitMatrix) inside(x, y int) bool {
+	return x >= 0 && y >= 0 && x < m.width && y < m.height
+}
+
+func (m *bitMatrix) invert() {
+	for i := range m.bits {
+		m.bits[i] = !m.bits[i]
+	}
+}
+
+// luminance converts img to 8-bit luminance, compositing translucent pixels over white.
+func luminance(img image.Image) ([]uint8, int, int) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	lum := make([]uint8, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			// Premultiplied components: adding the uncovered share of white composites over it
+			white := 0xFFFF - a
+			r, g, b = r+white, g+white, b+white
+			lum[y*w+x] = uint8((299*r + 587*g + 114*b) / 1000 >> 8)
+		}
+	}
+	return lum, w, h
+}
+
+// hybridBinarize thresholds each block of pixels against the average of its 5x5 neighbouring
+// blocks, which copes with uneven lighting. Small images use the global threshold.
+func hybridBinarize(lum []uint8, w, h int) *bitMatrix {
+	if w < 5*binarizerBlockSize || h < 5*binarizerBlockSize {
+		return globalBinarize(lum, w, h)
+	}
+
+	subW := (w + binarizerBlockSize - 1) / binarizerBlockSize
+	subH := (h + binarizerBlockSize - 1) / binarizerBlockSize
+	blockOffset := func(block, size int) int {
+		offset := block * binarizerBlockSize
+		if offset > size-binarizerBlockSize {
+			offset = size - binarizerBlockSize
+		}
+		return offset
+	}
+
+	blackPoints := make([][]int, subH)
+	for by := range blackPoints {
+		blackPoints[by] = make([]int, subW)
+		yOffset := blockOffset(by, h)
+		for bx := 0; bx < subW; bx++ {
+			xOffset := blockOffset(bx, w)
+			sum, lowest, highest := 0, 255, 0
+			for y := yOffset; y < yOffset+binarizerBlockSize; y++ {
+				for _, v := range lum[y*w+xOffset : y*w+xOffset+binarizerBlockSize] {
+					sum += int(v)
+					if int(v) < lowest {
+						lowest = int(v)
+					}
+					if int(v) > highest {
+						highest = int(v)
+					}
+				}
+			}
+
+			average := sum / (binarizerBlockSize * binarizerBlockSize)
+			if highest-lowest <= minDynamicRange {
+				// A uniform block is taken as light unless its neighbours say it is darker than them
+				average = lowest / 2
+				if by > 0 && bx > 0 {
+					neighbours := (blackPoints[by-1][bx] + 2*blackPoints[by][bx-1] + blackPoints[by-1][bx-1]) / 4
+					if lowest < neighbours {
+						average = neighbours
+					}
+				}
+			}
+			blackPoints[by][bx] = average
+		}
+	}
+
+	m := newBitMatrix(w, h)
+	clamp := func(v, hi int) int {
+		if v < 2 {
+			return 2
+		}
+		if v > hi-3 {
+			return hi - 3
+		}
+		return v
+	}
+	for by := 0; by < subH; by++ {
+		yOffset := blockOffset(by, h)
+		top := clamp(by, subH)
+		for bx := 0; bx < subW; bx++ {
+			xOffset := blockOffset(bx, w)
+			left := clamp(bx, subW)
+			sum := 0
+			for y := top - 2; y <= top+2; y++ {
+				for x := left - 2; x <= left+2; x++ {
+					sum += blackPoints[y][x]
+				}
+			}
+			threshold := sum / 25
+			for y := yOffset; y < yOffset+binarizerBlockSize; y++ {
+				for x := xOffset; x < xOffset+binarizerBlockSize; x++ {
+					m.bits[y*w+x] = int(lum[y*w+x]) <= threshold
+				}
+			}
+		}
+	}
+	return m
+}
+
+// globalBinarize thresholds the whole image at the level that best separates its histogram (Otsu's method).
+func globalBinarize(lum []uint8, w, h int) *bitMatrix {
+	var histogram [256]int
+	for _, v := range lum {
+		histogram[v]++
+	}
+
+	total := len(lum)
+	sumAll := 0
+	for v, n := range histogram {
+		sumAll += v * n
+	}
+	threshold, best := 0, -1.0
+	darkCount, darkSum := 0, 0
+	for t, n := range histogram {
+		darkCount += n
+		darkSum += t * n
+		lightCount := total - darkCount
+		if darkCount == 0 || lightCount == 0 {
+			continue
+		}
+		darkMean := float64(darkSum) / float64(darkCount)
+		lightMean := float64(sumAll-darkSum) / float64(lightCount)
+		between := float64(darkCount) * float64(lightCount) * (darkMean - lightMean) * (darkMean - lightMean)
+		if between > best {
+			threshold, best = t, between
+		}
+	}
+
+	m := newBitMatrix(w, h)
+	for i, v := range lum {
+		m.bits[i] = int(v) <= threshold
+	}
+	return m
+}
+
+// finderPattern is the centre of a candidate finder pattern in pixels.
+type finderPattern struct {
+	X, Y       float64
+	ModuleSize float64
+	Count      int // Number of scans that confirmed the pattern
+}
+
+func (p finderPattern) distance(q finderPattern) float64 {
+	return math.Hypot(p.X-q.X, p.Y-q.Y)
+}
+
+// finderRatio reports whether five run lengths match the 1:1:3:1:1 profile of a finder pattern.
+func finderRatio(counts [5]int) bool {
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	if total < 7 {
+		return false
+	}
+	ms := float64(total) / 7
+	variance := ms / 2
+	return math.Abs(ms-float64(counts[0])) < variance &&
+		math.Abs(ms-float64(counts[1])) < variance &&
+		math.Abs(3*ms-float64(counts[2])) < 3*variance &&
+		math.Abs(ms-float64(counts[3])) < variance &&
+		math.Abs(ms-float64(counts[4])) < variance
+}
+
+// findFinderPatterns scans rows for the 1:1:3:1:1 profile, confirms each hit across the
+// column and merges hits on the same pattern.
+func findFinderPatterns(m *bitMatrix) []finderPattern {
+	var patterns []finderPattern
+	skip := m.height / 300
+	if skip < 1 {
+		skip = 1
+	}
+
+	for y := skip - 1; y < m.height; y += skip {
+		var counts [5]int
+		state := 0
+		for x := 0; x < m.width; x++ {
+			if m.get(x, y) {
+				if state&1 == 1 {
+					state++
+				}
+				counts[state]++
+				continue
+			}
+			if state&1 == 1 {
+				counts[state]++
+				continue
+			}
+			if state < 4 {
+				state++
+				counts[state]++
+				continue
+			}
+			// A dark-light-dark-light-dark sequence just ended
+			if finderRatio(counts) && addFinderPattern(m, &patterns, counts, x, y) {
+				counts, state = [5]int{}, 0
+				continue
+			}
+			counts = [5]int{counts[2], counts[3], counts[4], 1, 0}
+			state = 3
+		}
+		if state == 4 && finderRatio(counts) {
+			addFinderPattern(m, &patterns, counts, m.width, y)
+		}
+	}
+	return patterns
+}
+
+// addFinderPattern cross-checks a row hit ending at x and records or merges the pattern.
+func addFinderPattern(m *bitMatrix, patterns *[]finderPattern, counts [5]int, x, y int) bool {
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	cx := float64(x-counts[4]-counts[3]) - float64(counts[2])/2
+
+	cy, verticalTotal, ok := crossCheckFinder(m, int(cx), y, 0, 1, counts[2], total)
+	if !ok {
+		return false
+	}
+	cx, horizontalTotal, ok := crossCheckFinder(m, int(cx), int(cy), 1, 0, counts[2], total)
+	if !ok {
+		return false
+	}
+	ms := float64(verticalTotal+horizontalTotal) / 14
+
+	for i := range *patterns {
+		p := &(*patterns)[i]
+		if math.Abs(p.X-cx) <= ms && math.Abs(p.Y-cy) <= ms {
+			if diff := math.Abs(p.ModuleSize - ms); diff <= 1 || diff <= ms {
+				n := float64(p.Count)
+				p.X = (p.X*n + cx) / (n + 1)
+				p.Y = (p.Y*n + cy) / (n + 1)
+				p.ModuleSize = (p.ModuleSize*n + ms) / (n + 1)
+				p.Count++
+				return true
+			}
+		}
+	}
+	*patterns = append(*patterns, finderPattern{X: cx, Y: cy, ModuleSize: ms, Count: 1})
+	return true
+}
+
+// crossCheckFinder measures the finder profile through (x, y) along (dx, dy) and returns the
+// centre's coordinate on that axis and the profile's length.
+func crossCheckFinder(m *bitMatrix, x, y, dx, dy, maxCount, originalTotal int) (float64, int, bool) {
+	if !m.inside(x, y) {
+		return 0, 0, false
+	}
+	var counts [5]int
+
+	// Backwards from the centre: the ball, the light ring, the dark frame
+	px, py := x, y
+	for m.inside(px, py) && m.get(px, py) {
+		counts[2]++
+		px, py = px-dx, py-dy
+	}
+	for m.inside(px, py) && !m.get(px, py) && counts[1] <= maxCount {
+		counts[1]++
+		px, py = px-dx, py-dy
+	}
+	if !m.inside(px, py) || counts[1] > maxCount {
+		return 0, 0, false
+	}
+	for m.inside(px, py) && m.get(px, py) && counts[0] <= maxCount {
+		counts[0]++
+		px, py = px-dx, py-dy
+	}
+	if counts[0] > maxCount {
+		return 0, 0, false
+	}
+
+	// Forwards
+	px, py = x+dx, y+dy
+	for m.inside(px, py) && m.get(px, py) {
+		counts[2]++
+		px, py = px+dx, py+dy
+	}
+	for m.inside(px, py) && !m.get(px, py) && counts[3] <= maxCount {
+		counts[3]++
+		px, py = px+dx, py+dy
+	}
+	if !m.inside(px, py) || counts[3] > maxCount {
+		return 0, 0, false
+	}
+	for m.inside(px, py) && m.get(px, py) && counts[4] <= maxCount {
+		counts[4]++
+		px, py = px+dx, py+dy
+	}
+	if counts[4] > maxCount {
+		return 0, 0, false
+	}
+
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	if 5*absInt(total-originalTotal) >= 2*originalTotal || !finderRatio(counts) {
+		return 0, 0, false
+	}
+	end := px*dx + py*dy
+	return float64(end-counts[4]-counts[3]) - float64(counts[2])/2, total, true
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// finderTriples returns the (top-left, top-right, bottom-left) triples of patterns that could
+// be the finders of one symbol, best fitting first.
+func finderTriples(patterns []finderPattern) [][3]finderPattern {
+	sort.SliceStable(patterns, func(i, j int) bool { return patterns[i].Count > patterns[j].Count })
+	if len(patterns) > maxFinderCandidates {
+		patterns = patterns[:maxFinderCandidates]
+	}
+
+	type scored struct {
+		finders [3]finderPattern
+		score   float64
+	}
+	var triples []scored
+	for i := 0; i < len(patterns); i++ {
+		for j := i + 1; j < len(patterns); j++ {
+			for k := j + 1; k < len(patterns); k++ {
+				if score, ok := tripleScore(patterns[i], patterns[j], patterns[k]); ok {
+					triples = append(triples, scored{orderFinderPatterns(patterns[i], patterns[j], patterns[k]), score})
+				}
+			}
+		}
+	}
+	sort.SliceStable(triples, func(a, b int) bool { return triples[a].score < triples[b].score })
+
+	result := make([][3]finderPattern, len(triples))
+	for i, t := range triples {
+		result[i] = t.finders
+	}
+	return result
+}
+
+// tripleScore rates how closely three patterns form the right isosceles triangle of a symbol's
+// finders with similar module sizes; lower is better.
+func tripleScore(a, b, c finderPattern) (float64, bool) {
+	smallest := math.Min(a.ModuleSize, math.Min(b.ModuleSize, c.ModuleSize))
+	largest := math.Max(a.ModuleSize, math.Max(b.ModuleSize, c.ModuleSize))
+	if largest > 1.5*smallest {
+		return 0, false
+	}
+
+	sides := []float64{a.distance(b), b.distance(c), a.distance(c)}
+	sort.Float64s(sides)
+	short, long, hypotenuse := sides[0], sides[1], sides[2]
+	ms := (a.ModuleSize + b.ModuleSize + c.ModuleSize) / 3
+	if short/ms < 10 || long/ms > 180 {
+		return 0, false
+	}
+
+	legs := (long - short) / long
+	expected := math.Hypot(short, long)
+	angle := math.Abs(hypotenuse-expected) / expected
+	if legs > 0.3 || angle > 0.15 {
+		return 0, false
+	}
+	return legs + angle + (largest-smallest)/smallest, true
+}
+
+// orderFinderPatterns returns the patterns as top-left (at the right angle), top-right and bottom-left.
+func orderFinderPatterns(a, b, c finderPattern) [3]finderPattern {
+	ab, bc, ac := a.distance(b), b.distance(c), a.distance(c)
+	var corner, p, q finderPattern
+	switch {
+	case bc >= ab && bc >= ac:
+		corner, p, q = a, b, c
+	case ac >= ab && ac >= bc:
+		corner, p, q = b, a, c
+	default:
+		corner, p, q = c, a, b
+	}
+	// With y pointing down, the top-right pattern is clockwise from the bottom-left one
+	if (q.X-corner.X)*(p.Y-corner.Y)-(q.Y-corner.Y)*(p.X-corner.X) < 0 {
+		p, q = q, p
+	}
+	return [3]finderPattern{corner, q, p}
+}
+
+// decodeBitMatrix decodes the symbols found in m, or only the first unless all is set. A
+// finder pattern belongs to at most one decoded symbol.
+func decodeBitMatrix(m *bitMatrix, all bool) ([]*DecodedQRCode, error) {
+	var codes []*DecodedQRCode
+	var used []finderPattern
+	isUsed := func(p finderPattern) bool {
+		for _, u := range used {
+			if u == p {
+				return true
+			}
+		}
+		return false
+	}
+
+	var lastErr error = ErrQRCodeNotFound
+	for i, finders := range finderTriples(findFinderPatterns(m)) {
+		if i >= maxTripleAttempts {
+			break
+		}
+		if isUsed(finders[0]) || isUsed(finders[1]) || isUsed(finders[2]) {
+			continue
+		}
+		code, err := decodeAtFinders(m, finders)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		codes = append(codes, code)
+		if !all {
+			break
+		}
+		used = append(used, finders[:]...)
+	}
+
+	if len(codes) == 0 {
+		return nil, lastErr
+	}
+	return codes, nil
+}
+
+// decodeAtFinders samples and decodes the symbol with the given finder patterns, trying the
+// dimensions closest to the one estimated from their spacing.
+func decodeAtFinders(m *bitMatrix, finders [3]finderPattern) (*DecodedQRCode, error) {
+	tl, tr, bl := finders[0], finders[1], finders[2]
+	ms := (moduleSizeAlong(m, tl, tr) + moduleSizeAlong(m, tr, tl) + moduleSizeAlong(m, tl, bl) + moduleSizeAlong(m, bl, tl)) / 4
+	estimate := (tl.distance(tr)+tl.distance(bl))/(2*ms) + finderModules
+
+	base := 17 + 4*int(math.Round((estimate-17)/4))
+	var lastErr error = ErrQRCodeNotFound
+	for _, dim := range []int{base, base + 4, base - 4} {
+		if dim < 21 || dim > 177 {
+			continue
+		}
+		grid, err := sampleGrid(m, finders, dim, ms)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		code, err := decodeGrid(grid)
+		if err == nil {
+			return code, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// moduleSizeAlong measures finder p along the line towards q, across which it is seven
+// modules wide. Row scans overestimate the module size of rotated symbols, by up to √2 at 45°.
+func moduleSizeAlong(m *bitMatrix, p, q finderPattern) float64 {
+	d := p.distance(q)
+	dx, dy := (q.X-p.X)/d, (q.Y-p.Y)/d
+	forward, ok := darkLightDarkExtent(m, p, dx, dy)
+	if !ok {
+		return p.ModuleSize
+	}
+	backward, ok := darkLightDarkExtent(m, p, -dx, -dy)
+	if !ok {
+		return p.ModuleSize
+	}
+	return (forward + backward) / finderModules
+}
+
+// darkLightDarkExtent walks from the centre of finder p along (dx, dy) across the ball, the
+// light ring and the dark frame, and returns the distance to the frame's outer edge.
+func darkLightDarkExtent(m *bitMatrix, p finderPattern, dx, dy float64) (float64, bool) {
+	limit := 10 * p.ModuleSize
+	state := 0
+	for t := 0.0; t < limit; t++ {
+		x, y := int(math.Floor(p.X+t*dx)), int(math.Floor(p.Y+t*dy))
+		if !m.inside(x, y) {
+			return t, state == 2
+		}
+		dark := m.get(x, y)
+		switch {
+		case state == 0 && !dark, state == 1 && dark:
+			state++
+		case state == 2 && !dark:
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// sampleGrid reads the module centres of a symbol of dimension dim, mapping module
+// coordinates to pixels with the homography fixed by the finder centres and the bottom-right
+// alignment pattern (or the parallelogram's fourth corner when there is none).
+func sampleGrid(m *bitMatrix, finders [3]finderPattern, dim int, ms float64) (moduleGrid, error) {
+	tl, tr, bl := finders[0], finders[1], finders[2]
+	near, far := 3.5, float64(dim)-3.5
+	src := [][2]float64{{near, near}, {far, near}, {near, far}}
+	dst := [][2]float64{{tl.X, tl.Y}, {tr.X, tr.Y}, {bl.X, bl.Y}}
+
+	// Affine estimate from the finders alone
+	span := float64(dim - finderModules)
+	affine := func(u, v float64) (float64, float64) {
+		u, v = (u-near)/span, (v-near)/span
+		return tl.X + u*(tr.X-tl.X) + v*(bl.X-tl.X), tl.Y + u*(tr.Y-tl.Y) + v*(bl.Y-tl.Y)
+	}
+
+	corner := float64(dim) - 6.5
+	ax, ay := affine(corner, corner)
+	found := false
+	if dim > 21 {
+		ax, ay, found = findAlignmentPattern(m, ax, ay, affine, ms)
+	}
+	if found {
+		src = append(src, [2]float64{corner, corner})
+	} else {
+		ax, ay = affine(far, far)
+		src = append(src, [2]float64{far, far})
+	}
+	dst = append(dst, [2]float64{ax, ay})
+
+	h, err := solveHomography(src, dst)
+	if err != nil {
+		return nil, err
+	}
+
+	grid := make(moduleGrid, dim)
+	for y := range grid {
+		grid[y] = make([]bool, dim)
+		for x := range grid[y] {
+			px, py := h.apply(float64(x)+0.5, float64(y)+0.5)
+			ix, iy := int(math.Floor(px)), int(math.Floor(py))
+			if !m.inside(ix, iy) {
+				return nil, ErrQRCodeNotFound
+			}
+			grid[y][x] = m.get(ix, iy)
+		}
+	}
+	return grid, nil
+}
+
+// findAlignmentPattern searches around (ex, ey) for a dark module ringed by light and then
+// dark modules, widening the search until found. affine maps module to pixel coordinates.
+func findAlignmentPattern(m *bitMatrix, ex, ey float64, affine func(u, v float64) (float64, float64), ms float64) (float64, float64, bool) {
+	// Pixel offsets of one module along the symbol's axes
+	ox, oy := affine(0, 0)
+	ux, uy := affine(1, 0)
+	vx, vy := affine(0, 1)
+	ux, uy, vx, vy = ux-ox, uy-oy, vx-ox, vy-oy
+
+	matches := func(cx, cy float64) int {
+		n := 0
+		for j := -2; j <= 2; j++ {
+			for i := -2; i <= 2; i++ {
+				ring := absInt(i)
+				if absInt(j) > ring {
+					ring = absInt(j)
+				}
+				x := int(math.Floor(cx + float64(i)*ux + float64(j)*vx))
+				y := int(math.Floor(cy + float64(i)*uy + float64(j)*vy))
+				// The centre and the outer ring are dark, the ring between them light
+				if m.inside(x, y) && m.get(x, y) == (ring != 1) {
+					n++
+				}
+			}
+		}
+		return n
+	}
+
+	step := math.Max(1, ms/4)
+	for _, allowance := range []float64{4, 8, 16} {
+		radius := allowance * ms
+		bestX, bestY, best, bestDistance := 0.0, 0.0, 0, math.Inf(1)
+		for dy := -radius; dy <= radius; dy += step {
+			for dx := -radius; dx <= radius; dx += step {
+				n := matches(ex+dx, ey+dy)
+				d := math.Hypot(dx, dy)
+				if n > best || (n == best && d < bestDistance) {
+					bestX, bestY, best, bestDistance = ex+dx, ey+dy, n, d
+				}
+			}
+		}
+		// Allow a couple of mismatches on blurred or skewed edges
+		if best >= 23 {
+			return refineDarkCentre(m, bestX, bestY, ms)
+		}
+	}
+	return 0, 0, false
+}
+
+// refineDarkCentre returns the centre of the dark run through (x, y) on both axes.
+func refineDarkCentre(m *bitMatrix, x, y, ms float64) (float64, float64, bool) {
+	ix, iy := int(x), int(y)
+	if !m.inside(ix, iy) || !m.get(ix, iy) {
+		return x, y, true
+	}
+	limit := int(2 * ms)
+	run := func(dx, dy int) float64 {
+		lo, hi := 0, 0
+		for lo < limit && m.inside(ix-(lo+1)*dx, iy-(lo+1)*dy) && m.get(ix-(lo+1)*dx, iy-(lo+1)*dy) {
+			lo++
+		}
+		for hi < limit && m.inside(ix+(hi+1)*dx, iy+(hi+1)*dy) && m.get(ix+(hi+1)*dx, iy+(hi+1)*dy) {
+			hi++
+		}
+		return float64(hi-lo) / 2
+	}
+	return float64(ix) + 0.5 + run(1, 0), float64(iy) + 0.5 + run(0, 1), true
+}
+
+// homography maps (u, v) to ((a·u + b·v + c) / (g·u + h·v + 1), (d·u + e·v + f) / (g·u + h·v + 1)).
+type homography [8]float64
+
+func (h homography) apply(u, v float64) (float64, float64) {
+	w := h[6]*u + h[7]*v + 1
+	return (h[0]*u + h[1]*v + h[2]) / w, (h[3]*u + h[4]*v + h[5]) / w
+}
+
+// solveHomography finds the homography taking the four src points to the dst points.
+func solveHomography(src, dst [][2]float64) (homography, error) {
+	var a [8][9]float64
+	for i := 0; i < 4; i++ {
+		u, v := src[i][0], src[i][1]
+		x, y := dst[i][0], dst[i][1]
+		a[2*i] = [9]float64{u, v, 1, 0, 0, 0, -u * x, -v * x, x}
+		a[2*i+1] = [9]float64{0, 0, 0, u, v, 1, -u * y, -v * y, y}
+	}
+
+	// Gaussian elimination with partial pivoting
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return homography{}, ErrQRCodeNotFound
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for row := 0; row < 8; row++ {
+			if row == col {
+				continue
+			}
+			factor := a[row][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[row][k] -= factor * a[col][k]
+			}
+		}
+	}
+
+	var h homography
+	for i := range h {
+		h[i] = a[i][8] / a[i][i]
+	}
+	return h, nil
+}
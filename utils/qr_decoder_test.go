@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/fogleman/gg"
+	"github.com/mca93/qrcode_service/models"
+	"github.com/stretchr/testify/assert"
+	qrcode "github.com/yeqown/go-qrcode/v2"
+)
+
+// matrixWriter captures the encoded matrix of a QR code.
+type matrixWriter struct {
+	grid moduleGrid
+}
+
+func (w *matrixWriter) Write(mat qrcode.Matrix) error {
+	w.grid = moduleGrid(mat.Bitmap())
+	return nil
+}
+
+func (w *matrixWriter) Close() error {
+	return nil
+}
+
+func encodeGrid(t *testing.T, content string, level qrcode.EncodeOption) moduleGrid {
+	qr, err := qrcode.NewWith(content, level)
+	assert.NoError(t, err)
+	w := &matrixWriter{}
+	assert.NoError(t, qr.Save(w))
+	return w.grid
+}
+
+func TestDecodeGrid(t *testing.T) {
+	levels := map[models.QRCodeErrorCorrection]qrcode.EncodeOption{
+		models.ErrorCorrectionL: qrcode.WithErrorCorrectionLevel(qrcode.ErrorCorrectionLow),
+		models.ErrorCorrectionM: qrcode.WithErrorCorrectionLevel(qrcode.ErrorCorrectionMedium),
+		models.ErrorCorrectionQ: qrcode.WithErrorCorrectionLevel(qrcode.ErrorCorrectionQuart),
+		models.ErrorCorrectionH: qrcode.WithErrorCorrectionLevel(qrcode.ErrorCorrectionHighest),
+	}
+	contents := []string{
+		"0123456789012345",                    // numeric
+		"HTTPS://EXAMPLE.COM/A-B",             // alphanumeric
+		"https://example.com/olá?x=1",         // byte, UTF-8
+		strings.Repeat("https://ex.com/", 40), // version 13-19
+		strings.Repeat("0123456789abcdef", 60),
+	}
+
+	for level, option := range levels {
+		for _, content := range contents {
+			grid := encodeGrid(t, content, option)
+			decoded, err := decodeGrid(grid)
+			if assert.NoError(t, err, "level %s, dimension %d", level, grid.dimension()) {
+				assert.Equal(t, content, decoded.Content)
+				assert.Equal(t, level, decoded.ErrorCorrection)
+				assert.Equal(t, (grid.dimension()-17)/4, decoded.Version)
+			}
+		}
+	}
+}
+
+func TestDecodeGridCorrectsErrors(t *testing.T) {
+	content := "https://example.com/qrcodes/00000000-0000-0000-0000-000000000000"
+	grid := encodeGrid(t, content, qrcode.WithErrorCorrectionLevel(qrcode.ErrorCorrectionHighest))
+
+	// Flip a block of data modules near the bottom-right corner
+	dim := grid.dimension()
+	for y := dim - 4; y < dim; y++ {
+		for x := dim - 4; x < dim; x++ {
+			grid[y][x] = !grid[y][x]
+		}
+	}
+	decoded, err := decodeGrid(grid)
+	assert.NoError(t, err)
+	assert.Equal(t, content, decoded.Content)
+	assert.Greater(t, decoded.ErrorsCorrected, 0)
+}
+
+func TestDecodeQRImage(t *testing.T) {
+	content := "https://example.com/qrcodes/00000000-0000-0000-0000-000000000000"
+	qr, err := qrcode.NewWith(content, qrcode.WithErrorCorrectionLevel(qrcode.ErrorCorrectionQuart))
+	assert.NoError(t, err)
+
+	styles := []renderStyle{
+		{Foreground: color.White, Background: color.Black},       // inverted
+		{Foreground: color.Black, Background: color.Transparent}, // read over white
+		{Foreground: color.Black, Background: color.White, Size: 150},
+	}
+	for _, shape := range []models.ModuleShape{models.ModuleShapeSquare, models.ModuleShapeCircle, models.ModuleShapeDots,
+		models.ModuleShapeDiamond, models.ModuleShapeRounded, models.ModuleShapeVerticalBars, models.ModuleShapeHorizontalBars} {
+		for _, eye := range []models.EyeShape{models.EyeShapeSquare, models.EyeShapeRounded, models.EyeShapeCircle} {
+			styles = append(styles, renderStyle{ModuleShape: shape, EyeShape: eye, Foreground: color.Black, Background: color.White})
+		}
+	}
+
+	for _, style := range styles {
+		var buf bytes.Buffer
+		assert.NoError(t, qr.Save(newQRRenderer(&buf, style)))
+		img, err := png.Decode(&buf)
+		assert.NoError(t, err)
+
+		decoded, err := DecodeQRImage(img)
+		if assert.NoError(t, err, "shape %q, eye %q", style.ModuleShape, style.EyeShape) {
+			assert.Equal(t, content, decoded.Content)
+		}
+	}
+
+	_, err = DecodeQRImage(image.NewGray(image.Rect(0, 0, 100, 100)))
+	assert.ErrorIs(t, err, ErrQRCodeNotFound)
+}
+
+func TestDecodeQRImageData(t *testing.T) {
+	contents := []string{"https://example.com/qrcodes/first", "https://example.com/qrcodes/second-with-longer-content"}
+
+	// A photo of two rotated labels on a grey surface, saved as a JPEG
+	dc := gg.NewContext(1200, 700)
+	dc.SetRGB(0.8, 0.8, 0.78)
+	dc.Clear()
+	for i, content := range contents {
+		qr, err := qrcode.NewWith(content, qrcode.WithErrorCorrectionLevel(qrcode.ErrorCorrectionMedium))
+		assert.NoError(t, err)
+		var buf bytes.Buffer
+		assert.NoError(t, qr.Save(newQRRenderer(&buf, renderStyle{Foreground: color.Black, Background: color.White, Size: 300})))
+		label, err := png.Decode(&buf)
+		assert.NoError(t, err)
+
+		x := 300 + 600*float64(i)
+		dc.Push()
+		dc.RotateAbout(gg.Radians(45-float64(i)*70), x, 350)
+		dc.DrawImageAnchored(label, int(x), 350, 0.5, 0.5)
+		dc.Pop()
+	}
+	var photo bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&photo, dc.Image(), &jpeg.Options{Quality: 60}))
+
+	codes, err := DecodeQRImageData(photo.Bytes())
+	assert.NoError(t, err)
+	var decoded []string
+	for _, code := range codes {
+		decoded = append(decoded, code.Content)
+	}
+	assert.ElementsMatch(t, contents, decoded)
+
+	_, err = DecodeQRImageData([]byte("GIF89a"))
+	assert.ErrorIs(t, err, ErrUnsupportedImage)
+}
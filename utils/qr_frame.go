@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/mca93/qrcode_service/models"
+	"golang.org/x/image/font/sfnt"
+)
+
+const (
+	frameThicknessRatio  = 0.03 // Outline thickness of borders and banners, relative to the symbol size
+	cardMarginRatio      = 0.06 // Card margin around the symbol, relative to the symbol size
+	cardRadiusRatio      = 0.08 // Card corner radius, relative to the symbol size
+	minBannerBandRatio   = 0.12 // Smallest banner band height, relative to the symbol size
+	defaultFontSizeRatio = 0.1  // Caption font size when the frame sets none, relative to the symbol size
+	captionLineHeight    = 1.6  // Caption band height relative to the font size
+	captionWidthRatio    = 0.9  // Widest caption, relative to the symbol size; longer ones are shrunk
+)
+
+// frameStyle is a template frame resolved for drawing.
+type frameStyle struct {
+	Style     models.FrameStyle
+	Color     color.Color
+	Caption   string // Placeholders already filled in
+	Position  models.CaptionPosition
+	Font      *sfnt.Font
+	FontSize  float64     // Pixels; 0 for the default
+	TextColor color.Color // nil to pick black or white against the surface
+}
+
+// rect is an axis-aligned rectangle in pixels.
+type rect struct {
+	X, Y, W, H float64
+}
+
+// frameLayout places the symbol and the caption band in a framed image.
+type frameLayout struct {
+	Width, Height    float64
+	SymbolX, SymbolY float64
+	SymbolSize       float64
+	Inset            float64 // Outline thickness or card margin
+	Band             rect    // Caption area, as wide as the symbol
+	FontSize         float64 // Shrunk so the caption fits the band
+}
+
+// layoutFrame lays out a frame around a symbol of size pixels. Without a frame the image is
+// the symbol alone.
+func layoutFrame(f *frameStyle, size float64) (frameLayout, error) {
+	if f == nil {
+		return frameLayout{Width: size, Height: size}, nil
+	}
+
+	fontSize := f.FontSize
+	if fontSize <= 0 {
+		fontSize = math.Round(size * defaultFontSizeRatio)
+	}
+	bandHeight := 0.0
+	if f.Caption != "" {
+		bandHeight = math.Round(fontSize * captionLineHeight)
+	}
+
+	inset := math.Max(2, math.Round(size*frameThicknessRatio))
+	switch f.Style {
+	case models.FrameStyleCard:
+		inset = math.Round(size * cardMarginRatio)
+	case models.FrameStyleBanner:
+		bandHeight = math.Max(bandHeight, math.Round(size*minBannerBandRatio))
+	}
+
+	l := frameLayout{
+		Width:      size + 2*inset,
+		Height:     size + 2*inset + bandHeight,
+		SymbolX:    inset,
+		SymbolY:    inset,
+		SymbolSize: size,
+		Inset:      inset,
+		Band:       rect{X: inset, Y: inset + size, W: size, H: bandHeight},
+		FontSize:   fontSize,
+	}
+	if f.Position == models.CaptionPositionTop {
+		l.Band.Y = inset
+		l.SymbolY = inset + bandHeight
+	}
+
+	if f.Caption != "" {
+		width, err := measureText(f.Font, f.Caption, fontSize)
+		if err != nil {
+			return l, err
+		}
+		if limit := size * captionWidthRatio; width > limit {
+			l.FontSize = fontSize * limit / width
+		}
+	}
+	return l, nil
+}
+
+// drawFrame draws the frame behind the symbol and caption.
+func drawFrame(c canvas, l frameLayout, f *frameStyle, background color.Color) {
+	frame := paint{color: f.Color}
+	switch f.Style {
+	case models.FrameStyleCard:
+		c.roundedRectangle(0, 0, l.Width, l.Height, l.SymbolSize*cardRadiusRatio)
+		c.fill(frame, false)
+	case models.FrameStyleBanner:
+		c.rectangle(0, 0, l.Width, l.Height)
+		c.fill(frame, false)
+	default:
+		c.rectangle(0, 0, l.Width, l.Height)
+		c.rectangle(l.Inset, l.Inset, l.Width-2*l.Inset, l.Height-2*l.Inset)
+		c.fill(frame, true)
+		// The caption sits on the symbol's background
+		if background != nil && l.Band.H > 0 {
+			c.rectangle(l.Inset, l.Inset, l.Width-2*l.Inset, l.Height-2*l.Inset)
+			c.fill(paint{color: background}, false)
+		}
+	}
+}
+
+// drawCaption centres the caption in the band.
+func drawCaption(c canvas, l frameLayout, f *frameStyle, background color.Color) error {
+	if f.Caption == "" {
+		return nil
+	}
+
+	width, err := measureText(f.Font, f.Caption, l.FontSize)
+	if err != nil {
+		return err
+	}
+	ascent, descent, err := textMetrics(f.Font, l.FontSize)
+	if err != nil {
+		return err
+	}
+
+	textColor := f.TextColor
+	if textColor == nil {
+		surface := f.Color
+		if f.Style == models.FrameStyleBorder || f.Style == "" {
+			surface = background
+		}
+		textColor = contrastingTextColor(surface)
+	}
+
+	x := l.Band.X + (l.Band.W-width)/2
+	baseline := l.Band.Y + l.Band.H/2 + (ascent-descent)/2
+	return drawText(c, f.Font, f.Caption, x, baseline, l.FontSize, paint{color: textColor})
+}
+
+// contrastingTextColor returns black or white, whichever contrasts more with the surface,
+// which is seen over white when translucent.
+func contrastingTextColor(surface color.Color) color.Color {
+	if surface == nil {
+		return color.Black
+	}
+	backdrop := Composite(surface, color.White)
+	if ContrastRatio(color.White, backdrop) > ContrastRatio(color.Black, backdrop) {
+		return color.White
+	}
+	return color.Black
+}
@@ -0,0 +1,335 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"  // Register GIF logos with image.Decode
+	_ "image/jpeg" // Register JPEG logos with image.Decode; PNG is registered by the renderer
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mca93/qrcode_service/config"
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/storage"
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Register WebP logos with image.Decode
+)
+
+const (
+	MaxLogoBytes       = 5 << 20          // Largest logo file read, uploaded or fetched
+	maxLogoPixels      = 4096 * 4096      // Largest raster logo decoded
+	defaultLogoScale   = 0.2              // Logo width or height relative to the symbol
+	defaultLogoPadding = 1.0              // Modules cleared around the logo
+	logoSafeMargin     = 8                // Modules along each edge never cleared: finder, separator and timing patterns
+	logoFetchTimeout   = 10 * time.Second // Time allowed to fetch a logo from its URL
+	logoCacheTTL       = 5 * time.Minute  // How long a loaded logo is reused
+	maxCachedLogos     = 100
+)
+
+var (
+	// ErrUnsupportedLogo is returned for logos that are not PNG, JPEG, GIF, WebP or SVG images.
+	ErrUnsupportedLogo = errors.New("logo must be a PNG, JPEG, GIF, WebP or SVG image")
+	// ErrLogoTooLarge is returned for logo files over MaxLogoBytes.
+	ErrLogoTooLarge = fmt.Errorf("logo must be at most %d MB", MaxLogoBytes>>20)
+)
+
+// logoHTTPClient fetches logos from their URLs. It only connects to public addresses, so
+// template logos cannot be used to probe the service's own network.
+var logoHTTPClient = &http.Client{
+	Timeout: logoFetchTimeout,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: logoFetchTimeout, Control: dialPublicOnly}).DialContext,
+		TLSHandshakeTimeout: logoFetchTimeout,
+	},
+}
+
+// logoCache keeps recently loaded logos, by template logo URL.
+var logoCache = struct {
+	sync.Mutex
+	entries map[string]cachedLogo
+}{entries: make(map[string]cachedLogo)}
+
+type cachedLogo struct {
+	logo    *logoImage
+	expires time.Time
+}
+
+// logoImage is a decoded logo: a raster image, or an SVG document drawn at the size needed.
+type logoImage struct {
+	raster        image.Image
+	svg           []byte  // Source of SVG logos, embedded as is in SVG output
+	width, height float64 // Intrinsic size, which gives the aspect ratio
+}
+
+// render draws the logo into a new w x h image.
+func (l *logoImage) render(w, h int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if l.svg != nil {
+		// Icons keep their target transform, so each render parses its own
+		icon, err := oksvg.ReadIconStream(bytes.NewReader(l.svg))
+		if err != nil {
+			return dst // Parsed when the logo was loaded, so this does not happen
+		}
+		icon.SetTarget(0, 0, float64(w), float64(h))
+		scanner := rasterx.NewScannerGV(w, h, dst, dst.Bounds())
+		icon.Draw(rasterx.NewDasher(w, h, scanner), 1)
+		return dst
+	}
+	draw.CatmullRom.Scale(dst, dst.Bounds(), l.raster, l.raster.Bounds(), draw.Over, nil)
+	return dst
+}
+
+// IsRemoteLogoURL reports whether logoURL is an http(s) URL the logo is fetched from, rather
+// than the path of an uploaded logo.
+func IsRemoteLogoURL(logoURL string) bool {
+	parsed, err := url.Parse(logoURL)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// loadLogo returns the logo at logoURL, fetched when it is an http(s) URL and read from asset
+// storage otherwise, or nil when the template has none. Logos are reused for a while, so every
+// render does not fetch them again.
+func loadLogo(logoURL string) (*logoImage, error) {
+	if logoURL == "" {
+		return nil, nil // No logo specified, skip
+	}
+
+	logoCache.Lock()
+	cached, ok := logoCache.entries[logoURL]
+	logoCache.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.logo, nil
+	}
+
+	var data []byte
+	var err error
+	if IsRemoteLogoURL(logoURL) {
+		data, err = fetchLogo(logoURL)
+	} else {
+		data, err = readStoredLogo(logoURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The content is sniffed; file names and Content-Type headers are not trusted
+	logo, err := parseLogo(data)
+	if err != nil {
+		return nil, err
+	}
+
+	logoCache.Lock()
+	defer logoCache.Unlock()
+	now := time.Now()
+	if len(logoCache.entries) >= maxCachedLogos {
+		for key, entry := range logoCache.entries {
+			if now.After(entry.expires) {
+				delete(logoCache.entries, key)
+			}
+		}
+		if len(logoCache.entries) >= maxCachedLogos {
+			logoCache.entries = make(map[string]cachedLogo)
+		}
+	}
+	logoCache.entries[logoURL] = cachedLogo{logo: logo, expires: now.Add(logoCacheTTL)}
+	return logo, nil
+}
+
+func readStoredLogo(ref string) ([]byte, error) {
+	data, err := config.Storage.Get(context.Background(), AssetKey(ref))
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		return nil, fmt.Errorf("logo file does not exist: %s", ref)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read logo file: %w", err)
+	}
+	if len(data) > MaxLogoBytes {
+		return nil, ErrLogoTooLarge
+	}
+	return data, nil
+}
+
+// fetchLogo downloads the logo at an http(s) URL.
+func fetchLogo(logoURL string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), logoFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, logoURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid logo URL: %w", err)
+	}
+	resp, err := logoHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch logo: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch logo: %s", resp.Status)
+	}
+	if resp.ContentLength > MaxLogoBytes {
+		return nil, ErrLogoTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxLogoBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch logo: %w", err)
+	}
+	if len(data) > MaxLogoBytes {
+		return nil, ErrLogoTooLarge
+	}
+	return data, nil
+}
+
+// dialPublicOnly refuses connections to loopback, private, link-local and other non-public addresses.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return fmt.Errorf("logo host %s is not a public address", host)
+	}
+	return nil
+}
+
+// parseLogo decodes a logo, telling its format from the content.
+func parseLogo(data []byte) (*logoImage, error) {
+	switch http.DetectContentType(data) {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode logo image: %w", err)
+		}
+		if config.Width*config.Height > maxLogoPixels {
+			return nil, fmt.Errorf("logo is %dx%d pixels, at most %d pixels are supported", config.Width, config.Height, maxLogoPixels)
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode logo image: %w", err)
+		}
+		bounds := img.Bounds()
+		if bounds.Empty() {
+			return nil, errors.New("logo image is empty")
+		}
+		return &logoImage{raster: img, width: float64(bounds.Dx()), height: float64(bounds.Dy())}, nil
+	}
+
+	if !isSVG(data) {
+		return nil, ErrUnsupportedLogo
+	}
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SVG logo: %w", err)
+	}
+	if icon.ViewBox.W <= 0 || icon.ViewBox.H <= 0 {
+		return nil, errors.New("SVG logo has no size; set its viewBox or width and height")
+	}
+	return &logoImage{svg: data, width: icon.ViewBox.W, height: icon.ViewBox.H}, nil
+}
+
+// isSVG reports whether data is a text document with an <svg> element near its start.
+func isSVG(data []byte) bool {
+	if !strings.HasPrefix(http.DetectContentType(data), "text/") {
+		return false
+	}
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	return bytes.Contains(bytes.ToLower(head), []byte("<svg"))
+}
+
+// logoStyle is a template logo resolved for drawing.
+type logoStyle struct {
+	Image      *logoImage
+	Scale      float64 // Logo width or height relative to the symbol
+	Padding    float64 // Modules cleared around the logo
+	Plate      models.LogoPlate
+	PlateColor color.Color // nil to use the background
+}
+
+// size returns the logo's width and height in modules for a symbol of the given dimension.
+func (l *logoStyle) size(dimension int) (float64, float64) {
+	side := l.Scale * float64(dimension)
+	aspect := l.Image.width / l.Image.height
+	if aspect >= 1 {
+		return side, side / aspect
+	}
+	return side * aspect, side
+}
+
+// footprint returns the modules cleared for the logo in a symbol of the given dimension: a
+// centred rectangle holding the logo and its padding, kept off the finder and timing patterns.
+func (l *logoStyle) footprint(dimension int) image.Rectangle {
+	limit := dimension - 2*logoSafeMargin
+	if limit <= 0 {
+		return image.Rectangle{}
+	}
+	modules := func(extent float64) int {
+		n := int(math.Ceil(extent + 2*l.Padding))
+		// Symbols have an odd dimension, so an odd count centres the area on the middle module
+		if n%2 == 0 {
+			n++
+		}
+		if n > limit {
+			n = limit
+		}
+		return n
+	}
+
+	w, h := l.size(dimension)
+	cols, rows := modules(w), modules(h)
+	x, y := (dimension-cols)/2, (dimension-rows)/2
+	return image.Rect(x, y, x+cols, y+rows)
+}
+
+// coverage returns the share of the modules of a symbol of the given dimension cleared for the logo.
+func (l *logoStyle) coverage(dimension int) float64 {
+	area := l.footprint(dimension)
+	return float64(area.Dx()*area.Dy()) / float64(dimension*dimension)
+}
+
+// drawLogo draws the plate over the modules cleared in area and the logo centred on it. The
+// symbol's modules start at (x, y) and are ms pixels wide.
+func drawLogo(c canvas, l *logoStyle, dimension int, area image.Rectangle, x, y, ms float64, background color.Color) {
+	px, py := x+float64(area.Min.X)*ms, y+float64(area.Min.Y)*ms
+	pw, ph := float64(area.Dx())*ms, float64(area.Dy())*ms
+
+	plate := l.PlateColor
+	if plate == nil {
+		plate = background
+	}
+	if plate != nil && l.Plate != models.LogoPlateNone {
+		if _, _, _, a := plate.RGBA(); a > 0 {
+			if l.Plate == models.LogoPlateSquare {
+				c.rectangle(px, py, pw, ph)
+			} else {
+				c.roundedRectangle(px, py, pw, ph, ms)
+			}
+			c.fill(paint{color: plate}, false)
+		}
+	}
+
+	// A footprint cut short by a small symbol shrinks the logo with it
+	w, h := l.size(dimension)
+	fit := math.Min(1, math.Min((float64(area.Dx())-2*l.Padding)/w, (float64(area.Dy())-2*l.Padding)/h))
+	if fit <= 0 {
+		return
+	}
+	w, h = w*fit*ms, h*fit*ms
+	c.drawImage(l.Image, px+(pw-w)/2, py+(ph-h)/2, w, h)
+}
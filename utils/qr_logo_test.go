@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mca93/qrcode_service/config"
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSVGLogo = `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 50"><rect width="100" height="50" fill="#1565C0"/></svg>`
+
+func testPNGLogo(t *testing.T, w, h int, c color.Color) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// storeTestAsset stores data in a local asset storage for the test and returns its key.
+func storeTestAsset(t *testing.T, prefix string, data []byte, ext string) string {
+	if _, ok := config.Storage.(*storage.Local); !ok {
+		defaultStorage := config.Storage
+		config.Storage = storage.NewLocal(t.TempDir(), "", nil)
+		t.Cleanup(func() { config.Storage = defaultStorage })
+	}
+	key, err := SaveUpload(context.Background(), config.Storage, prefix, data, ext)
+	assert.NoError(t, err)
+	return key
+}
+
+func TestLoadLogo(t *testing.T) {
+	logo := testPNGLogo(t, 40, 20, color.Black)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/logo.png":
+			// The content is sniffed, whatever the server calls it
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write(logo)
+		case "/logo.svg":
+			_, _ = w.Write([]byte(testSVGLogo))
+		case "/large.png":
+			_, _ = w.Write(make([]byte, MaxLogoBytes+1))
+		case "/page.html":
+			_, _ = w.Write([]byte("<html><body>not a logo</body></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// Test servers listen on loopback, which logos are not fetched from
+	_, err := loadLogo(server.URL + "/logo.png")
+	assert.ErrorContains(t, err, "not a public address")
+
+	defaultClient := logoHTTPClient
+	logoHTTPClient = server.Client()
+	defer func() { logoHTTPClient = defaultClient }()
+
+	img, err := loadLogo(server.URL + "/logo.png")
+	assert.NoError(t, err)
+	assert.Equal(t, 40.0, img.width)
+	assert.NotNil(t, img.raster)
+
+	img, err = loadLogo(server.URL + "/logo.svg")
+	assert.NoError(t, err)
+	assert.Equal(t, 100.0, img.width)
+	assert.Equal(t, 50.0, img.height)
+	assert.Equal(t, color.RGBAModel.Convert(color.RGBA{R: 0x15, G: 0x65, B: 0xC0, A: 0xFF}), color.RGBAModel.Convert(img.render(20, 10).At(10, 5)))
+
+	_, err = loadLogo(server.URL + "/large.png")
+	assert.ErrorIs(t, err, ErrLogoTooLarge)
+	_, err = loadLogo(server.URL + "/page.html")
+	assert.ErrorIs(t, err, ErrUnsupportedLogo)
+	_, err = loadLogo(server.URL + "/missing.png")
+	assert.ErrorContains(t, err, "404")
+}
+
+func TestRendererLogo(t *testing.T) {
+	path := storeTestAsset(t, "logos", testPNGLogo(t, 60, 60, color.RGBA{R: 0xCC, A: 0xFF}), ".png")
+	plate := color.RGBA{R: 0xFF, G: 0xF3, B: 0xE0, A: 0xFF}
+
+	qr := &models.QRCode{ID: "123", DeepLinkURL: "https://example.com/qrcodes/123"}
+	template := &models.Template{
+		LogoURL:         path,
+		LogoOptions:     &models.LogoOptions{Scale: 0.25, Plate: models.LogoPlateSquare, PlateColor: "#FFF3E0"},
+		ErrorCorrection: models.ErrorCorrectionL,
+	}
+	service := NewQRCodeService(qr, template)
+
+	// Level L cannot make up for the modules the logo clears
+	info, err := service.EncodedContentInfo()
+	assert.NoError(t, err)
+	assert.NotEqual(t, models.ErrorCorrectionL, info.ErrorCorrection)
+
+	data, err := service.GenerateImage(ImageFormatPNG)
+	assert.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+
+	// The plate fills the cleared modules, the logo sits in the middle
+	logo, err := service.getLogoStyle()
+	assert.NoError(t, err)
+	area := logo.footprint(info.Dimension)
+	corner := (quietZoneModules+area.Min.X)*defaultModulePixels + 2
+	middle := (quietZoneModules*2 + info.Dimension) * defaultModulePixels / 2
+	assert.Equal(t, color.RGBAModel.Convert(plate), color.RGBAModel.Convert(img.At(corner, corner)))
+	assert.Equal(t, color.RGBAModel.Convert(color.RGBA{R: 0xCC, A: 0xFF}), color.RGBAModel.Convert(img.At(middle, middle)))
+
+	_, err = service.VerifyImage(data)
+	assert.NoError(t, err)
+
+	svg, err := service.GenerateImage(ImageFormatSVG)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(svg), `xlink:href="data:image/png;base64,`))
+}
@@ -0,0 +1,378 @@
+package utils
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/mca93/qrcode_service/models"
+	qrcode "github.com/yeqown/go-qrcode/v2"
+)
+
+const (
+	quietZoneModules    = 4  // Light border around the symbol required by ISO/IEC 18004
+	defaultModulePixels = 10 // Module size used when the template has no size
+	finderModules       = 7  // Width and height of a finder pattern
+)
+
+// ImageFormat is the output format of a rendered QR code.
+type ImageFormat string
+
+const (
+	ImageFormatPNG ImageFormat = "png"
+	ImageFormatSVG ImageFormat = "svg"
+)
+
+func (f ImageFormat) IsValid() bool {
+	switch f {
+	case ImageFormatPNG, ImageFormatSVG:
+		return true
+	default:
+		return false
+	}
+}
+
+// renderStyle describes how a QR matrix is drawn.
+type renderStyle struct {
+	Format             ImageFormat // Defaults to PNG
+	ModuleShape        models.ModuleShape
+	EyeShape           models.EyeShape
+	Foreground         color.Color
+	ForegroundGradient *models.Gradient // Overrides Foreground
+	Background         color.Color      // May be translucent or fully transparent
+	EyeColor           color.Color      // Eyes use the foreground paint when nil
+	Size               int              // Symbol width and height in pixels, including the quiet zone
+	Logo               *logoStyle       // Drawn over cleared modules in the middle of the symbol
+	Frame              *frameStyle      // Drawn around the symbol, enlarging the image
+}
+
+// imageSize returns the width and height of a symbol of the given dimension, quiet zone included.
+func (s renderStyle) imageSize(dimension int) int {
+	if s.Size > 0 {
+		return s.Size
+	}
+	return (dimension + 2*quietZoneModules) * defaultModulePixels
+}
+
+// qrRenderer implements qrcode.Writer, drawing the matrix with renderStyle and
+// encoding it in the style's format.
+type qrRenderer struct {
+	out   io.Writer
+	style renderStyle
+}
+
+var _ qrcode.Writer = (*qrRenderer)(nil)
+
+func newQRRenderer(out io.Writer, style renderStyle) *qrRenderer {
+	return &qrRenderer{out: out, style: style}
+}
+
+func (r *qrRenderer) Write(mat qrcode.Matrix) error {
+	grid := moduleGrid(mat.Bitmap())
+	size := r.style.imageSize(grid.dimension())
+	layout, err := layoutFrame(r.style.Frame, float64(size))
+	if err != nil {
+		return err
+	}
+	width, height := int(math.Ceil(layout.Width)), int(math.Ceil(layout.Height))
+
+	draw := func(c canvas) error {
+		if r.style.Frame != nil {
+			drawFrame(c, layout, r.style.Frame, r.style.Background)
+		}
+		drawSymbol(c, grid, layout.SymbolX, layout.SymbolY, size, r.style)
+		if r.style.Frame != nil {
+			return drawCaption(c, layout, r.style.Frame, r.style.Background)
+		}
+		return nil
+	}
+
+	switch r.style.Format {
+	case ImageFormatSVG:
+		c := newSVGCanvas(width, height)
+		if err := draw(c); err != nil {
+			return err
+		}
+		_, err := io.WriteString(r.out, c.String())
+		return err
+	case ImageFormatPNG, "":
+		c := newRasterCanvas(width, height)
+		if err := draw(c); err != nil {
+			return err
+		}
+		return png.Encode(r.out, c.Image())
+	default:
+		return fmt.Errorf("unsupported image format: %s", r.style.Format)
+	}
+}
+
+func (r *qrRenderer) Close() error {
+	return nil
+}
+
+// canvas is a drawing surface. Shapes are added to the current path, which fill paints and clears.
+type canvas interface {
+	moveTo(x, y float64)
+	lineTo(x, y float64)
+	quadraticTo(cx, cy, x, y float64)
+	cubicTo(c1x, c1y, c2x, c2y, x, y float64)
+	closePath()
+	rectangle(x, y, w, h float64)
+	roundedRectangle(x, y, w, h, r float64)
+	circle(cx, cy, r float64)
+	fill(p paint, evenOdd bool)
+	drawImage(img *logoImage, x, y, w, h float64)
+}
+
+// paint is a solid color or a gradient resolved to pixel coordinates.
+type paint struct {
+	color    color.Color
+	gradient *gradientPaint
+}
+
+// gradientPaint is a gradient in pixel coordinates. Linear gradients run from (X0, Y0) to
+// (X1, Y1); radial gradients are centred on (X0, Y0) with radius R.
+type gradientPaint struct {
+	Linear         bool
+	X0, Y0, X1, Y1 float64
+	R              float64
+	Stops          []gradientStop
+}
+
+type gradientStop struct {
+	Offset float64
+	Color  color.Color
+}
+
+// resolveGradient places g over the square symbol area at (x, y) with the given side.
+func resolveGradient(g *models.Gradient, x, y, side float64) (*gradientPaint, error) {
+	stops := make([]gradientStop, len(g.Stops))
+	for i, stop := range g.Stops {
+		c, err := ParseHexColor(stop.Color, nil)
+		if err != nil {
+			return nil, err
+		}
+		stops[i] = gradientStop{Offset: stop.Offset, Color: c}
+	}
+
+	if g.Type == models.GradientTypeRadial {
+		cx, cy := 0.5, 0.5
+		if g.CenterX != nil {
+			cx = *g.CenterX
+		}
+		if g.CenterY != nil {
+			cy = *g.CenterY
+		}
+		radius := g.Radius
+		if radius <= 0 {
+			radius = math.Sqrt2 / 2 // from the centre to the corners
+		}
+		return &gradientPaint{X0: x + cx*side, Y0: y + cy*side, R: radius * side, Stops: stops}, nil
+	}
+
+	// The gradient line passes through the centre and is long enough to reach the corners
+	angle := g.Angle * math.Pi / 180
+	dx, dy := math.Cos(angle), math.Sin(angle)
+	half := (math.Abs(dx) + math.Abs(dy)) * side / 2
+	cx, cy := x+side/2, y+side/2
+	return &gradientPaint{
+		Linear: true,
+		X0:     cx - dx*half, Y0: cy - dy*half,
+		X1: cx + dx*half, Y1: cy + dy*half,
+		Stops: stops,
+	}, nil
+}
+
+// moduleGrid gives shape-aware access to the symbol's modules.
+type moduleGrid [][]bool
+
+func (g moduleGrid) dimension() int {
+	return len(g)
+}
+
+// inFinder reports whether (x, y) belongs to one of the three finder patterns.
+func (g moduleGrid) inFinder(x, y int) bool {
+	far := g.dimension() - finderModules
+	return (x < finderModules && y < finderModules) ||
+		(x >= far && y < finderModules) ||
+		(x < finderModules && y >= far)
+}
+
+// dataDark reports whether (x, y) is a dark module drawn with the module shape.
+// Modules outside the symbol and inside finder patterns count as light.
+func (g moduleGrid) dataDark(x, y int) bool {
+	if x < 0 || y < 0 || x >= g.dimension() || y >= g.dimension() {
+		return false
+	}
+	return g[y][x] && !g.inFinder(x, y)
+}
+
+// cleared returns a copy of the grid with the modules in r turned light.
+func (g moduleGrid) cleared(r image.Rectangle) moduleGrid {
+	out := make(moduleGrid, len(g))
+	for y, row := range g {
+		out[y] = append([]bool(nil), row...)
+		if y >= r.Min.Y && y < r.Max.Y {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				out[y][x] = false
+			}
+		}
+	}
+	return out
+}
+
+// finderOrigins returns the top-left module of each finder pattern.
+func (g moduleGrid) finderOrigins() []image.Point {
+	far := g.dimension() - finderModules
+	return []image.Point{{X: 0, Y: 0}, {X: far, Y: 0}, {X: 0, Y: far}}
+}
+
+// drawSymbol draws the symbol described by grid, quiet zone included, in the size x size
+// square whose top-left corner is at (x, y).
+func drawSymbol(c canvas, grid moduleGrid, x, y float64, size int, style renderStyle) {
+	ms := float64(size) / float64(grid.dimension()+2*quietZoneModules)
+	offsetX, offsetY := x+ms*quietZoneModules, y+ms*quietZoneModules
+
+	// The logo takes the place of the modules under it instead of covering them
+	var logoArea image.Rectangle
+	if style.Logo != nil {
+		logoArea = style.Logo.footprint(grid.dimension())
+		grid = grid.cleared(logoArea)
+	}
+
+	if style.Background != nil {
+		if _, _, _, a := style.Background.RGBA(); a > 0 {
+			c.rectangle(x, y, float64(size), float64(size))
+			c.fill(paint{color: style.Background}, false)
+		}
+	}
+
+	foreground := paint{color: style.Foreground}
+	if style.ForegroundGradient != nil {
+		// Validated templates always resolve; fall back to the solid color otherwise
+		if gradient, err := resolveGradient(style.ForegroundGradient, offsetX, offsetY, ms*float64(grid.dimension())); err == nil {
+			foreground = paint{gradient: gradient}
+		}
+	}
+
+	// All data modules form a single path filled once
+	for row := 0; row < grid.dimension(); row++ {
+		for col := 0; col < grid.dimension(); col++ {
+			if grid.dataDark(col, row) {
+				drawModule(c, grid, col, row, offsetX+float64(col)*ms, offsetY+float64(row)*ms, ms, style.ModuleShape)
+			}
+		}
+	}
+	c.fill(foreground, false)
+
+	eyes := foreground
+	if style.EyeColor != nil {
+		eyes = paint{color: style.EyeColor}
+	}
+	for _, origin := range grid.finderOrigins() {
+		drawEye(c, offsetX+float64(origin.X)*ms, offsetY+float64(origin.Y)*ms, ms, style.EyeShape, eyes)
+	}
+
+	if !logoArea.Empty() {
+		drawLogo(c, style.Logo, grid.dimension(), logoArea, offsetX, offsetY, ms, style.Background)
+	}
+}
+
+// drawModule adds the module at grid position (x, y) and pixel position (px, py) to the current path.
+func drawModule(c canvas, grid moduleGrid, x, y int, px, py, ms float64, shape models.ModuleShape) {
+	switch shape {
+	case models.ModuleShapeCircle:
+		c.circle(px+ms/2, py+ms/2, ms/2)
+	case models.ModuleShapeDots:
+		c.circle(px+ms/2, py+ms/2, ms*0.35)
+	case models.ModuleShapeDiamond:
+		c.moveTo(px+ms/2, py)
+		c.lineTo(px+ms, py+ms/2)
+		c.lineTo(px+ms/2, py+ms)
+		c.lineTo(px, py+ms/2)
+		c.closePath()
+	case models.ModuleShapeRounded:
+		up, down := grid.dataDark(x, y-1), grid.dataDark(x, y+1)
+		left, right := grid.dataDark(x-1, y), grid.dataDark(x+1, y)
+		// A corner is rounded only when neither side meeting at it touches a dark module
+		drawCornerRect(c, px, py, ms, ms, [4]bool{!up && !left, !up && !right, !down && !right, !down && !left}, ms/2)
+	case models.ModuleShapeVerticalBars:
+		// Each vertical run of modules is drawn once, from its top module
+		if grid.dataDark(x, y-1) {
+			return
+		}
+		run := 1
+		for grid.dataDark(x, y+run) {
+			run++
+		}
+		pad := ms * 0.1
+		c.roundedRectangle(px+pad, py+pad, ms-2*pad, float64(run)*ms-2*pad, (ms-2*pad)/2)
+	case models.ModuleShapeHorizontalBars:
+		if grid.dataDark(x-1, y) {
+			return
+		}
+		run := 1
+		for grid.dataDark(x+run, y) {
+			run++
+		}
+		pad := ms * 0.1
+		c.roundedRectangle(px+pad, py+pad, float64(run)*ms-2*pad, ms-2*pad, (ms-2*pad)/2)
+	default:
+		c.rectangle(px, py, ms, ms)
+	}
+}
+
+// drawCornerRect adds a rectangle whose corners, clockwise from the top left, are rounded with radius r when set.
+func drawCornerRect(c canvas, x, y, w, h float64, rounded [4]bool, r float64) {
+	radius := func(i int) float64 {
+		if rounded[i] {
+			return r
+		}
+		return 0
+	}
+
+	c.moveTo(x+radius(0), y)
+	c.lineTo(x+w-radius(1), y)
+	c.quadraticTo(x+w, y, x+w, y+radius(1))
+	c.lineTo(x+w, y+h-radius(2))
+	c.quadraticTo(x+w, y+h, x+w-radius(2), y+h)
+	c.lineTo(x+radius(3), y+h)
+	c.quadraticTo(x, y+h, x, y+h-radius(3))
+	c.lineTo(x, y+radius(0))
+	c.quadraticTo(x, y, x+radius(0), y)
+	c.closePath()
+}
+
+// drawEye draws a finder pattern whose top-left corner is at (x, y): a 7x7 frame one module
+// thick around a 3x3 ball.
+func drawEye(c canvas, x, y, ms float64, shape models.EyeShape, p paint) {
+	outer, inner, ball := 7*ms, 5*ms, 3*ms
+	cx, cy := x+outer/2, y+outer/2
+
+	// The frame is the outer outline minus the inner one
+	switch shape {
+	case models.EyeShapeCircle:
+		c.circle(cx, cy, outer/2)
+		c.circle(cx, cy, inner/2)
+	case models.EyeShapeRounded:
+		c.roundedRectangle(x, y, outer, outer, 2*ms)
+		c.roundedRectangle(x+ms, y+ms, inner, inner, 1.2*ms)
+	default:
+		c.rectangle(x, y, outer, outer)
+		c.rectangle(x+ms, y+ms, inner, inner)
+	}
+	c.fill(p, true)
+
+	switch shape {
+	case models.EyeShapeCircle:
+		c.circle(cx, cy, ball/2)
+	case models.EyeShapeRounded:
+		c.roundedRectangle(x+2*ms, y+2*ms, ball, ball, ms)
+	default:
+		c.rectangle(x+2*ms, y+2*ms, ball, ball)
+	}
+	c.fill(p, false)
+}
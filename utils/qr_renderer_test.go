@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/stretchr/testify/assert"
+	qrcode "github.com/yeqown/go-qrcode/v2"
+)
+
+func TestRendererShapes(t *testing.T) {
+	qr, err := qrcode.New("https://example.com/qrcodes/123")
+	assert.NoError(t, err)
+
+	eye := color.RGBA{R: 0xCC, A: 0xFF}
+	shapes := []models.ModuleShape{
+		models.ModuleShapeSquare, models.ModuleShapeCircle, models.ModuleShapeRounded, models.ModuleShapeDots,
+		models.ModuleShapeDiamond, models.ModuleShapeVerticalBars, models.ModuleShapeHorizontalBars,
+	}
+	for _, shape := range shapes {
+		for _, eyeShape := range []models.EyeShape{models.EyeShapeSquare, models.EyeShapeRounded, models.EyeShapeCircle} {
+			var buf bytes.Buffer
+			style := renderStyle{
+				ModuleShape: shape,
+				EyeShape:    eyeShape,
+				Foreground:  color.Black,
+				Background:  color.White,
+				EyeColor:    eye,
+			}
+			assert.NoError(t, qr.Save(newQRRenderer(&buf, style)))
+
+			img, err := png.Decode(&buf)
+			assert.NoError(t, err)
+
+			// With 10px modules the symbol starts after a 40px quiet zone
+			assert.Equal(t, (qr.Dimension()+2*quietZoneModules)*defaultModulePixels, img.Bounds().Dx())
+			assert.Equal(t, color.RGBAModel.Convert(color.White), color.RGBAModel.Convert(img.At(5, 5)), "quiet zone for %s", shape)
+			center := quietZoneModules*defaultModulePixels + 35
+			assert.Equal(t, color.RGBAModel.Convert(eye), color.RGBAModel.Convert(img.At(center, center)), "eye ball for %s/%s", shape, eyeShape)
+		}
+	}
+}
+
+func TestRendererGradientSVG(t *testing.T) {
+	qr, err := qrcode.New("https://example.com/qrcodes/123")
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	style := renderStyle{
+		Format:      ImageFormatSVG,
+		ModuleShape: models.ModuleShapeDots,
+		EyeShape:    models.EyeShapeCircle,
+		ForegroundGradient: &models.Gradient{Type: models.GradientTypeLinear, Angle: 45, Stops: []models.GradientStop{
+			{Offset: 0, Color: "#000000"}, {Offset: 1, Color: "#1A237ECC"},
+		}},
+		Background: color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0x80},
+	}
+	assert.NoError(t, qr.Save(newQRRenderer(&buf, style)))
+
+	svg := buf.String()
+	assert.True(t, strings.HasPrefix(svg, "<svg "))
+	// One gradient definition shared by the modules and the eyes
+	assert.Equal(t, 1, strings.Count(svg, "<linearGradient"))
+	assert.Contains(t, svg, `<stop offset="1" stop-color="#1A237E" stop-opacity="0.8"/>`)
+	assert.Contains(t, svg, `fill="#FFFFFF" fill-opacity="0.5"`)
+	assert.Equal(t, 7, strings.Count(svg, `fill="url(#gradient1)"`))
+}
+
+func TestContrast(t *testing.T) {
+	assert.InDelta(t, 21, ContrastRatio(color.Black, color.White), 0.001)
+
+	c, err := ParseHexColor("#F008", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, color.NRGBA{R: 0xFF, A: 0x88}, c)
+	_, err = ParseHexColor("FF0000", nil)
+	assert.Error(t, err)
+
+	// Both stops contrast with grey, but the colors in between do not
+	grey := color.NRGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xFF}
+	contrast, err := GradientMinContrast(&models.Gradient{Type: models.GradientTypeLinear, Stops: []models.GradientStop{
+		{Offset: 0, Color: "#000"}, {Offset: 1, Color: "#FFF"},
+	}}, grey)
+	assert.NoError(t, err)
+	assert.Less(t, contrast, 1.1)
+}
+
+func TestRendererOmitsSensitiveData(t *testing.T) {
+	// Values stored before the field was marked sensitive are still plaintext
+	qr := &models.QRCode{ID: "123", DeepLinkURL: "https://example.com/qrcodes/123", Data: models.JSONMap{
+		"seat": "A12", "nationalId": "123456789", "phone": map[string]interface{}{EncryptedValueKey: "encryption-v1.abc"},
+	}}
+	template := &models.Template{
+		Size:       300,
+		Definition: models.Definition{{Name: "seat"}, {Name: "nationalId", Sensitive: true}, {Name: "phone", Sensitive: true}},
+		Content:    &models.ContentMapping{Format: models.ContentFormatKeyValue},
+		Frame:      &models.Frame{Style: models.FrameStyleBorder, Caption: "Seat {seat} {nationalId}"},
+	}
+	service := NewQRCodeService(qr, template)
+
+	preview, err := service.EncodedContentInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "seat=A12", preview.Content)
+
+	style, err := service.getRenderStyle()
+	assert.NoError(t, err)
+	assert.Equal(t, "Seat A12 ", style.Frame.Caption)
+}
+
+func TestRendererFrame(t *testing.T) {
+	qr := &models.QRCode{ID: "123", DeepLinkURL: "https://example.com/qrcodes/123", Data: models.JSONMap{"tableNumber": 12}}
+	for _, frameStyle := range []models.FrameStyle{models.FrameStyleBorder, models.FrameStyleCard, models.FrameStyleBanner} {
+		template := &models.Template{
+			Size:            300,
+			ForegroundColor: "#1A237E",
+			Frame:           &models.Frame{Style: frameStyle, Caption: "Table {tableNumber}", CaptionPosition: models.CaptionPositionTop},
+		}
+		service := NewQRCodeService(qr, template)
+
+		style, err := service.getRenderStyle()
+		assert.NoError(t, err)
+		assert.Equal(t, "Table 12", style.Frame.Caption)
+		assert.Equal(t, color.RGBAModel.Convert(style.Foreground), color.RGBAModel.Convert(style.Frame.Color))
+
+		data, err := service.GenerateImage(ImageFormatPNG)
+		assert.NoError(t, err)
+		img, err := png.Decode(bytes.NewReader(data))
+		assert.NoError(t, err)
+		// The frame and caption band are drawn around the symbol
+		assert.Greater(t, img.Bounds().Dx(), 300, "width for %s", frameStyle)
+		assert.Greater(t, img.Bounds().Dy(), img.Bounds().Dx(), "height for %s", frameStyle)
+
+		decoded, err := service.VerifyImage(data)
+		assert.NoError(t, err, "decode for %s", frameStyle)
+		if decoded != nil {
+			assert.Equal(t, qr.DeepLinkURL, decoded.Content)
+		}
+
+		svg, err := service.GenerateImage(ImageFormatSVG)
+		assert.NoError(t, err)
+		assert.Contains(t, string(svg), `fill="#1A237E"`)
+	}
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/mca93/qrcode_service/signing"
+)
+
+// ParseScannedPayload identifies the QR code a staff device scanned, from its deep link
+// (https://host/qrcodes/<id>), its short link (https://host/q/<shortId>), a GS1 Digital Link
+// or a bare QR code ID or short ID. It returns the QR code ID or short ID, or else the canonical
+// Digital Link path; both are empty for payloads that are neither, such as custom slug links.
+func ParseScannedPayload(payload string) (id, digitalLink string) {
+	payload = strings.TrimSpace(payload)
+	u, err := url.Parse(payload)
+	if err != nil {
+		return "", ""
+	}
+	if u.Scheme == "" && u.Host == "" {
+		// Signed tokens are identified by their verified subject, see SignedPayloadSubject
+		if payload == "" || strings.ContainsAny(payload, "/?# ") || signing.IsCompact(payload) {
+			return "", ""
+		}
+		return payload, ""
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", ""
+	}
+
+	if rest, ok := strings.CutPrefix(u.Path, "/qrcodes/"); ok {
+		if id, _, _ = strings.Cut(rest, "/"); id != "" {
+			return id, ""
+		}
+		return "", ""
+	}
+	if rest, ok := strings.CutPrefix(u.Path, "/q/"); ok && rest != "" && !strings.Contains(rest, "/") {
+		return rest, ""
+	}
+	if path, err := ParseGS1DigitalLinkPath(u.EscapedPath()); err == nil {
+		return "", path
+	}
+	return "", ""
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScannedPayload(t *testing.T) {
+	for payload, want := range map[string][2]string{
+		"https://qr.example.com/qrcodes/abc-123":         {"abc-123", ""},
+		"https://qr.example.com/qrcodes/abc-123/media/x": {"abc-123", ""},
+		"https://qr.example.com/q/aB3dE5fG":              {"aB3dE5fG", ""},
+		"https://qr.example.com/q/acme/summer-sale":      {"", ""},
+		" abc-123 ": {"abc-123", ""},
+		"https://id.example.com/gtin/9506000134352/lot/A1": {"", "/01/09506000134352/10/A1"},
+		"https://example.com/other":                        {"", ""},
+		"WIFI:S:guest;T:WPA;P:secret;;":                    {"", ""},
+		"":                                                 {"", ""},
+	} {
+		id, digitalLink := ParseScannedPayload(payload)
+		assert.Equal(t, want, [2]string{id, digitalLink}, payload)
+	}
+}
+
+func TestDeviceToken(t *testing.T) {
+	token, hash, err := NewDeviceToken("device-1")
+	assert.NoError(t, err)
+
+	id, secret, ok := ParseDeviceToken(token)
+	assert.True(t, ok)
+	assert.Equal(t, "device-1", id)
+	assert.True(t, CheckDeviceSecret(secret, hash))
+	assert.False(t, CheckDeviceSecret(secret+"x", hash))
+
+	_, _, ok = ParseDeviceToken("no-secret")
+	assert.False(t, ok)
+}
@@ -0,0 +1,156 @@
+package utils
+
+import "errors"
+
+// errTooManyErrors is returned when a block has more errors than its error correction codewords can restore.
+var errTooManyErrors = errors.New("too many errors to correct")
+
+// GF(256) arithmetic with the QR code field polynomial x^8 + x^4 + x^3 + x^2 + 1.
+var gfExp, gfLog = buildGaloisTables()
+
+func buildGaloisTables() (exp [512]byte, log [256]int) {
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(x)
+		log[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	// Doubled so products of two logarithms need no modulo
+	for i := 255; i < 512; i++ {
+		exp[i] = exp[i-255]
+	}
+	return exp, log
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+255-gfLog[b]]
+}
+
+// gfPow returns α^e for any integer e.
+func gfPow(e int) byte {
+	e %= 255
+	if e < 0 {
+		e += 255
+	}
+	return gfExp[e]
+}
+
+// gfEval evaluates a polynomial with coefficients in ascending order of degree at x.
+func gfEval(poly []byte, x byte) byte {
+	var y byte
+	for i := len(poly) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ poly[i]
+	}
+	return y
+}
+
+// reedSolomonCorrect corrects block in place, a codeword block whose last eccLen bytes are
+// Reed-Solomon error correction codewords, and returns the number of corrected bytes.
+func reedSolomonCorrect(block []byte, eccLen int) (int, error) {
+	n := len(block)
+	// The first byte is the coefficient of x^(n-1)
+	syndromes := make([]byte, eccLen)
+	clean := true
+	for i := range syndromes {
+		x := gfPow(i)
+		var s byte
+		for _, b := range block {
+			s = gfMul(s, x) ^ b
+		}
+		syndromes[i] = s
+		clean = clean && s == 0
+	}
+	if clean {
+		return 0, nil
+	}
+
+	// Berlekamp-Massey finds the error locator polynomial
+	locator := []byte{1}
+	previous := []byte{1}
+	errorCount, shift := 0, 1
+	lastDiscrepancy := byte(1)
+	for k := 0; k < eccLen; k++ {
+		discrepancy := syndromes[k]
+		for i := 1; i <= errorCount && i < len(locator); i++ {
+			discrepancy ^= gfMul(locator[i], syndromes[k-i])
+		}
+		if discrepancy == 0 {
+			shift++
+			continue
+		}
+
+		coef := gfDiv(discrepancy, lastDiscrepancy)
+		updated := make([]byte, maxInt(len(locator), len(previous)+shift))
+		copy(updated, locator)
+		for i, p := range previous {
+			updated[i+shift] ^= gfMul(coef, p)
+		}
+		if 2*errorCount <= k {
+			previous = locator
+			errorCount = k + 1 - errorCount
+			lastDiscrepancy = discrepancy
+			shift = 1
+		} else {
+			shift++
+		}
+		locator = updated
+	}
+	if 2*errorCount > eccLen {
+		return 0, errTooManyErrors
+	}
+
+	// Chien search: an error at index j makes α^-(n-1-j) a root of the locator
+	var positions []int
+	for j := 0; j < n; j++ {
+		if gfEval(locator, gfPow(-(n-1-j))) == 0 {
+			positions = append(positions, j)
+		}
+	}
+	if len(positions) != errorCount {
+		return 0, errTooManyErrors
+	}
+
+	// Forney: evaluator Ω = S·Λ mod x^eccLen, magnitude X·Ω(X⁻¹)/Λ'(X⁻¹)
+	evaluator := make([]byte, eccLen)
+	for i, s := range syndromes {
+		for j, l := range locator {
+			if i+j < eccLen {
+				evaluator[i+j] ^= gfMul(s, l)
+			}
+		}
+	}
+	derivative := make([]byte, len(locator))
+	for i := 1; i < len(locator); i += 2 {
+		derivative[i-1] = locator[i]
+	}
+	for _, j := range positions {
+		x := gfPow(n - 1 - j)
+		xInverse := gfPow(-(n - 1 - j))
+		denominator := gfEval(derivative, xInverse)
+		if denominator == 0 {
+			return 0, errTooManyErrors
+		}
+		block[j] ^= gfMul(x, gfDiv(gfEval(evaluator, xInverse), denominator))
+	}
+	return len(positions), nil
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
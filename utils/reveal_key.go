@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+// NewRevealKey issues the key a client app reveals sensitive QR code data with, and returns it
+// with its hash, which is all that is stored.
+func NewRevealKey() (key, keyHash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	key = base64.RawURLEncoding.EncodeToString(secret)
+	return key, hashSecret(key), nil
+}
+
+// CheckRevealKey reports whether key hashes to keyHash, in constant time. Client apps without
+// a reveal key never match.
+func CheckRevealKey(key, keyHash string) bool {
+	if key == "" || keyHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashSecret(key)), []byte(keyHash)) == 1
+}
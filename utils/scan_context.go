@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mca93/qrcode_service/models"
+)
+
+// NewScanContext describes a scan request for routing. scanCount includes the scan.
+func NewScanContext(r *http.Request, scanCount int64, visitorID, variant string) models.ScanContext {
+	return models.ScanContext{
+		OS:        DetectDeviceOS(r.UserAgent()),
+		Language:  PreferredLanguage(r.Header.Get("Accept-Language")),
+		Time:      time.Now(),
+		ScanCount: scanCount,
+		Query:     r.URL.Query(),
+		VisitorID: visitorID,
+		Variant:   variant,
+	}
+}
+
+// DetectDeviceOS tells iOS, Android and desktop browsers apart by their User-Agent. Other
+// mobile devices and clients without a browser User-Agent get "".
+func DetectDeviceOS(userAgent string) models.DeviceOS {
+	switch {
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"), strings.Contains(userAgent, "iPod"):
+		return models.DeviceOSIOS
+	case strings.Contains(userAgent, "Android"):
+		return models.DeviceOSAndroid
+	case strings.Contains(userAgent, "Mobi"), !strings.HasPrefix(userAgent, "Mozilla/"):
+		return ""
+	case strings.Contains(userAgent, "Windows"), strings.Contains(userAgent, "Macintosh"),
+		strings.Contains(userAgent, "X11"), strings.Contains(userAgent, "CrOS"):
+		return models.DeviceOSDesktop
+	default:
+		return ""
+	}
+}
+
+// PreferredLanguage returns the language tag with the highest quality in an Accept-Language
+// header, e.g. "pt-BR" for "en;q=0.8, pt-BR", or "" when there is none.
+func PreferredLanguage(acceptLanguage string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		// Ties go to the tag listed first
+		if q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+	return best
+}
+
+// VisitorID identifies the scanner of a QR code without storing their address: a hash of the
+// QR code ID, client IP and User-Agent.
+func VisitorID(qrCodeID, clientIP, userAgent string) string {
+	sum := sha256.Sum256([]byte(qrCodeID + "\n" + clientIP + "\n" + userAgent))
+	return hex.EncodeToString(sum[:16])
+}
+
+// AddQueryParam returns rawURL with a query parameter added.
+func AddQueryParam(rawURL, key, value string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set(key, value)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// VariantStats lists the stats of the current variants in order, then those of variants that
+// were removed from the routing but still have scans, filling in weights and conversion rates.
+func VariantStats(variants []models.Variant, rows []models.VariantStats) []models.VariantStats {
+	byName := make(map[string]models.VariantStats, len(rows))
+	for _, row := range rows {
+		byName[row.Variant] = row
+	}
+
+	stats := make([]models.VariantStats, 0, len(variants)+len(rows))
+	for _, variant := range variants {
+		row := byName[variant.Name]
+		row.Variant, row.Weight = variant.Name, variant.Weight
+		stats = append(stats, row)
+		delete(byName, variant.Name)
+	}
+	for _, row := range rows {
+		if _, removed := byName[row.Variant]; removed {
+			row.Weight = 0
+			stats = append(stats, row)
+		}
+	}
+
+	for i := range stats {
+		if stats[i].Scans > 0 {
+			stats[i].ConversionRate = float64(stats[i].Conversions) / float64(stats[i].Scans)
+		}
+	}
+	return stats
+}
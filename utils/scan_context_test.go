@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanContext(t *testing.T) {
+	assert.Equal(t, models.DeviceOSIOS, DetectDeviceOS("Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148"))
+	assert.Equal(t, models.DeviceOSAndroid, DetectDeviceOS("Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36"))
+	assert.Equal(t, models.DeviceOSDesktop, DetectDeviceOS("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36"))
+	assert.Equal(t, models.DeviceOS(""), DetectDeviceOS("curl/8.0"))
+
+	assert.Equal(t, "pt-BR", PreferredLanguage("en;q=0.8, pt-BR, fr;q=0.9"))
+	assert.Equal(t, "en", PreferredLanguage("en, de"))
+	assert.Equal(t, "", PreferredLanguage("*"))
+}
+
+func TestVariantStats(t *testing.T) {
+	variants := []models.Variant{{Name: "a", Weight: 50}, {Name: "b", Weight: 50}}
+	rows := []models.VariantStats{
+		{Variant: "a", Scans: 10, Visitors: 8, Conversions: 2},
+		{Variant: "old", Scans: 4, Visitors: 4, Conversions: 1},
+	}
+	stats := VariantStats(variants, rows)
+	if assert.Len(t, stats, 3) {
+		assert.Equal(t, models.VariantStats{Variant: "a", Weight: 50, Scans: 10, Visitors: 8, Conversions: 2, ConversionRate: 0.2}, stats[0])
+		assert.Equal(t, models.VariantStats{Variant: "b", Weight: 50}, stats[1])
+		assert.Equal(t, "old", stats[2].Variant)
+		assert.Equal(t, 0.25, stats[2].ConversionRate)
+	}
+
+	assert.Equal(t, VisitorID("qr-1", "10.0.0.1", "ua"), VisitorID("qr-1", "10.0.0.1", "ua"))
+	assert.NotEqual(t, VisitorID("qr-1", "10.0.0.1", "ua"), VisitorID("qr-2", "10.0.0.1", "ua"))
+}
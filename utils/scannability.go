@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/mca93/qrcode_service/models"
+)
+
+// recommendedContrastRatio is the contrast above which no warning is raised.
+const recommendedContrastRatio = 4.5
+
+// sampleQRCodeID and sampleShortID have the length of the UUIDs and short IDs given to QR
+// codes, so the sample content used to estimate the symbol size is as long as a real one.
+const sampleQRCodeID = "00000000-0000-0000-0000-000000000000"
+
+var sampleShortID = strings.Repeat("0", ShortIDLength)
+
+// errorCorrectionCapacity maps error correction levels to the approximate share of the
+// symbol that can be damaged or covered and still be restored.
+var errorCorrectionCapacity = map[models.QRCodeErrorCorrection]float64{
+	models.ErrorCorrectionL: 0.07,
+	models.ErrorCorrectionM: 0.15,
+	models.ErrorCorrectionQ: 0.25,
+	models.ErrorCorrectionH: 0.30,
+}
+
+// CheckScannability checks a template's colors and logo against what scanners can read.
+// Logo coverage is estimated for the symbol size of a typical QR code of the template, and
+// that code is rendered and decoded to confirm it reads back.
+func CheckScannability(template *models.Template) (*models.ScannabilityReport, error) {
+	report := &models.ScannabilityReport{Issues: []models.ScannabilityIssue{}}
+	addIssue := func(code string, severity models.ScannabilitySeverity, format string, args ...interface{}) {
+		report.Issues = append(report.Issues, models.ScannabilityIssue{Code: code, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Colors
+	contrast, err := ForegroundContrast(template.ForegroundColor, template.ForegroundGradient, template.EyeColor, template.BackgroundColor)
+	if err != nil {
+		return nil, err
+	}
+	report.Contrast = math.Round(contrast*100) / 100
+	switch {
+	case contrast < MinContrastRatio:
+		addIssue("LOW_CONTRAST", models.ScannabilityError,
+			"contrast between the foreground and the background is %.2f, must be at least %.1f", contrast, MinContrastRatio)
+	case contrast < recommendedContrastRatio:
+		addIssue("LOW_CONTRAST", models.ScannabilityWarning,
+			"contrast between the foreground and the background is %.2f, at least %.1f is recommended", contrast, recommendedContrastRatio)
+	}
+
+	if report.Inverted, err = IsInverted(template.ForegroundColor, template.ForegroundGradient, template.BackgroundColor); err != nil {
+		return nil, err
+	}
+	if report.Inverted {
+		addIssue("INVERTED_COLORS", models.ScannabilityWarning,
+			"the foreground is lighter than the background; many scanners cannot read inverted codes")
+	}
+
+	background, err := ParseHexColor(template.BackgroundColor, nil)
+	if err != nil {
+		return nil, err
+	}
+	if background != nil {
+		if _, _, _, a := background.RGBA(); a < 0xFFFF {
+			addIssue("TRANSPARENT_BACKGROUND", models.ScannabilityWarning,
+				"the background is not opaque; contrast depends on the surface the code is placed on")
+		}
+	}
+
+	// Logo
+	service := NewQRCodeService(sampleQRCode(template), template)
+	content, err := service.getDataToEncode()
+	if err != nil {
+		// The content mapping needs data the defaults do not provide; check with the deep link
+		withoutMapping := *template
+		withoutMapping.Content = nil
+		service = NewQRCodeService(sampleQRCode(template), &withoutMapping)
+		if content, err = service.getDataToEncode(); err != nil {
+			return nil, err
+		}
+	}
+
+	logo, logoErr := service.getLogoStyle()
+	if logoErr != nil {
+		addIssue("LOGO_UNREADABLE", models.ScannabilityError, "%v", logoErr)
+	}
+	qrCode, ec, err := service.encode(content, logo)
+	if err != nil {
+		return nil, err
+	}
+	report.ErrorCorrection = ec
+	report.ErrorCorrectionCapacity = errorCorrectionCapacity[ec]
+	if logo != nil {
+		// Error correction was raised as far as it goes, or may not be raised
+		report.LogoCoverage = math.Round(logo.coverage(qrCode.Dimension())*1000) / 1000
+		switch {
+		case report.LogoCoverage > report.ErrorCorrectionCapacity:
+			addIssue("LOGO_COVERAGE", models.ScannabilityError,
+				"the logo covers %.0f%% of the symbol but error correction level %s restores about %.0f%%",
+				report.LogoCoverage*100, ec, report.ErrorCorrectionCapacity*100)
+		case report.LogoCoverage > report.ErrorCorrectionCapacity/2:
+			addIssue("LOGO_COVERAGE", models.ScannabilityWarning,
+				"the logo covers %.0f%% of the symbol, over half of the %.0f%% error correction level %s restores",
+				report.LogoCoverage*100, report.ErrorCorrectionCapacity*100, ec)
+		}
+	}
+
+	// Render a sample code and read it back, which catches what the estimates above miss
+	if logoErr == nil {
+		if _, err := service.Verify(); errors.Is(err, ErrVerificationFailed) {
+			addIssue("DECODE_FAILED", models.ScannabilityError, "a sample code rendered with the template could not be read back: %v", err)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	report.Scannable = !report.HasErrors()
+	return report, nil
+}
+
+// sampleQRCode returns an unsaved QR code of the template, filled with field defaults.
+func sampleQRCode(template *models.Template) *models.QRCode {
+	shortID := sampleShortID
+	qr := &models.QRCode{
+		ID:         sampleQRCodeID,
+		ShortID:    &shortID,
+		TemplateID: template.ID,
+		Data:       template.Definition.ApplyDefaults(nil),
+	}
+	qr.DeepLinkURL = qr.BuildDeepLinkURL()
+	return qr
+}
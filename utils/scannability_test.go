@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func issueCodes(report *models.ScannabilityReport) map[string]models.ScannabilitySeverity {
+	codes := make(map[string]models.ScannabilitySeverity)
+	for _, issue := range report.Issues {
+		codes[issue.Code] = issue.Severity
+	}
+	return codes
+}
+
+func TestCheckScannabilityColors(t *testing.T) {
+	// Pale yellow on white
+	report, err := CheckScannability(&models.Template{ForegroundColor: "#FFF59D", BackgroundColor: "#FFFFFF"})
+	assert.NoError(t, err)
+	assert.False(t, report.Scannable)
+	assert.Equal(t, models.ScannabilityError, issueCodes(report)["LOW_CONTRAST"])
+
+	report, err = CheckScannability(&models.Template{ForegroundColor: "#FFFFFF", BackgroundColor: "#000000"})
+	assert.NoError(t, err)
+	assert.True(t, report.Scannable)
+	assert.True(t, report.Inverted)
+	assert.Equal(t, models.ScannabilityWarning, issueCodes(report)["INVERTED_COLORS"])
+
+	report, err = CheckScannability(&models.Template{})
+	assert.NoError(t, err)
+	assert.True(t, report.Scannable)
+	assert.Empty(t, report.Issues)
+	assert.Equal(t, 21.0, report.Contrast)
+}
+
+func TestCheckScannabilityLogo(t *testing.T) {
+	logoPath := storeTestAsset(t, "logos", testPNGLogo(t, 80, 80, color.Transparent), ".png")
+
+	// The modules cleared for the logo need more than level L restores, so the level is raised
+	report, err := CheckScannability(&models.Template{LogoURL: logoPath, Size: 400, ErrorCorrection: models.ErrorCorrectionL})
+	assert.NoError(t, err)
+	assert.Greater(t, report.LogoCoverage, 0.07)
+	assert.Equal(t, models.ErrorCorrectionQ, report.ErrorCorrection)
+	assert.True(t, report.Scannable)
+	assert.NotContains(t, issueCodes(report), "LOGO_COVERAGE")
+
+	// Level H is as far as it goes
+	padding := 4.0
+	report, err = CheckScannability(&models.Template{LogoURL: logoPath, Size: 400, LogoOptions: &models.LogoOptions{Scale: 0.3, Padding: &padding}})
+	assert.NoError(t, err)
+	assert.Equal(t, models.ErrorCorrectionH, report.ErrorCorrection)
+	assert.Equal(t, models.ScannabilityWarning, issueCodes(report)["LOGO_COVERAGE"])
+
+	report, err = CheckScannability(&models.Template{LogoURL: "logos/missing.png"})
+	assert.NoError(t, err)
+	assert.False(t, report.Scannable)
+	assert.Equal(t, models.ScannabilityError, issueCodes(report)["LOGO_UNREADABLE"])
+}
+
+func TestCheckScannabilityDecode(t *testing.T) {
+	logoPath := storeTestAsset(t, "logos", testPNGLogo(t, 80, 80, color.RGBA{R: 200, A: 255}), ".png")
+
+	// A logo clearing most of the modules leaves too few codewords to restore
+	padding := 4.0
+	report, err := CheckScannability(&models.Template{LogoURL: logoPath, Size: 400, LogoOptions: &models.LogoOptions{Scale: 0.3, Padding: &padding}})
+	assert.NoError(t, err)
+	assert.Equal(t, models.ScannabilityError, issueCodes(report)["DECODE_FAILED"])
+
+	report, err = CheckScannability(&models.Template{LogoURL: logoPath, Size: 400, ErrorCorrection: models.ErrorCorrectionL, Shape: models.ModuleShapeDots})
+	assert.NoError(t, err)
+	assert.NotContains(t, issueCodes(report), "DECODE_FAILED")
+	assert.True(t, report.Scannable)
+}
+
+func TestSampleQRCodeUsesShortLink(t *testing.T) {
+	qr := sampleQRCode(&models.Template{})
+	assert.Equal(t, models.DeepLinkBaseURL()+"/q/"+sampleShortID, qr.DeepLinkURL)
+	assert.True(t, IsShortID(sampleShortID))
+}
@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mca93/qrcode_service/models"
+)
+
+const (
+	// EncryptedValueKey is the key of the object a sensitive value is stored as in QRCode.Data,
+	// e.g. {"$encrypted": "encryption-v1.<ciphertext>"}.
+	EncryptedValueKey = "$encrypted"
+	// MaskedValue replaces sensitive values in responses. Sending it back in an update keeps
+	// the stored value.
+	MaskedValue = "********"
+)
+
+// EncryptSensitiveData returns a copy of data with the values of the definition's sensitive
+// fields encrypted. Each value is bound to the QR code and field, so it cannot be moved to
+// another one. Values stored before a field was marked sensitive are encrypted when the
+// template is updated to mark it.
+func EncryptSensitiveData(data models.JSONMap, definition models.Definition, qrCodeID string) (models.JSONMap, error) {
+	result := copyData(data)
+	for _, field := range definition {
+		value, exists := result[field.Name]
+		if !field.Sensitive || !exists || value == nil {
+			continue
+		}
+		if _, encrypted := encryptedValue(value); encrypted {
+			continue
+		}
+		plaintext, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		ciphertext, err := Encrypt(plaintext, sensitiveValueAD(qrCodeID, field.Name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt %s: %w", field.Name, err)
+		}
+		result[field.Name] = map[string]interface{}{EncryptedValueKey: ciphertext}
+	}
+	return result, nil
+}
+
+// DecryptSensitiveData returns a copy of data with its encrypted values decrypted.
+func DecryptSensitiveData(data models.JSONMap, qrCodeID string) (models.JSONMap, error) {
+	result := copyData(data)
+	for name, value := range result {
+		ciphertext, encrypted := encryptedValue(value)
+		if !encrypted {
+			continue
+		}
+		plaintext, err := Decrypt(ciphertext, sensitiveValueAD(qrCodeID, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", name, err)
+		}
+		var decrypted interface{}
+		if err := json.Unmarshal(plaintext, &decrypted); err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", name, err)
+		}
+		result[name] = decrypted
+	}
+	return result, nil
+}
+
+// MaskSensitiveData returns a copy of data with its encrypted values replaced by MaskedValue.
+func MaskSensitiveData(data models.JSONMap) models.JSONMap {
+	result := copyData(data)
+	for name, value := range result {
+		if _, encrypted := encryptedValue(value); encrypted {
+			result[name] = MaskedValue
+		}
+	}
+	return result
+}
+
+// OmitSensitiveData returns a copy of data without encrypted values nor values of the
+// definition's sensitive fields, for what is encoded in or printed on a QR code.
+func OmitSensitiveData(data models.JSONMap, definition models.Definition) models.JSONMap {
+	result := copyData(data)
+	for name, value := range result {
+		if _, encrypted := encryptedValue(value); encrypted {
+			delete(result, name)
+		}
+	}
+	for _, field := range definition {
+		if field.Sensitive {
+			delete(result, field.Name)
+		}
+	}
+	return result
+}
+
+// UnmaskSensitiveData returns a copy of data in which values sent back as MaskedValue are the
+// decrypted values stored, so updates can resend the data they read.
+func UnmaskSensitiveData(data, stored models.JSONMap, qrCodeID string) (models.JSONMap, error) {
+	result := copyData(data)
+	masked := models.JSONMap{}
+	for name, value := range result {
+		if _, encrypted := encryptedValue(stored[name]); encrypted && value == MaskedValue {
+			masked[name] = stored[name]
+		}
+	}
+	if len(masked) == 0 {
+		return result, nil
+	}
+	decrypted, err := DecryptSensitiveData(masked, qrCodeID)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range decrypted {
+		result[name] = value
+	}
+	return result, nil
+}
+
+// encryptedValue returns the ciphertext of a stored sensitive value.
+func encryptedValue(value interface{}) (string, bool) {
+	object, ok := value.(map[string]interface{})
+	if !ok || len(object) != 1 {
+		return "", false
+	}
+	ciphertext, ok := object[EncryptedValueKey].(string)
+	return ciphertext, ok
+}
+
+func sensitiveValueAD(qrCodeID, field string) []byte {
+	return []byte("qrcode:" + qrCodeID + ":" + field)
+}
+
+func copyData(data models.JSONMap) models.JSONMap {
+	if data == nil {
+		return nil
+	}
+	result := make(models.JSONMap, len(data))
+	for key, value := range data {
+		result[key] = value
+	}
+	return result
+}
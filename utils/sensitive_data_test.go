@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSealWithKey(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	ciphertext, err := sealWithKey("encryption-v1", key, []byte(`"+351912345678"`), []byte("qrcode:a:phone"))
+	assert.NoError(t, err)
+	assert.Contains(t, ciphertext, "encryption-v1.")
+
+	plaintext, err := openWithKey(key, ciphertext, []byte("qrcode:a:phone"))
+	assert.NoError(t, err)
+	assert.Equal(t, `"+351912345678"`, string(plaintext))
+
+	// Values cannot be moved to another QR code or field
+	_, err = openWithKey(key, ciphertext, []byte("qrcode:b:phone"))
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+	tampered := ciphertext[:len(ciphertext)-2] + "AA"
+	_, err = openWithKey(key, tampered, []byte("qrcode:a:phone"))
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+}
+
+func TestMaskSensitiveData(t *testing.T) {
+	data := models.JSONMap{
+		"name":  "Ana",
+		"phone": map[string]interface{}{EncryptedValueKey: "encryption-v1.abc"},
+		"meta":  map[string]interface{}{"a": 1, EncryptedValueKey: "not an envelope"},
+	}
+	masked := MaskSensitiveData(data)
+	assert.Equal(t, "Ana", masked["name"])
+	assert.Equal(t, MaskedValue, masked["phone"])
+	assert.Equal(t, data["meta"], masked["meta"])
+	// The stored data is left alone
+	assert.NotEqual(t, MaskedValue, data["phone"])
+
+	// Plaintext data needs no keys
+	decrypted, err := DecryptSensitiveData(models.JSONMap{"name": "Ana"}, "qr-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Ana", decrypted["name"])
+	unmasked, err := UnmaskSensitiveData(models.JSONMap{"name": MaskedValue}, models.JSONMap{"name": "Ana"}, "qr-1")
+	assert.NoError(t, err)
+	assert.Equal(t, MaskedValue, unmasked["name"])
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// ShortIDLength is the length of the base62 short IDs of QR codes. 62^8 IDs keep random
+// collisions rare. Callers draw another ID when one is taken.
+const ShortIDLength = 8
+
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// NewShortID returns a random base62 short ID, the resolver path of QR codes that is shorter
+// than their UUID.
+func NewShortID() (string, error) {
+	id := make([]byte, ShortIDLength)
+	max := big.NewInt(int64(len(base62Alphabet)))
+	for i := range id {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		id[i] = base62Alphabet[n.Int64()]
+	}
+	return string(id), nil
+}
+
+// IsShortID reports whether s has the form of a short ID.
+func IsShortID(s string) bool {
+	if len(s) != ShortIDLength {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewShortID(t *testing.T) {
+	id, err := NewShortID()
+	assert.NoError(t, err)
+	assert.Len(t, id, ShortIDLength)
+	assert.True(t, IsShortID(id))
+
+	other, err := NewShortID()
+	assert.NoError(t, err)
+	assert.NotEqual(t, id, other)
+
+	assert.False(t, IsShortID("aB3dE5f"))
+	assert.False(t, IsShortID("aB3-E5fG"))
+	assert.False(t, IsShortID("00000000-0000-0000-0000-000000000000"))
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/signing"
+)
+
+// SignedPayloadParam is the query parameter that carries the token of query-embedded payloads.
+const SignedPayloadParam = "sig"
+
+var (
+	// ErrSigningNotConfigured is returned when a template signs its payloads but there is no signing key.
+	ErrSigningNotConfigured = errors.New("payload signing is not configured")
+	// ErrSignedPayloadNotYetValid is returned for tokens of codes that are not active yet.
+	ErrSignedPayloadNotYetValid = errors.New("signed payload is not valid yet")
+	// ErrSignedPayloadExpired is returned for tokens of expired codes.
+	ErrSignedPayloadExpired = errors.New("signed payload has expired")
+)
+
+// SignedPayloadClaims returns the claims signed into the QR code: its ID, creation, activation
+// and expiry times, and the selected Data fields it has.
+func SignedPayloadClaims(payload *models.SignedPayload, qr *models.QRCode) models.SignedPayloadClaims {
+	// Signing at the creation time keeps Ed25519 tokens, and so the rendered code, stable
+	claims := models.SignedPayloadClaims{Subject: qr.ID, IssuedAt: qr.CreatedAt.Unix()}
+	if qr.ActivatesAt != nil {
+		claims.NotBefore = qr.ActivatesAt.Unix()
+	}
+	if qr.ExpiresAt != nil {
+		claims.ExpiresAt = qr.ExpiresAt.Unix()
+	}
+	for _, name := range payload.Fields {
+		if value, ok := qr.Data[name]; ok {
+			if claims.Data == nil {
+				claims.Data = make(map[string]interface{}, len(payload.Fields))
+			}
+			claims.Data[name] = value
+		}
+	}
+	return claims
+}
+
+// SignPayload embeds a token signed with the key set's signing key in content, as the
+// template's signed payload options ask.
+func SignPayload(keys *signing.KeySet, payload *models.SignedPayload, qr *models.QRCode, content string) (string, error) {
+	if keys == nil || keys.SigningKey() == nil {
+		return "", ErrSigningNotConfigured
+	}
+	token, err := signing.Sign(keys.SigningKey(), SignedPayloadClaims(payload, qr))
+	if err != nil {
+		return "", err
+	}
+	if payload.Embed == models.SignedPayloadEmbedQuery {
+		return AddQueryParam(content, SignedPayloadParam, token)
+	}
+	return token, nil
+}
+
+// SignedPayloadToken returns the token in content read from a QR code, which is either the
+// token itself or a URL carrying it in its sig parameter.
+func SignedPayloadToken(content string) string {
+	content = strings.TrimSpace(content)
+	if strings.Contains(content, "://") {
+		if u, err := url.Parse(content); err == nil {
+			return u.Query().Get(SignedPayloadParam)
+		}
+	}
+	return content
+}
+
+// SignedPayloadSubject returns the ID of the QR code whose signed token content carries, as the
+// token itself or in the sig parameter of a URL. Only the signature is checked here; whether
+// the code still resolves is left to the QR code's own checks.
+func SignedPayloadSubject(keys *signing.KeySet, content string) (string, bool) {
+	token := SignedPayloadToken(content)
+	if !signing.IsCompact(token) {
+		return "", false
+	}
+	claims, _, _ := VerifySignedPayload(keys, token, time.Now())
+	if claims == nil || claims.Subject == "" {
+		return "", false
+	}
+	return claims.Subject, true
+}
+
+// StripSignedPayload removes the sig parameter from a URL read from a QR code, leaving the
+// deep link it was added to.
+func StripSignedPayload(content string) string {
+	content = strings.TrimSpace(content)
+	u, err := url.Parse(content)
+	if err != nil || u.RawQuery == "" {
+		return content
+	}
+	query := u.Query()
+	if !query.Has(SignedPayloadParam) {
+		return content
+	}
+	query.Del(SignedPayloadParam)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// VerifySignedPayload checks the signature and validity period of a token at now. It returns
+// the claims whenever the signature verifies, so callers can report on expired tokens.
+func VerifySignedPayload(keys *signing.KeySet, token string, now time.Time) (*models.SignedPayloadClaims, string, error) {
+	if keys == nil {
+		return nil, "", ErrSigningNotConfigured
+	}
+	var claims models.SignedPayloadClaims
+	kid, err := keys.Verify(token, &claims)
+	if err != nil {
+		return nil, "", err
+	}
+	switch {
+	case claims.NotBefore != 0 && now.Unix() < claims.NotBefore:
+		return &claims, kid, ErrSignedPayloadNotYetValid
+	case claims.ExpiresAt != 0 && now.Unix() >= claims.ExpiresAt:
+		return &claims, kid, ErrSignedPayloadExpired
+	}
+	return &claims, kid, nil
+}
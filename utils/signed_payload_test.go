@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/signing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignedPayload(t *testing.T) {
+	key, _ := signing.GenerateKey("k1", signing.AlgorithmEdDSA)
+	keys := signing.NewKeySet(key)
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	expires := created.Add(24 * time.Hour)
+	qr := &models.QRCode{
+		ID:        "qr-1",
+		CreatedAt: created,
+		ExpiresAt: &expires,
+		Data:      models.JSONMap{"seat": "A12", "email": "holder@example.com"},
+	}
+	payload := &models.SignedPayload{Fields: []string{"seat", "missing"}}
+
+	token, err := SignPayload(keys, payload, qr, "https://example.com/qrcodes/qr-1")
+	assert.NoError(t, err)
+	again, _ := SignPayload(keys, payload, qr, "https://example.com/qrcodes/qr-1")
+	assert.Equal(t, token, again) // The rendered code does not change
+
+	claims, kid, err := VerifySignedPayload(keys, token, created.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, "k1", kid)
+	assert.Equal(t, "qr-1", claims.Subject)
+	assert.Equal(t, map[string]interface{}{"seat": "A12"}, claims.Data) // Only the selected fields
+	assert.Equal(t, expires.Unix(), claims.ExpiresAt)
+
+	_, _, err = VerifySignedPayload(keys, token, expires)
+	assert.ErrorIs(t, err, ErrSignedPayloadExpired)
+
+	// Embedded in the deep link
+	payload.Embed = models.SignedPayloadEmbedQuery
+	content, err := SignPayload(keys, payload, qr, "https://example.com/qrcodes/qr-1")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(content, "https://example.com/qrcodes/qr-1?sig="))
+	assert.Equal(t, token, SignedPayloadToken(content))
+	assert.Equal(t, token, SignedPayloadToken(token))
+
+	_, err = SignPayload(nil, payload, qr, "")
+	assert.ErrorIs(t, err, ErrSigningNotConfigured)
+}
+
+func TestSignedPayloadRoundTrip(t *testing.T) {
+	key, _ := signing.GenerateKey("k1", signing.AlgorithmES256)
+	keys := signing.NewKeySet(key)
+	qr := &models.QRCode{ID: "0b6c2f0e-5d1a-4c59-9c0e-1f2a3b4c5d6e", CreatedAt: time.Now()}
+	deepLink := "https://example.com/q/aB3dE5fG"
+
+	// A bare token, the default embedding, is identified by its subject and not taken for an ID
+	token, err := SignPayload(keys, &models.SignedPayload{}, qr, deepLink)
+	assert.NoError(t, err)
+	assert.True(t, signing.IsCompact(token))
+	id, ok := SignedPayloadSubject(keys, token)
+	assert.True(t, ok)
+	assert.Equal(t, qr.ID, id)
+	id, digitalLink := ParseScannedPayload(token)
+	assert.Empty(t, id)
+	assert.Empty(t, digitalLink)
+
+	// A deep link carrying the token resolves by its subject, or by the link once stripped
+	content, err := SignPayload(keys, &models.SignedPayload{Embed: models.SignedPayloadEmbedQuery}, qr, deepLink)
+	assert.NoError(t, err)
+	id, ok = SignedPayloadSubject(keys, content)
+	assert.True(t, ok)
+	assert.Equal(t, qr.ID, id)
+	assert.Equal(t, deepLink, StripSignedPayload(content))
+	id, _ = ParseScannedPayload(content)
+	assert.Equal(t, "aB3dE5fG", id)
+
+	// Tokens of other keys, or tampered ones, are not trusted
+	other, _ := signing.GenerateKey("k1", signing.AlgorithmES256)
+	_, ok = SignedPayloadSubject(signing.NewKeySet(other), token)
+	assert.False(t, ok)
+	_, ok = SignedPayloadSubject(keys, token[:len(token)-4]+"AAAA")
+	assert.False(t, ok)
+	_, ok = SignedPayloadSubject(keys, deepLink)
+	assert.False(t, ok)
+	assert.Equal(t, deepLink, StripSignedPayload(deepLink))
+}
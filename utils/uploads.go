@@ -0,0 +1,361 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mca93/qrcode_service/storage"
+)
+
+const (
+	MaxUploadDimension = 4096     // Widest and tallest image accepted for upload, in pixels
+	MaxMediaBytes      = 20 << 20 // Largest Media field upload; templates may set a lower maxSize
+	uploadJPEGQuality  = 90
+)
+
+var (
+	// ErrUnsupportedMedia is returned for Media field uploads of a type that is not supported.
+	ErrUnsupportedMedia = errors.New("media must be a PNG, JPEG, GIF, WebP or SVG image, a PDF document, or MP4, WebM, MP3, WAV or Ogg media")
+	// ErrMediaTooLarge is returned for Media field uploads over MaxMediaBytes.
+	ErrMediaTooLarge = fmt.Errorf("media must be at most %d MB", MaxMediaBytes>>20)
+)
+
+// mediaExtensions are the file extensions of the media types stored as uploaded.
+var mediaExtensions = map[string]string{
+	"application/pdf": ".pdf",
+	"video/mp4":       ".mp4",
+	"video/webm":      ".webm",
+	"audio/mpeg":      ".mp3",
+	"audio/wave":      ".wav",
+	"application/ogg": ".ogg",
+}
+
+// allowedSVGElements are the SVG elements kept in uploaded logos: the ones logos are drawn with.
+var allowedSVGElements = map[string]bool{
+	"svg": true, "g": true, "defs": true, "title": true, "desc": true, "style": true, "use": true,
+	"path": true, "rect": true, "circle": true, "ellipse": true, "line": true, "polyline": true, "polygon": true,
+	"linearGradient": true, "radialGradient": true, "stop": true,
+}
+
+// SanitizeLogo checks an uploaded logo and re-encodes it, which drops metadata and anything
+// appended to the image. Raster images keep their format, except GIF and WebP which become
+// PNG; SVG documents keep only drawing elements. It returns the new content and its file extension.
+func SanitizeLogo(data []byte) ([]byte, string, error) {
+	if len(data) > MaxLogoBytes {
+		return nil, "", ErrLogoTooLarge
+	}
+
+	switch contentType := http.DetectContentType(data); contentType {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		return sanitizeRaster(data, contentType, false)
+	}
+	if !isSVG(data) {
+		return nil, "", ErrUnsupportedLogo
+	}
+	return sanitizeSVGImage(data)
+}
+
+// SanitizeMedia checks an upload for a Media field and returns its content, media type and
+// file extension. Images are cleaned as SanitizeLogo does, except that GIF animations are kept;
+// the other supported types, listed in mediaExtensions, are stored as uploaded. The media type
+// is sniffed from the content and is the one the upload had, before WebP images become PNG.
+func SanitizeMedia(data []byte) ([]byte, string, string, error) {
+	if len(data) > MaxMediaBytes {
+		return nil, "", "", ErrMediaTooLarge
+	}
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	switch contentType {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		clean, ext, err := sanitizeRaster(data, contentType, true)
+		return clean, contentType, ext, err
+	}
+	if ext, ok := mediaExtensions[contentType]; ok {
+		return data, contentType, ext, nil
+	}
+	if !isSVG(data) {
+		return nil, "", "", ErrUnsupportedMedia
+	}
+	clean, ext, err := sanitizeSVGImage(data)
+	return clean, "image/svg+xml", ext, err
+}
+
+// StoredMediaType returns the media type of the media SanitizeMedia stored under key, by its
+// extension. WebP images are stored, and so reported, as PNG.
+func StoredMediaType(key string) string {
+	ext := path.Ext(key)
+	for contentType, mediaExt := range mediaExtensions {
+		if mediaExt == ext {
+			return contentType
+		}
+	}
+	switch ext {
+	case ".png":
+		return "image/png"
+	case ".jpg":
+		return "image/jpeg"
+	case ".gif":
+		return "image/gif"
+	case ".svg":
+		return "image/svg+xml"
+	}
+	return "application/octet-stream"
+}
+
+// sanitizeSVGImage keeps only the drawing elements of an SVG document.
+func sanitizeSVGImage(data []byte) ([]byte, string, error) {
+	clean, err := sanitizeSVG(data)
+	if err != nil {
+		return nil, "", err
+	}
+	// The cleaned document must still draw
+	if _, err := parseLogo(clean); err != nil {
+		return nil, "", err
+	}
+	return clean, ".svg", nil
+}
+
+// sanitizeRaster re-encodes a PNG, JPEG, GIF or WebP image. JPEG images stay JPEG, GIF images
+// stay GIF with all their frames when keepGIF is set, and the others become PNG.
+func sanitizeRaster(data []byte, contentType string, keepGIF bool) ([]byte, string, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	if config.Width > MaxUploadDimension || config.Height > MaxUploadDimension {
+		return nil, "", fmt.Errorf("image is %dx%d pixels, at most %dx%d are supported",
+			config.Width, config.Height, MaxUploadDimension, MaxUploadDimension)
+	}
+
+	var buf bytes.Buffer
+	if contentType == "image/gif" && keepGIF {
+		animation, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to decode image: %w", err)
+		}
+		if err := gif.EncodeAll(&buf, animation); err != nil {
+			return nil, "", fmt.Errorf("failed to encode image: %w", err)
+		}
+		return buf.Bytes(), ".gif", nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	if contentType == "image/jpeg" {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: uploadJPEGQuality}); err != nil {
+			return nil, "", fmt.Errorf("failed to encode image: %w", err)
+		}
+		return buf.Bytes(), ".jpg", nil
+	}
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), ".png", nil
+}
+
+// sanitizeSVG rewrites an SVG document keeping only drawing elements and their presentation
+// attributes. Scripts, event handlers, foreign content, metadata and references to other
+// documents are dropped.
+func sanitizeSVG(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	skip := 0 // Depth inside a dropped element
+	open := []string{}
+
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SVG logo: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if skip > 0 || t.Name.Space != "" || !allowedSVGElements[t.Name.Local] {
+				skip++
+				continue
+			}
+			if len(open) == 0 && t.Name.Local != "svg" {
+				return nil, errors.New("SVG logo must have an <svg> root element")
+			}
+			out.WriteString("<" + t.Name.Local)
+			for _, attr := range t.Attr {
+				if safeSVGAttr(attr) {
+					out.WriteString(" " + svgAttrName(attr.Name) + `="`)
+					if err := xml.EscapeText(&out, []byte(attr.Value)); err != nil {
+						return nil, err
+					}
+					out.WriteString(`"`)
+				}
+			}
+			out.WriteString(">")
+			open = append(open, t.Name.Local)
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if len(open) == 0 {
+				return nil, errors.New("failed to parse SVG logo: unbalanced elements")
+			}
+			out.WriteString("</" + open[len(open)-1] + ">")
+			open = open[:len(open)-1]
+		case xml.CharData:
+			if skip > 0 || len(open) == 0 {
+				continue
+			}
+			switch open[len(open)-1] {
+			case "style":
+				if !safeCSS(string(t)) {
+					return nil, errors.New("SVG logo styles must not refer to other documents")
+				}
+			case "title", "desc":
+			default:
+				continue // Text is only drawn by elements that are not kept
+			}
+			if err := xml.EscapeText(&out, t); err != nil {
+				return nil, err
+			}
+		}
+		// Comments, processing instructions and DOCTYPE declarations are dropped
+	}
+
+	if len(open) > 0 || out.Len() == 0 {
+		return nil, errors.New("failed to parse SVG logo: incomplete document")
+	}
+	return out.Bytes(), nil
+}
+
+// safeSVGAttr reports whether an attribute of an uploaded SVG element is kept.
+func safeSVGAttr(attr xml.Attr) bool {
+	name := strings.ToLower(attr.Name.Local)
+	switch attr.Name.Space {
+	case "":
+		if strings.HasPrefix(name, "on") {
+			return false // Event handlers
+		}
+	case "xmlns":
+		return name == "xlink"
+	case "xlink":
+		if name != "href" {
+			return false
+		}
+	default:
+		return false // Editor metadata such as inkscape: and sodipodi: attributes
+	}
+
+	switch name {
+	case "href":
+		return strings.HasPrefix(strings.TrimSpace(attr.Value), "#")
+	case "xmlns":
+		return attr.Value == "http://www.w3.org/2000/svg"
+	case "style":
+		return safeCSS(attr.Value)
+	}
+	return safeCSS(attr.Value) // e.g. fill="url(#gradient)"
+}
+
+func svgAttrName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// safeCSS reports whether CSS only refers to fragments of the same document.
+func safeCSS(css string) bool {
+	lower := strings.ToLower(css)
+	if strings.Contains(lower, "@import") || strings.Contains(lower, "expression(") || strings.Contains(lower, `\`) {
+		return false
+	}
+	for rest := lower; ; {
+		i := strings.Index(rest, "url(")
+		if i < 0 {
+			return true
+		}
+		rest = strings.TrimLeft(rest[i+len("url("):], " \t\n\r'\"")
+		if !strings.HasPrefix(rest, "#") {
+			return false
+		}
+	}
+}
+
+// ValidateFont checks that data is a TTF or OTF font that can draw text and returns its file extension.
+func ValidateFont(data []byte) (string, error) {
+	if len(data) > MaxFontBytes {
+		return "", ErrFontTooLarge
+	}
+	f, err := parseFont(data)
+	if err != nil {
+		return "", err
+	}
+	if _, err := measureText(f, "Scan me", 12); err != nil {
+		return "", err
+	}
+	if bytes.HasPrefix(data, []byte("OTTO")) {
+		return ".otf", nil
+	}
+	return ".ttf", nil
+}
+
+// SaveUpload stores data under prefix, named by the hex SHA-256 of the content, and returns its
+// key, e.g. "logos/<sha256>.png". Identical uploads share an object, so objects are only removed
+// by CollectUploads. Storing an object again refreshes its time, so it is not collected before
+// its template is saved.
+func SaveUpload(ctx context.Context, store storage.Storage, prefix string, data []byte, ext string) (string, error) {
+	sum := sha256.Sum256(data)
+	key := prefix + "/" + hex.EncodeToString(sum[:]) + ext
+	if err := store.Put(ctx, key, data, storage.ContentType(key)); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// CollectUploads removes the objects under prefix that are not in referenced and were last
+// written before grace ago, and returns their keys. The grace period covers uploads whose
+// template is not saved yet.
+func CollectUploads(ctx context.Context, store storage.Storage, prefix string, referenced map[string]bool, grace time.Duration) ([]string, error) {
+	objects, err := store.List(ctx, prefix+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-grace)
+	var removed []string
+	for _, object := range objects {
+		if referenced[object.Key] || object.ModTime.After(cutoff) {
+			continue
+		}
+		if err := store.Delete(ctx, object.Key); err != nil {
+			return removed, err
+		}
+		removed = append(removed, object.Key)
+	}
+	return removed, nil
+}
+
+// AssetKey returns the storage key of an uploaded logo or font. Templates saved before assets
+// moved to storage hold paths under the default ./uploads directory instead.
+func AssetKey(ref string) string {
+	return strings.TrimPrefix(path.Clean(filepath.ToSlash(ref)), "uploads/")
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mca93/qrcode_service/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeLogo(t *testing.T) {
+	// Anything appended to an image is dropped by re-encoding it
+	logo := append(testPNGLogo(t, 40, 20, color.Black), []byte("<script>alert(1)</script>")...)
+	clean, ext, err := SanitizeLogo(logo)
+	assert.NoError(t, err)
+	assert.Equal(t, ".png", ext)
+	assert.False(t, bytes.Contains(clean, []byte("script")))
+	img, err := png.Decode(bytes.NewReader(clean))
+	assert.NoError(t, err)
+	assert.Equal(t, 40, img.Bounds().Dx())
+
+	_, _, err = SanitizeLogo(testPNGLogo(t, MaxUploadDimension+1, 1, color.Black))
+	assert.ErrorContains(t, err, "pixels")
+	_, _, err = SanitizeLogo([]byte("<html><body>not a logo</body></html>"))
+	assert.ErrorIs(t, err, ErrUnsupportedLogo)
+
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 100 50" onload="alert(1)">
+<script>alert(1)</script>
+<foreignObject><div>html</div></foreignObject>
+<image xlink:href="https://example.com/track.png"/>
+<rect id="r" width="100" height="50" fill="#1565C0"/>
+<use xlink:href="#r"/>
+</svg>`
+	clean, ext, err = SanitizeLogo([]byte(svg))
+	assert.NoError(t, err)
+	assert.Equal(t, ".svg", ext)
+	for _, dropped := range []string{"onload", "script", "foreignObject", "<image", "example.com"} {
+		assert.False(t, strings.Contains(string(clean), dropped), dropped)
+	}
+	assert.True(t, strings.Contains(string(clean), `<use xlink:href="#r">`))
+
+	_, _, err = SanitizeLogo([]byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><style>@import url(https://example.com/a.css);</style></svg>`))
+	assert.Error(t, err)
+}
+
+func TestSanitizeMedia(t *testing.T) {
+	// Animations are kept
+	frame := image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.Black, color.White})
+	var buf bytes.Buffer
+	assert.NoError(t, gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{frame, frame}, Delay: []int{10, 10}}))
+	clean, contentType, ext, err := SanitizeMedia(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, "image/gif", contentType)
+	assert.Equal(t, ".gif", ext)
+	animation, err := gif.DecodeAll(bytes.NewReader(clean))
+	assert.NoError(t, err)
+	assert.Len(t, animation.Image, 2)
+
+	pdf := []byte("%PDF-1.4\n%%EOF\n")
+	clean, contentType, ext, err = SanitizeMedia(pdf)
+	assert.NoError(t, err)
+	assert.Equal(t, "application/pdf", contentType)
+	assert.Equal(t, ".pdf", ext)
+	assert.Equal(t, pdf, clean)
+
+	// References are checked against the type the stored media has
+	assert.Equal(t, "application/pdf", StoredMediaType("media/qr-1/menu/abc.pdf"))
+	assert.Equal(t, "image/jpeg", StoredMediaType("media/qr-1/photo/abc.jpg"))
+	assert.Equal(t, "application/octet-stream", StoredMediaType("media/qr-1/photo/abc"))
+
+	_, _, _, err = SanitizeMedia([]byte("<html><script>alert(1)</script></html>"))
+	assert.ErrorIs(t, err, ErrUnsupportedMedia)
+}
+
+func TestUploadStorage(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewLocal(t.TempDir(), "", nil)
+	logo := testPNGLogo(t, 10, 10, color.Black)
+
+	// Identical uploads share one object
+	key, err := SaveUpload(ctx, store, "logos", logo, ".png")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, "logos/") && strings.HasSuffix(key, ".png"))
+	again, err := SaveUpload(ctx, store, "logos", logo, ".png")
+	assert.NoError(t, err)
+	assert.Equal(t, key, again)
+	other, err := SaveUpload(ctx, store, "logos", testPNGLogo(t, 10, 10, color.White), ".png")
+	assert.NoError(t, err)
+	assert.NotEqual(t, key, other)
+
+	// Recent objects are spared until the grace period is over
+	removed, err := CollectUploads(ctx, store, "logos", map[string]bool{key: true}, time.Hour)
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+
+	removed, err = CollectUploads(ctx, store, "logos", map[string]bool{key: true}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{other}, removed)
+	_, err = store.Get(ctx, key)
+	assert.NoError(t, err)
+	_, err = store.Get(ctx, other)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Templates saved before storage backends kept paths under ./uploads
+	assert.Equal(t, key, AssetKey("./uploads/"+key))
+	assert.Equal(t, key, AssetKey(key))
+}
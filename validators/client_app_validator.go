@@ -23,12 +23,18 @@ func ValidateClientAppCreate(req models.ClientAppCreateRequest) error {
 		req.Status = models.ClientAppStatusActive
 	}
 
+	if req.Slug != "" {
+		if err := ValidateSlug(req.Slug); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func ValidateClientAppUpdate(req models.ClientAppUpdateRequest) error {
-	if strings.TrimSpace(req.Name) == "" && req.ContactEmail == "" && req.Status == "" {
-		return errors.New("at least one field (name, contact_email, status) is required")
+	if strings.TrimSpace(req.Name) == "" && req.ContactEmail == "" && req.Status == "" && req.Slug == "" {
+		return errors.New("at least one field (name, contact_email, status, slug) is required")
 	}
 
 	if req.ContactEmail != "" {
@@ -41,6 +47,11 @@ func ValidateClientAppUpdate(req models.ClientAppUpdateRequest) error {
 			return errors.New("invalid status value")
 		}
 	}
+	if req.Slug != "" {
+		if err := ValidateSlug(req.Slug); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -3,11 +3,18 @@ package validators
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/mca93/qrcode_service/models"
 )
 
+var pinRegex = regexp.MustCompile(`^[0-9]{4,12}$`)
+
+// maxPasswordBytes is the longest password bcrypt accepts.
+const maxPasswordBytes = 72
+
 // ValidateQRCodeCreate validates the QRCodeCreateRequest.
 func ValidateQRCodeCreate(req models.QRCodeCreateRequest, clientAppID string) error {
 	// Validate Type
@@ -38,6 +45,11 @@ func ValidateQRCodeCreate(req models.QRCodeCreateRequest, clientAppID string) er
 		return errors.New("expiresAt cannot be in the past")
 	}
 
+	// Validate the activation window and scan limit
+	if err := ValidateQRCodeValidity(req.ActivatesAt, req.ExpiresAt, req.MaxScans); err != nil {
+		return err
+	}
+
 	// Validate Data (if provided)
 	if req.Data != nil {
 		if err := validateCustomData(req.Data); err != nil {
@@ -45,6 +57,23 @@ func ValidateQRCodeCreate(req models.QRCodeCreateRequest, clientAppID string) er
 		}
 	}
 
+	// Validate the typed content payload (if any)
+	if err := ValidateQRContent(req.ContentType, req.Content); err != nil {
+		return err
+	}
+
+	// Validate the routing rules (if any)
+	if err := ValidateRouting(req.Routing, req.Type); err != nil {
+		return err
+	}
+
+	// Validate the custom slug (if any)
+	if req.Slug != "" {
+		if err := ValidateSlug(req.Slug); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -60,6 +89,16 @@ func ValidateQRCodeUpdate(req models.QRCodeUpdateRequest) error {
 		return errors.New("expiresAt cannot be in the past")
 	}
 
+	// Validate MaxScans (if provided), the window is checked against the stored code
+	if req.MaxScans != nil && *req.MaxScans < 0 {
+		return errors.New("maxScans cannot be negative")
+	}
+
+	// Validate Status (if provided)
+	if req.Status == models.QRCodeStatusRedeemed {
+		return errors.New("status REDEEMED is only set by redeeming the QR code")
+	}
+
 	// Validate Data (if provided)
 	if req.Data != nil {
 		if err := validateCustomData(req.Data); err != nil {
@@ -67,6 +106,22 @@ func ValidateQRCodeUpdate(req models.QRCodeUpdateRequest) error {
 		}
 	}
 
+	// Validate the typed content payload (if provided)
+	if err := ValidateQRContent(req.ContentType, req.Content); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ValidateQRCodeValidity validates when a QR code resolves and how many times.
+func ValidateQRCodeValidity(activatesAt, expiresAt *time.Time, maxScans int64) error {
+	if maxScans < 0 {
+		return errors.New("maxScans cannot be negative")
+	}
+	if activatesAt != nil && expiresAt != nil && !activatesAt.Before(*expiresAt) {
+		return errors.New("activatesAt must be before expiresAt")
+	}
 	return nil
 }
 
@@ -100,16 +155,24 @@ func validateCustomData(data map[string]interface{}) error {
 func ValidateQRCodeData(data models.JSONMap, template models.Template) error {
 	// Iterate through the Definition to validate fields
 	for _, field := range template.Definition {
-		// Check if the field is marked as required
-		if required, ok := field.Validations["required"].(bool); ok && required {
-			// Ensure the required field exists in the Data map
-			if _, exists := data[field.Name]; !exists {
-				return fmt.Errorf("missing required field in data: %s", field.Name)
+		value, exists := data[field.Name]
+
+		// Reject values for fields whose visibleIf condition does not hold
+		if !field.IsVisible(data) {
+			if exists {
+				return fmt.Errorf("field is not applicable for the provided data: %s", field.Name)
 			}
+			continue
+		}
+
+		// Check if the field is required, either always or through requiredIf. Media is
+		// uploaded once the QR code exists, so it cannot be required yet.
+		if field.IsRequired(data) && !exists && field.Type != models.FieldTypeMedia {
+			return fmt.Errorf("missing required field in data: %s", field.Name)
 		}
 
 		// Additional validation for specific field types
-		if err := validateFieldData(field, data[field.Name]); err != nil {
+		if err := validateFieldData(field, value); err != nil {
 			return fmt.Errorf("validation failed for field '%s': %w", field.Name, err)
 		}
 	}
@@ -155,10 +218,83 @@ func validateFieldData(field models.Field, value interface{}) error {
 			}
 		}
 	case models.FieldTypeMedia:
-		// Add specific validations for media fields if needed
+		ref, err := models.ParseMediaReference(value)
+		if err != nil {
+			return err
+		}
+		return ValidateMediaUpload(field, ref.ContentType, ref.Size)
 	default:
 		return fmt.Errorf("unsupported field type: %s", field.Type)
 	}
 
 	return nil
 }
+
+// ValidateMediaUpload checks media of contentType and size bytes against the allowedTypes and
+// maxSize validations of a Media field. Allowed types are media types such as "application/pdf",
+// or wildcards such as "image/*".
+func ValidateMediaUpload(field models.Field, contentType string, size int64) error {
+	if types, ok := field.Validations["allowedTypes"].([]interface{}); ok {
+		allowed := false
+		for _, t := range types {
+			pattern, _ := t.(string)
+			if pattern == contentType || (strings.HasSuffix(pattern, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(pattern, "*"))) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("media type %s is not allowed", contentType)
+		}
+	}
+	if maxSize, ok := field.Validations["maxSize"].(float64); ok && float64(size) > maxSize {
+		return fmt.Errorf("media exceeds maxSize of %d bytes", int64(maxSize))
+	}
+	return nil
+}
+
+// ValidateMediaOwnership ensures the Media fields of a QR code's data refer to media uploaded
+// for that QR code.
+func ValidateMediaOwnership(data models.JSONMap, template models.Template, qrCodeID string) error {
+	for _, field := range template.Definition {
+		value, exists := data[field.Name]
+		if field.Type != models.FieldTypeMedia || !exists || value == nil {
+			continue
+		}
+		ref, err := models.ParseMediaReference(value)
+		if err != nil {
+			return fmt.Errorf("validation failed for field '%s': %w", field.Name, err)
+		}
+		if !ref.BelongsTo(qrCodeID) {
+			return fmt.Errorf("field '%s' must refer to media uploaded for this QR code", field.Name)
+		}
+	}
+	return nil
+}
+
+// ValidateQRCodeProtection validates the PIN or password of a QR code of type qrType. An
+// empty protection removes it.
+func ValidateQRCodeProtection(protection *models.QRCodeProtection, qrType models.QRCodeType) error {
+	if protection == nil || (protection.Type == "" && protection.Secret == "") {
+		return nil // protection is optional
+	}
+	if !protection.Type.IsValid() {
+		return fmt.Errorf("invalid protection type: %s. Valid options are PIN, PASSWORD", protection.Type)
+	}
+	if qrType != models.QRCodeTypeDynamic {
+		return errors.New("protection requires a DYNAMIC QR code")
+	}
+	if protection.Type == models.ProtectionTypePIN && !pinRegex.MatchString(protection.Secret) {
+		return errors.New("protection PIN must be 4 to 12 digits")
+	}
+	// bcrypt hashes at most 72 bytes
+	if protection.Type == models.ProtectionTypePassword {
+		if len([]rune(protection.Secret)) < 8 {
+			return errors.New("protection password must be at least 8 characters")
+		}
+		if len(protection.Secret) > maxPasswordBytes {
+			return fmt.Errorf("protection password must be at most %d bytes", maxPasswordBytes)
+		}
+	}
+	return nil
+}
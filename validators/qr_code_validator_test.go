@@ -0,0 +1,155 @@
+package validators
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func ticketTemplate() models.Template {
+	return models.Template{
+		Definition: models.Definition{
+			{Name: "ticketType", Type: models.FieldTypeText, Default: "free"},
+			{
+				Name:       "refundPolicy",
+				Type:       models.FieldTypeText,
+				RequiredIf: &models.FieldCondition{Field: "ticketType", Value: "paid"},
+			},
+			{
+				Name:      "price",
+				Type:      models.FieldTypeNumber,
+				VisibleIf: &models.FieldCondition{Field: "ticketType", Operator: models.ConditionOperatorEquals, Value: "paid"},
+			},
+		},
+	}
+}
+
+func TestValidateQRCodeDataRequiredIf(t *testing.T) {
+	template := ticketTemplate()
+
+	err := ValidateQRCodeData(models.JSONMap{"ticketType": "paid"}, template)
+	assert.EqualError(t, err, "missing required field in data: refundPolicy")
+
+	err = ValidateQRCodeData(models.JSONMap{"ticketType": "paid", "refundPolicy": "14 days", "price": 10.0}, template)
+	assert.NoError(t, err)
+
+	err = ValidateQRCodeData(models.JSONMap{"ticketType": "free"}, template)
+	assert.NoError(t, err)
+}
+
+func TestValidateQRCodeDataVisibleIf(t *testing.T) {
+	template := ticketTemplate()
+
+	err := ValidateQRCodeData(models.JSONMap{"ticketType": "free", "price": 10.0}, template)
+	assert.EqualError(t, err, "field is not applicable for the provided data: price")
+}
+
+func TestApplyDefaults(t *testing.T) {
+	template := ticketTemplate()
+
+	data := template.Definition.ApplyDefaults(map[string]interface{}{})
+	assert.Equal(t, models.JSONMap{"ticketType": "free"}, data)
+
+	data = template.Definition.ApplyDefaults(map[string]interface{}{"ticketType": "paid"})
+	assert.Equal(t, "paid", data["ticketType"])
+}
+
+func TestValidateDefinitionConditions(t *testing.T) {
+	definition := models.Definition{
+		{Name: "refundPolicy", Type: models.FieldTypeText, RequiredIf: &models.FieldCondition{Field: "ticketType", Value: "paid"}},
+	}
+	assert.EqualError(t, validateDefinition(definition), "field refundPolicy references unknown field in condition: ticketType")
+
+	definition = models.Definition{
+		{Name: "name", Type: models.FieldTypeText, Default: "guest", Validations: map[string]interface{}{"maxLength": 3.0}},
+	}
+	assert.Error(t, validateDefinition(definition))
+}
+
+func TestValidateSignedPayloadSensitiveFields(t *testing.T) {
+	definition := models.Definition{
+		{Name: "seat", Type: models.FieldTypeText},
+		{Name: "phone", Type: models.FieldTypeText, Sensitive: true},
+	}
+	assert.NoError(t, validateSignedPayload(&models.SignedPayload{Fields: []string{"seat"}}, nil, definition))
+	assert.EqualError(t, validateSignedPayload(&models.SignedPayload{Fields: []string{"seat", "phone"}}, nil, definition),
+		"signedPayload fields cannot include sensitive field: phone")
+}
+
+func TestValidateDisplayedSensitiveFields(t *testing.T) {
+	definition := models.Definition{
+		{Name: "seat", Type: models.FieldTypeText},
+		{Name: "phone", Type: models.FieldTypeText, Sensitive: true},
+	}
+	assert.NoError(t, ValidateContentMapping(&models.ContentMapping{Format: models.ContentFormatKeyValue, Fields: []string{"seat"}}, definition))
+	assert.EqualError(t, ValidateContentMapping(&models.ContentMapping{Format: models.ContentFormatJSON, Fields: []string{"phone"}}, definition),
+		"content mapping cannot reference sensitive field: phone")
+	assert.EqualError(t, ValidateContentMapping(&models.ContentMapping{Format: models.ContentFormatURL, Template: "https://x.com/{phone}"}, definition),
+		"content template cannot reference sensitive field: phone")
+	assert.EqualError(t, validateFrame(&models.Frame{Style: models.FrameStyleBorder, Caption: "Call {phone}"}, definition),
+		"frame caption cannot reference sensitive field: phone")
+}
+
+func TestValidateQRCodeDataMedia(t *testing.T) {
+	template := models.Template{
+		Definition: models.Definition{
+			{
+				Name:        "photo",
+				Type:        models.FieldTypeMedia,
+				Validations: map[string]interface{}{"required": true, "allowedTypes": []interface{}{"image/*"}, "maxSize": 1000.0},
+			},
+		},
+	}
+	photo := map[string]interface{}{"asset": "media/qr-1/photo/a.png", "contentType": "image/png", "size": 500.0}
+
+	// Media is uploaded after the QR code is created
+	assert.NoError(t, ValidateQRCodeData(models.JSONMap{}, template))
+	assert.NoError(t, ValidateQRCodeData(models.JSONMap{"photo": photo}, template))
+	assert.Error(t, ValidateQRCodeData(models.JSONMap{"photo": "https://example.com/a.png"}, template))
+
+	assert.EqualError(t, ValidateMediaUpload(template.Definition[0], "application/pdf", 500), "media type application/pdf is not allowed")
+	assert.EqualError(t, ValidateMediaUpload(template.Definition[0], "image/jpeg", 2000), "media exceeds maxSize of 1000 bytes")
+
+	assert.NoError(t, ValidateMediaOwnership(models.JSONMap{"photo": photo}, template, "qr-1"))
+	assert.Error(t, ValidateMediaOwnership(models.JSONMap{"photo": photo}, template, "qr-2"))
+}
+
+func TestQRCodeValidity(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+	assert.NoError(t, ValidateQRCodeValidity(&now, &later, 10))
+	assert.Error(t, ValidateQRCodeValidity(&later, &now, 0))
+	assert.Error(t, ValidateQRCodeValidity(nil, nil, -1))
+
+	voucher := models.QRCode{Status: models.QRCodeStatusActive, ActivatesAt: &later, MaxScans: 1, OneTimeUse: true}
+	assert.ErrorIs(t, voucher.CheckResolvable(now), models.ErrQRCodeNotYetActive)
+	assert.NoError(t, voucher.CheckResolvable(later))
+
+	voucher.ExpiresAt = &later
+	assert.ErrorIs(t, voucher.CheckResolvable(later), models.ErrQRCodeExpired)
+
+	voucher.ActivatesAt, voucher.ExpiresAt, voucher.ScanCount = nil, nil, 1
+	assert.ErrorIs(t, voucher.CheckResolvable(now), models.ErrQRCodeExhausted)
+	voucher.Status = models.QRCodeStatusRedeemed
+	assert.ErrorIs(t, voucher.CheckResolvable(now), models.ErrQRCodeRedeemed)
+}
+
+func TestValidateQRCodeProtection(t *testing.T) {
+	pin := &models.QRCodeProtection{Type: models.ProtectionTypePIN, Secret: "4821"}
+	assert.NoError(t, ValidateQRCodeProtection(pin, models.QRCodeTypeDynamic))
+	assert.Error(t, ValidateQRCodeProtection(pin, models.QRCodeTypeStable))
+	assert.Error(t, ValidateQRCodeProtection(&models.QRCodeProtection{Type: models.ProtectionTypePIN, Secret: "12a4"}, models.QRCodeTypeDynamic))
+	assert.Error(t, ValidateQRCodeProtection(&models.QRCodeProtection{Type: models.ProtectionTypePassword, Secret: "short"}, models.QRCodeTypeDynamic))
+	// bcrypt limits passwords to 72 bytes, not characters
+	password := &models.QRCodeProtection{Type: models.ProtectionTypePassword, Secret: strings.Repeat("a", 72)}
+	assert.NoError(t, ValidateQRCodeProtection(password, models.QRCodeTypeDynamic))
+	password.Secret = strings.Repeat("a", 73)
+	assert.Error(t, ValidateQRCodeProtection(password, models.QRCodeTypeDynamic))
+	password.Secret = strings.Repeat("é", 37) // 37 characters, 74 bytes
+	assert.Error(t, ValidateQRCodeProtection(password, models.QRCodeTypeDynamic))
+	// {} removes the protection
+	assert.NoError(t, ValidateQRCodeProtection(&models.QRCodeProtection{}, models.QRCodeTypeStable))
+}
@@ -0,0 +1,432 @@
+package validators
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/utils"
+)
+
+const (
+	maxWiFiSSIDLength = 32
+	maxSMSLength      = 1000
+
+	maxSEPAPayloadLength     = 331 // bytes, EPC069-12
+	maxSEPANameLength        = 70
+	maxSEPARemittanceLength  = 140
+	maxSEPAInformationLength = 70
+	maxSEPAAmount            = 999999999.99
+
+	maxEMVCoPayloadLength      = 512
+	maxEMVCoValueLength        = 99
+	maxEMVCoMerchantNameLength = 25
+	maxEMVCoMerchantCityLength = 15
+	maxEMVCoPostalCodeLength   = 10
+	maxEMVCoAmountLength       = 13
+)
+
+var (
+	phoneRegex  = regexp.MustCompile(`^\+?[0-9]{3,20}$`)
+	wepKeyRegex = regexp.MustCompile(`^([0-9A-Fa-f]{10}|[0-9A-Fa-f]{26})$`)
+
+	bicRegex         = regexp.MustCompile(`^[A-Z]{4}[A-Z]{2}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+	purposeCodeRegex = regexp.MustCompile(`^[A-Z]{4}$`)
+	emvIDRegex       = regexp.MustCompile(`^[0-9]{2}$`)
+	mccRegex         = regexp.MustCompile(`^[0-9]{4}$`)
+	currencyRegex    = regexp.MustCompile(`^[0-9]{3}$`)
+	countryCodeRegex = regexp.MustCompile(`^[A-Z]{2}$`)
+	emvTextRegex     = regexp.MustCompile(`^[\x20-\x7E]*$`)
+)
+
+// ValidateQRContent validates the typed payload for a QR code content type.
+func ValidateQRContent(contentType models.QRContentType, content *models.QRContent) error {
+	if contentType == "" || contentType == models.QRContentTypeURL {
+		return nil // the payload comes from the template content mapping or the deep link
+	}
+	if !contentType.IsValid() {
+		return fmt.Errorf("invalid contentType: %s", contentType)
+	}
+	if content == nil {
+		return fmt.Errorf("content is required for contentType %s", contentType)
+	}
+
+	switch contentType {
+	case models.QRContentTypeVCard, models.QRContentTypeMeCard:
+		return validateContactContent(content.Contact, contentType)
+	case models.QRContentTypeWiFi:
+		return validateWiFiContent(content.WiFi)
+	case models.QRContentTypeEmail:
+		return validateEmailContent(content.Email)
+	case models.QRContentTypeSMS:
+		return validateSMSContent(content.SMS)
+	case models.QRContentTypeTel:
+		if content.Tel == nil {
+			return errors.New("content.tel is required for contentType TEL")
+		}
+		return validatePhoneNumber(content.Tel.Number, "tel.number")
+	case models.QRContentTypeGeo:
+		return validateGeoContent(content.Geo)
+	case models.QRContentTypeEvent:
+		return validateEventContent(content.Event)
+	case models.QRContentTypeEPC:
+		return validateSEPAPayment(content.SEPA)
+	case models.QRContentTypeEMVCo:
+		return validateEMVCoPayment(content.EMVCo)
+	case models.QRContentTypeGS1:
+		return validateGS1DigitalLink(content.GS1)
+	}
+	return nil
+}
+
+func validatePhoneNumber(number, fieldName string) error {
+	if strings.TrimSpace(number) == "" {
+		return fmt.Errorf("%s is required", fieldName)
+	}
+	if !phoneRegex.MatchString(utils.NormalizePhoneNumber(number)) {
+		return fmt.Errorf("invalid %s: must contain 3 to 20 digits with an optional leading +", fieldName)
+	}
+	return nil
+}
+
+func validateContactContent(contact *models.ContactContent, contentType models.QRContentType) error {
+	if contact == nil {
+		return fmt.Errorf("content.contact is required for contentType %s", contentType)
+	}
+	if strings.TrimSpace(contact.FirstName) == "" && strings.TrimSpace(contact.LastName) == "" {
+		return errors.New("contact.firstName or contact.lastName is required")
+	}
+	if contact.Version != "" && contact.Version != "3.0" && contact.Version != "4.0" {
+		return errors.New("invalid contact.version: must be 3.0 or 4.0")
+	}
+	for _, phone := range contact.Phones {
+		if err := validatePhoneNumber(phone.Number, "contact.phones.number"); err != nil {
+			return err
+		}
+		if phone.Type != "" && !utils.ValidPhoneType(phone.Type) {
+			return fmt.Errorf("invalid contact.phones.type: %q. Valid options are CELL, FAX, HOME, PAGER, TEXT, TEXTPHONE, VIDEO, VOICE, WORK", phone.Type)
+		}
+	}
+	for _, email := range contact.Emails {
+		if !emailRegex.MatchString(email) {
+			return fmt.Errorf("invalid contact email: %s", email)
+		}
+	}
+	if contact.URL != "" {
+		if parsed, err := url.ParseRequestURI(contact.URL); err != nil || parsed.Scheme == "" {
+			return errors.New("invalid contact.url: must be an absolute URL")
+		}
+	}
+	if contact.Birthday != "" {
+		if _, err := time.Parse("2006-01-02", contact.Birthday); err != nil {
+			return errors.New("invalid contact.birthday: must be YYYY-MM-DD")
+		}
+	}
+	return nil
+}
+
+func validateWiFiContent(wifi *models.WiFiContent) error {
+	if wifi == nil {
+		return errors.New("content.wifi is required for contentType WIFI")
+	}
+	if wifi.SSID == "" {
+		return errors.New("wifi.ssid is required")
+	}
+	if len(wifi.SSID) > maxWiFiSSIDLength {
+		return fmt.Errorf("wifi.ssid must be at most %d bytes", maxWiFiSSIDLength)
+	}
+
+	switch wifi.Security {
+	case "", models.WiFiSecurityWPA:
+		if len(wifi.Password) < 8 || len(wifi.Password) > 63 {
+			return errors.New("wifi.password must be between 8 and 63 characters for WPA")
+		}
+	case models.WiFiSecurityWEP:
+		if len(wifi.Password) != 5 && len(wifi.Password) != 13 && !wepKeyRegex.MatchString(wifi.Password) {
+			return errors.New("wifi.password must be a 5 or 13 character key, or 10 or 26 hex digits, for WEP")
+		}
+	case models.WiFiSecurityNoPass:
+		if wifi.Password != "" {
+			return errors.New("wifi.password must be empty when security is nopass")
+		}
+	default:
+		return errors.New("invalid wifi.security: must be WPA, WEP or nopass")
+	}
+	return nil
+}
+
+func validateEmailContent(email *models.EmailContent) error {
+	if email == nil {
+		return errors.New("content.email is required for contentType EMAIL")
+	}
+	if !emailRegex.MatchString(email.To) {
+		return errors.New("invalid email.to format")
+	}
+	return nil
+}
+
+func validateSMSContent(sms *models.SMSContent) error {
+	if sms == nil {
+		return errors.New("content.sms is required for contentType SMS")
+	}
+	if err := validatePhoneNumber(sms.Number, "sms.number"); err != nil {
+		return err
+	}
+	if len(sms.Message) > maxSMSLength {
+		return fmt.Errorf("sms.message must be at most %d characters", maxSMSLength)
+	}
+	return nil
+}
+
+func validateGeoContent(geo *models.GeoContent) error {
+	if geo == nil {
+		return errors.New("content.geo is required for contentType GEO")
+	}
+	if geo.Latitude < -90 || geo.Latitude > 90 {
+		return errors.New("geo.latitude must be between -90 and 90")
+	}
+	if geo.Longitude < -180 || geo.Longitude > 180 {
+		return errors.New("geo.longitude must be between -180 and 180")
+	}
+	return nil
+}
+
+func validateEventContent(event *models.EventContent) error {
+	if event == nil {
+		return errors.New("content.event is required for contentType EVENT")
+	}
+	if strings.TrimSpace(event.Summary) == "" {
+		return errors.New("event.summary is required")
+	}
+	if event.Start.IsZero() {
+		return errors.New("event.start is required")
+	}
+	if event.End != nil && event.End.Before(event.Start) {
+		return errors.New("event.end cannot be before event.start")
+	}
+	return nil
+}
+
+// validAmount reports whether amount is positive, at most max and has no more than two decimals.
+func validAmount(amount, max float64) bool {
+	return amount >= 0.01 && amount <= max && math.Abs(amount*100-math.Round(amount*100)) < 1e-6
+}
+
+func validateSEPAPayment(payment *models.SEPAPayment) error {
+	if payment == nil {
+		return errors.New("content.sepa is required for contentType EPC")
+	}
+	if strings.TrimSpace(payment.Name) == "" {
+		return errors.New("sepa.name is required")
+	}
+	// EPC fields are separated by newlines, so a field holding one would shift those after it
+	for name, value := range map[string]string{
+		"name": payment.Name, "iban": payment.IBAN, "bic": payment.BIC, "purpose": payment.Purpose,
+		"reference": payment.Reference, "remittanceText": payment.RemittanceText, "information": payment.Information,
+	} {
+		if hasControlCharacter(value) {
+			return fmt.Errorf("sepa.%s must not contain control characters such as line breaks", name)
+		}
+	}
+	if utf8.RuneCountInString(payment.Name) > maxSEPANameLength {
+		return fmt.Errorf("sepa.name must be at most %d characters", maxSEPANameLength)
+	}
+	if !utils.ValidIBAN(payment.IBAN) {
+		return errors.New("invalid sepa.iban: wrong length or checksum")
+	}
+	if payment.BIC != "" {
+		bic := strings.ToUpper(payment.BIC)
+		if !bicRegex.MatchString(bic) {
+			return errors.New("invalid sepa.bic: must be 8 or 11 characters (bank, country, location and optional branch)")
+		}
+		// The BIC country code must match the IBAN country code
+		if iban := utils.NormalizeIBAN(payment.IBAN); bic[4:6] != iban[:2] {
+			return errors.New("invalid sepa.bic: country code does not match the IBAN")
+		}
+	}
+	if payment.Amount != nil && !validAmount(*payment.Amount, maxSEPAAmount) {
+		return fmt.Errorf("sepa.amount must be between 0.01 and %.2f with at most two decimals", maxSEPAAmount)
+	}
+	if payment.Purpose != "" && !purposeCodeRegex.MatchString(strings.ToUpper(payment.Purpose)) {
+		return errors.New("invalid sepa.purpose: must be a 4-letter ISO 20022 purpose code")
+	}
+	if payment.Reference != "" && payment.RemittanceText != "" {
+		return errors.New("sepa.reference and sepa.remittanceText are mutually exclusive")
+	}
+	if payment.Reference != "" && !utils.ValidCreditorReference(payment.Reference) {
+		return errors.New("invalid sepa.reference: must be an ISO 11649 creditor reference (RF...)")
+	}
+	if utf8.RuneCountInString(payment.RemittanceText) > maxSEPARemittanceLength {
+		return fmt.Errorf("sepa.remittanceText must be at most %d characters", maxSEPARemittanceLength)
+	}
+	if utf8.RuneCountInString(payment.Information) > maxSEPAInformationLength {
+		return fmt.Errorf("sepa.information must be at most %d characters", maxSEPAInformationLength)
+	}
+	if utils.SEPAPayloadLength(payment) > maxSEPAPayloadLength {
+		return fmt.Errorf("sepa payload exceeds %d bytes", maxSEPAPayloadLength)
+	}
+	return nil
+}
+
+// hasControlCharacter reports whether s contains a control character, such as a line break.
+func hasControlCharacter(s string) bool {
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateEMVCoValue validates the value of a single EMVCo data object.
+func validateEMVCoValue(name, value string, maxLength int) error {
+	if len(value) > maxLength {
+		return fmt.Errorf("emvco.%s must be at most %d characters", name, maxLength)
+	}
+	if !emvTextRegex.MatchString(value) {
+		return fmt.Errorf("emvco.%s must contain printable ASCII characters only", name)
+	}
+	return nil
+}
+
+func validateEMVCoPayment(payment *models.EMVCoPayment) error {
+	if payment == nil {
+		return errors.New("content.emvco is required for contentType EMVCO")
+	}
+	switch payment.PointOfInitiation {
+	case "", models.EMVCoInitiationStatic, models.EMVCoInitiationDynamic:
+	default:
+		return errors.New("invalid emvco.pointOfInitiation: must be STATIC or DYNAMIC")
+	}
+
+	if len(payment.MerchantAccounts) == 0 {
+		return errors.New("emvco.merchantAccounts must contain at least one account")
+	}
+	seen := make(map[string]bool)
+	for _, account := range payment.MerchantAccounts {
+		if err := validateEMVCoMerchantAccount(account); err != nil {
+			return err
+		}
+		if seen[account.ID] {
+			return fmt.Errorf("duplicate emvco merchant account id: %s", account.ID)
+		}
+		seen[account.ID] = true
+	}
+
+	if !mccRegex.MatchString(payment.MerchantCategoryCode) {
+		return errors.New("invalid emvco.merchantCategoryCode: must be 4 digits")
+	}
+	if !currencyRegex.MatchString(payment.Currency) {
+		return errors.New("invalid emvco.currency: must be a 3-digit ISO 4217 numeric code")
+	}
+	if payment.Amount != nil {
+		if *payment.Amount <= 0 || len(strconv.FormatFloat(*payment.Amount, 'f', -1, 64)) > maxEMVCoAmountLength {
+			return fmt.Errorf("emvco.amount must be positive and at most %d characters long", maxEMVCoAmountLength)
+		}
+	}
+	if !countryCodeRegex.MatchString(strings.ToUpper(payment.CountryCode)) {
+		return errors.New("invalid emvco.countryCode: must be an ISO 3166-1 alpha-2 code")
+	}
+	if strings.TrimSpace(payment.MerchantName) == "" {
+		return errors.New("emvco.merchantName is required")
+	}
+	if err := validateEMVCoValue("merchantName", payment.MerchantName, maxEMVCoMerchantNameLength); err != nil {
+		return err
+	}
+	if strings.TrimSpace(payment.MerchantCity) == "" {
+		return errors.New("emvco.merchantCity is required")
+	}
+	if err := validateEMVCoValue("merchantCity", payment.MerchantCity, maxEMVCoMerchantCityLength); err != nil {
+		return err
+	}
+	if err := validateEMVCoValue("postalCode", payment.PostalCode, maxEMVCoPostalCodeLength); err != nil {
+		return err
+	}
+	if d := payment.AdditionalData; d != nil {
+		for name, value := range map[string]string{
+			"additionalData.billNumber": d.BillNumber, "additionalData.mobileNumber": d.MobileNumber,
+			"additionalData.storeLabel": d.StoreLabel, "additionalData.loyaltyNumber": d.LoyaltyNumber,
+			"additionalData.referenceLabel": d.ReferenceLabel, "additionalData.customerLabel": d.CustomerLabel,
+			"additionalData.terminalLabel": d.TerminalLabel, "additionalData.purpose": d.Purpose,
+		} {
+			if err := validateEMVCoValue(name, value, 25); err != nil {
+				return err
+			}
+		}
+	}
+	if utils.EMVCoPayloadLength(payment) > maxEMVCoPayloadLength {
+		return fmt.Errorf("emvco payload exceeds %d characters", maxEMVCoPayloadLength)
+	}
+	return nil
+}
+
+func validateEMVCoMerchantAccount(account models.EMVCoMerchantAccount) error {
+	if !emvIDRegex.MatchString(account.ID) || account.ID < "02" || account.ID > "51" {
+		return fmt.Errorf("invalid emvco merchant account id %q: must be between 02 and 51", account.ID)
+	}
+
+	// IDs 02-25 are primitive values reserved for the card schemes
+	if account.ID <= "25" {
+		if account.Value == "" || account.GUID != "" || len(account.Fields) > 0 {
+			return fmt.Errorf("emvco merchant account %s requires a value and no guid or fields", account.ID)
+		}
+		return validateEMVCoValue("merchantAccounts.value", account.Value, maxEMVCoValueLength)
+	}
+
+	// IDs 26-51 are templates identified by a globally unique identifier
+	if account.Value != "" || account.GUID == "" {
+		return fmt.Errorf("emvco merchant account %s requires a guid and fields instead of a value", account.ID)
+	}
+	if err := validateEMVCoValue("merchantAccounts.guid", account.GUID, 32); err != nil {
+		return err
+	}
+	length := 4 + len(account.GUID)
+	for _, field := range account.Fields {
+		if !emvIDRegex.MatchString(field.ID) || field.ID == "00" {
+			return fmt.Errorf("invalid emvco merchant account field id %q: must be between 01 and 99", field.ID)
+		}
+		if err := validateEMVCoValue("merchantAccounts.fields.value", field.Value, maxEMVCoValueLength); err != nil {
+			return err
+		}
+		length += 4 + len(field.Value)
+	}
+	if length > maxEMVCoValueLength {
+		return fmt.Errorf("emvco merchant account %s exceeds %d characters", account.ID, maxEMVCoValueLength)
+	}
+	return nil
+}
+
+func validateGS1DigitalLink(link *models.GS1DigitalLink) error {
+	if link == nil {
+		return errors.New("content.gs1 is required for contentType GS1")
+	}
+	if link.Domain != "" {
+		u, err := url.Parse(link.Domain)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			return errors.New("invalid gs1.domain: must be an absolute http(s) URL without query or fragment")
+		}
+	}
+	if !utils.ValidGTIN(link.GTIN) {
+		return errors.New("invalid gs1.gtin: must be 8, 12, 13 or 14 digits with a valid check digit")
+	}
+	if link.Batch != "" && !utils.ValidGS1Alphanumeric(link.Batch) {
+		return errors.New("invalid gs1.batch: must be 1 to 20 characters of the GS1 AI character set")
+	}
+	if link.Serial != "" && !utils.ValidGS1Alphanumeric(link.Serial) {
+		return errors.New("invalid gs1.serial: must be 1 to 20 characters of the GS1 AI character set")
+	}
+	if link.Expiry != "" {
+		if _, err := time.Parse("2006-01-02", link.Expiry); err != nil {
+			return errors.New("invalid gs1.expiry: must be in YYYY-MM-DD format")
+		}
+	}
+	return nil
+}
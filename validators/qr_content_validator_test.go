@@ -0,0 +1,36 @@
+package validators
+
+import (
+	"testing"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSEPAPaymentLineBreaks(t *testing.T) {
+	payment := func() *models.SEPAPayment {
+		return &models.SEPAPayment{Name: "Red Cross Belgium", IBAN: "BE72000000001616", BIC: "BPOTBEB1"}
+	}
+	assert.NoError(t, validateSEPAPayment(payment()))
+
+	// A line break in the name would move the IBAN to the amount's line
+	p := payment()
+	p.Name = "Red Cross\nBE72000000001616"
+	assert.EqualError(t, validateSEPAPayment(p), "sepa.name must not contain control characters such as line breaks")
+	p = payment()
+	p.RemittanceText = "Donation\r\nEUR1000.00"
+	assert.Error(t, validateSEPAPayment(p))
+	p = payment()
+	p.Information = "Thanks\n"
+	assert.Error(t, validateSEPAPayment(p))
+}
+
+func TestValidateContactPhoneType(t *testing.T) {
+	contact := &models.ContactContent{FirstName: "Ana", Phones: []models.ContactPhone{{Type: "cell", Number: "+258841234567"}}}
+	assert.NoError(t, validateContactContent(contact, models.QRContentTypeVCard))
+
+	contact.Phones[0].Type = "WORK\r\nNOTE:injected"
+	assert.Error(t, validateContactContent(contact, models.QRContentTypeVCard))
+	contact.Phones[0].Type = "mobile"
+	assert.Error(t, validateContactContent(contact, models.QRContentTypeVCard))
+}
@@ -0,0 +1,41 @@
+package validators
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mca93/qrcode_service/models"
+)
+
+// maxClockSkew is how far off the service's clock offline redemption times may be.
+const maxClockSkew = 5 * time.Minute
+
+// ValidateRedemptionRequest validates a code scanned by a staff device.
+func ValidateRedemptionRequest(req models.RedemptionRequest) error {
+	if (req.Latitude == nil) != (req.Longitude == nil) {
+		return errors.New("latitude and longitude must be given together")
+	}
+	if req.Latitude != nil && (*req.Latitude < -90 || *req.Latitude > 90) {
+		return errors.New("latitude must be between -90 and 90")
+	}
+	if req.Longitude != nil && (*req.Longitude < -180 || *req.Longitude > 180) {
+		return errors.New("longitude must be between -180 and 180")
+	}
+	return nil
+}
+
+// ValidateOfflineRedemption validates a redemption uploaded by a staff device that was offline.
+// It must have been made since the device was enrolled, and at most maxOfflineAge ago.
+func ValidateOfflineRedemption(req models.OfflineRedemption, device *models.StaffDevice, maxOfflineAge time.Duration, now time.Time) error {
+	if req.RedeemedAt.After(now.Add(maxClockSkew)) {
+		return errors.New("redeemedAt cannot be in the future")
+	}
+	if req.RedeemedAt.Before(device.CreatedAt.Add(-maxClockSkew)) {
+		return errors.New("redeemedAt cannot be before the device was enrolled")
+	}
+	if req.RedeemedAt.Before(now.Add(-maxOfflineAge)) {
+		return fmt.Errorf("redeemedAt cannot be more than %s ago", maxOfflineAge)
+	}
+	return ValidateRedemptionRequest(req.RedemptionRequest)
+}
@@ -0,0 +1,35 @@
+package validators
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOfflineRedemption(t *testing.T) {
+	now := time.Now()
+	device := &models.StaffDevice{ID: "door-1", CreatedAt: now.Add(-48 * time.Hour)}
+	maxOfflineAge := 72 * time.Hour
+	latitude, longitude := 38.72, -9.14
+	redemption := models.OfflineRedemption{
+		RedemptionRequest:  models.RedemptionRequest{Payload: "abc", Latitude: &latitude, Longitude: &longitude},
+		ClientRedemptionID: "door-1-0001",
+		RedeemedAt:         now.Add(-time.Hour),
+	}
+	assert.NoError(t, ValidateOfflineRedemption(redemption, device, maxOfflineAge, now))
+
+	redemption.RedeemedAt = now.Add(time.Hour)
+	assert.ErrorContains(t, ValidateOfflineRedemption(redemption, device, maxOfflineAge, now), "future")
+
+	redemption.RedeemedAt = now.Add(-60 * time.Hour)
+	assert.ErrorContains(t, ValidateOfflineRedemption(redemption, device, maxOfflineAge, now), "enrolled")
+
+	device.CreatedAt = now.Add(-30 * 24 * time.Hour)
+	redemption.RedeemedAt = now.Add(-96 * time.Hour)
+	assert.ErrorContains(t, ValidateOfflineRedemption(redemption, device, maxOfflineAge, now), "72h0m0s ago")
+
+	redemption.RedeemedAt, redemption.Longitude = now, nil
+	assert.ErrorContains(t, ValidateOfflineRedemption(redemption, device, maxOfflineAge, now), "together")
+}
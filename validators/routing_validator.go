@@ -0,0 +1,131 @@
+package validators
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/mca93/qrcode_service/models"
+)
+
+const (
+	maxRoutingRules = 50
+	maxVariants     = 10
+)
+
+var (
+	languageTagRegex = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+	variantNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,50}$`)
+)
+
+// ValidateRouting validates the routing rules of a QR code of type qrType.
+func ValidateRouting(routing *models.Routing, qrType models.QRCodeType) error {
+	if routing == nil {
+		return nil // routing is optional
+	}
+	if qrType != models.QRCodeTypeDynamic && (len(routing.Rules) > 0 || len(routing.Variants) > 0 || routing.DefaultURL != "") {
+		return errors.New("routing requires a DYNAMIC QR code")
+	}
+	if len(routing.Rules) > maxRoutingRules {
+		return fmt.Errorf("routing can have at most %d rules", maxRoutingRules)
+	}
+	if routing.DefaultURL != "" {
+		if err := validateDestination(routing.DefaultURL, "routing defaultUrl"); err != nil {
+			return err
+		}
+	}
+	for i := range routing.Rules {
+		if err := validateRoutingRule(&routing.Rules[i]); err != nil {
+			return fmt.Errorf("invalid routing rule %d: %w", i+1, err)
+		}
+	}
+	return validateVariants(routing.Variants)
+}
+
+// validateVariants validates the destinations of an A/B test. Their names end up in cookies
+// and reports, so they are short identifiers.
+func validateVariants(variants []models.Variant) error {
+	if len(variants) == 0 {
+		return nil // variants are optional
+	}
+	if len(variants) < 2 || len(variants) > maxVariants {
+		return fmt.Errorf("routing must have between 2 and %d variants", maxVariants)
+	}
+	names := make(map[string]bool)
+	total := 0
+	for _, variant := range variants {
+		if !variantNameRegex.MatchString(variant.Name) {
+			return fmt.Errorf("invalid variant name: %q, must be 1 to 50 letters, digits, - or _", variant.Name)
+		}
+		if names[variant.Name] {
+			return fmt.Errorf("duplicate variant name: %s", variant.Name)
+		}
+		names[variant.Name] = true
+		if err := validateDestination(variant.URL, "variant "+variant.Name+" url"); err != nil {
+			return err
+		}
+		if variant.Weight < 0 || variant.Weight > 100 {
+			return fmt.Errorf("variant %s weight must be between 0 and 100", variant.Name)
+		}
+		total += variant.Weight
+	}
+	if total != 100 {
+		return fmt.Errorf("variant weights must add up to 100, got %d", total)
+	}
+	return nil
+}
+
+func validateRoutingRule(rule *models.RoutingRule) error {
+	if err := validateDestination(rule.Destination, "destination"); err != nil {
+		return err
+	}
+	for _, os := range rule.OS {
+		if !os.IsValid() {
+			return fmt.Errorf("invalid os: %s. Valid options are ios, android, desktop", os)
+		}
+	}
+	for _, language := range rule.Languages {
+		if !languageTagRegex.MatchString(language) {
+			return fmt.Errorf("invalid language: %s, must be a language tag such as pt or pt-BR", language)
+		}
+	}
+	for _, day := range rule.Weekdays {
+		if !day.IsValid() {
+			return fmt.Errorf("invalid weekday: %s. Valid options are mon, tue, wed, thu, fri, sat, sun", day)
+		}
+	}
+	if rule.TimeWindow != nil {
+		from, to, err := rule.TimeWindow.Minutes()
+		if err != nil {
+			return fmt.Errorf("invalid timeWindow: %w", err)
+		}
+		if from == to {
+			return errors.New("timeWindow must not be empty")
+		}
+	}
+	if err := rule.ValidateTimezone(); err != nil {
+		return err
+	}
+	if rule.MinScanCount < 0 || rule.MaxScanCount < 0 {
+		return errors.New("scan counts must not be negative")
+	}
+	if rule.MaxScanCount > 0 && rule.MinScanCount > rule.MaxScanCount {
+		return errors.New("minScanCount must not be greater than maxScanCount")
+	}
+	for key := range rule.Query {
+		if key == "" {
+			return errors.New("query parameter names must not be empty")
+		}
+	}
+	return nil
+}
+
+// validateDestination checks that scans can be redirected to a URL.
+func validateDestination(destination, fieldName string) error {
+	u, err := url.Parse(destination)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an http or https URL", fieldName)
+	}
+	return nil
+}
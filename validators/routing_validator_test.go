@@ -0,0 +1,92 @@
+package validators
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mca93/qrcode_service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func appStoreRouting() *models.Routing {
+	return &models.Routing{
+		Rules: []models.RoutingRule{
+			{OS: []models.DeviceOS{models.DeviceOSIOS}, Destination: "https://apps.apple.com/app/id123"},
+			{OS: []models.DeviceOS{models.DeviceOSAndroid}, Destination: "https://play.google.com/store/apps/details?id=com.example"},
+			{Languages: []string{"pt"}, Weekdays: []models.Weekday{"sat", "sun"}, TimeWindow: &models.TimeWindow{From: "22:00", To: "02:00"}, Timezone: "Europe/Lisbon", Destination: "https://example.com/pt/night"},
+			{MaxScanCount: 100, Query: map[string]string{"utm_source": "*"}, Destination: "https://example.com/early"},
+		},
+		DefaultURL: "https://example.com",
+	}
+}
+
+func TestValidateRouting(t *testing.T) {
+	assert.NoError(t, ValidateRouting(appStoreRouting(), models.QRCodeTypeDynamic))
+	assert.ErrorContains(t, ValidateRouting(appStoreRouting(), models.QRCodeTypeStable), "DYNAMIC")
+
+	invalid := []models.RoutingRule{
+		{Destination: "javascript:alert(1)"},
+		{OS: []models.DeviceOS{"windows"}, Destination: "https://example.com"},
+		{Weekdays: []models.Weekday{"monday"}, Destination: "https://example.com"},
+		{TimeWindow: &models.TimeWindow{From: "9am", To: "18:00"}, Destination: "https://example.com"},
+		{Timezone: "Mars/Olympus", Destination: "https://example.com"},
+		{MinScanCount: 10, MaxScanCount: 5, Destination: "https://example.com"},
+	}
+	for _, rule := range invalid {
+		routing := &models.Routing{Rules: []models.RoutingRule{rule}}
+		assert.Error(t, ValidateRouting(routing, models.QRCodeTypeDynamic), "%+v", rule)
+	}
+}
+
+func TestRoutingResolve(t *testing.T) {
+	routing := appStoreRouting()
+	// Saturday 23:30 in Lisbon (summer time)
+	night := time.Date(2024, 6, 15, 22, 30, 0, 0, time.UTC)
+
+	route := routing.Resolve(models.ScanContext{OS: models.DeviceOSIOS, Time: night, ScanCount: 500})
+	assert.Equal(t, "https://apps.apple.com/app/id123", route.Destination)
+	assert.NotNil(t, route.Rule)
+
+	route = routing.Resolve(models.ScanContext{OS: models.DeviceOSDesktop, Language: "pt-BR", Time: night, ScanCount: 500})
+	assert.Equal(t, "https://example.com/pt/night", route.Destination)
+
+	// The window runs past midnight into Sunday, but not into Monday
+	route = routing.Resolve(models.ScanContext{Language: "pt", Time: night.Add(24*time.Hour + 2*time.Hour), ScanCount: 500})
+	assert.Equal(t, "https://example.com", route.Destination)
+
+	query := url.Values{"utm_source": {"poster"}}
+	route = routing.Resolve(models.ScanContext{Time: night, ScanCount: 100, Query: query})
+	assert.Equal(t, "https://example.com/early", route.Destination)
+	route = routing.Resolve(models.ScanContext{Time: night, ScanCount: 101, Query: query})
+	assert.Equal(t, "https://example.com", route.Destination)
+	assert.Nil(t, route.Rule)
+}
+
+func TestRoutingVariants(t *testing.T) {
+	routing := &models.Routing{Variants: []models.Variant{
+		{Name: "a", URL: "https://example.com/a", Weight: 70},
+		{Name: "b", URL: "https://example.com/b", Weight: 30},
+	}}
+	assert.NoError(t, ValidateRouting(routing, models.QRCodeTypeDynamic))
+
+	// Scanners are spread by weight, and the same scanner gets the same variant
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		visitor := fmt.Sprintf("visitor-%d", i)
+		route := routing.Resolve(models.ScanContext{VisitorID: visitor})
+		counts[route.Variant.Name]++
+		assert.Equal(t, route.Variant, routing.Resolve(models.ScanContext{VisitorID: visitor}).Variant)
+	}
+	assert.InDelta(t, 700, counts["a"], 60)
+
+	// Scanners keep the variant assigned before
+	route := routing.Resolve(models.ScanContext{VisitorID: "visitor-1", Variant: "b"})
+	assert.Equal(t, "https://example.com/b", route.Destination)
+
+	routing.Variants[1].Weight = 40
+	assert.ErrorContains(t, ValidateRouting(routing, models.QRCodeTypeDynamic), "add up to 100")
+	routing.Variants[1] = models.Variant{Name: "a", URL: "https://example.com/b", Weight: 30}
+	assert.ErrorContains(t, ValidateRouting(routing, models.QRCodeTypeDynamic), "duplicate")
+}
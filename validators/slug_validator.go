@@ -0,0 +1,70 @@
+package validators
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// slugRegex accepts 3 to 50 lowercase letters, digits and inner hyphens.
+var slugRegex = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]{1,48})[a-z0-9]$`)
+
+// reservedSlugs are path segments the service routes, or may route, itself. Shorter ones
+// are already too short for a slug.
+var reservedSlugs = map[string]bool{
+	"admin": true, "api": true, "assets": true, "auth": true, "clientapps": true,
+	"conversions": true, "devices": true, "gtin": true, "health": true, "jwks": true,
+	"login": true, "logout": true, "media": true, "qrcodes": true, "redemptions": true,
+	"signed-payloads": true, "static": true, "templates": true, "unlock": true,
+	"well-known": true, "www": true,
+}
+
+// blockedWords are profanities kept out of slugs and generated short IDs, in English and Portuguese.
+var blockedWords = []string{
+	"arse", "ass", "bitch", "boceta", "bosta", "buceta", "caralho", "cock", "crap", "cu",
+	"cunt", "dick", "fdp", "foda", "fuck", "merda", "nazi", "nigger", "pica", "piss", "porn",
+	"porra", "puta", "rape", "sex", "shit", "slut", "twat", "viado", "whore",
+}
+
+// ErrBlockedSlug is returned for slugs that are reserved or contain a blocked word.
+var ErrBlockedSlug = errors.New("slug is reserved or not allowed")
+
+// ValidateSlug checks a custom slug of a client app or QR code.
+func ValidateSlug(slug string) error {
+	if !slugRegex.MatchString(slug) || strings.Contains(slug, "--") {
+		return fmt.Errorf("invalid slug %q: must be 3 to 50 lowercase letters, digits or single hyphens", slug)
+	}
+	if reservedSlugs[slug] || hasBlockedWord(slug) {
+		return ErrBlockedSlug
+	}
+	return nil
+}
+
+// hasBlockedWord reports whether one of the hyphen-separated words of a slug, or the slug
+// without its hyphens, is a blocked word. Words are not searched inside longer words, which
+// would reject innocent slugs such as "grapes" or "computador".
+func hasBlockedWord(slug string) bool {
+	words := strings.Split(slug, "-")
+	words = append(words, strings.Join(words, ""))
+	for _, word := range words {
+		for _, blocked := range blockedWords {
+			if word == blocked {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ContainsBlockedWord reports whether s contains a blocked word anywhere, ignoring case. Generated
+// short IDs are checked this strictly, since another one can be drawn at no cost.
+func ContainsBlockedWord(s string) bool {
+	s = strings.ToLower(s)
+	for _, blocked := range blockedWords {
+		if strings.Contains(s, blocked) {
+			return true
+		}
+	}
+	return false
+}
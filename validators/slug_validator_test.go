@@ -0,0 +1,24 @@
+package validators
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSlug(t *testing.T) {
+	for _, slug := range []string{"acme", "summer-sale-2024", "grapes", "computador", "a1b"} {
+		assert.NoError(t, ValidateSlug(slug), slug)
+	}
+	for _, slug := range []string{"ab", "Acme", "-acme", "acme-", "summer--sale", "café", "acme/sale"} {
+		assert.Error(t, ValidateSlug(slug), slug)
+	}
+	for _, slug := range []string{"qrcodes", "admin", "api", "shit", "big-fuck-sale", "pu-ta"} {
+		assert.ErrorIs(t, ValidateSlug(slug), ErrBlockedSlug, slug)
+	}
+}
+
+func TestContainsBlockedWord(t *testing.T) {
+	assert.True(t, ContainsBlockedWord("x7ShIt9a"))
+	assert.False(t, ContainsBlockedWord("aB3dE5fG"))
+}
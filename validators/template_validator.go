@@ -3,11 +3,13 @@ package validators
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/mca93/qrcode_service/models"
+	"github.com/mca93/qrcode_service/utils"
 )
 
 const (
@@ -15,7 +17,16 @@ const (
 	maxDescriptionLength = 500
 	minQRSize            = 100
 	maxQRSize            = 1000
-	colorRegex           = "^#([A-Fa-f0-9]{6}|[A-Fa-f0-9]{3})$"
+	colorRegex           = "^#([A-Fa-f0-9]{8}|[A-Fa-f0-9]{6}|[A-Fa-f0-9]{3,4})$"
+	maxGradientStops     = 10
+	maxCaptionLength     = 100
+	minCaptionFontSize   = 8
+	maxCaptionFontSize   = 200
+	minLogoScale         = 0.05
+	maxLogoScale         = 0.3
+	maxLogoPadding       = 4
+	maxLandingTitle      = 200
+	maxLandingLabel      = 100
 )
 
 // ValidateTemplateCreate validates a TemplateCreateRequest
@@ -40,6 +51,10 @@ func ValidateTemplateCreate(req models.TemplateCreateRequest) error {
 		return err
 	}
 
+	if err := validateEyeShape(req.EyeShape); err != nil {
+		return err
+	}
+
 	if err := validateColor(req.ForegroundColor, "foregroundColor"); err != nil {
 		return err
 	}
@@ -48,6 +63,18 @@ func ValidateTemplateCreate(req models.TemplateCreateRequest) error {
 		return err
 	}
 
+	if err := validateColor(req.EyeColor, "eyeColor"); err != nil {
+		return err
+	}
+
+	if err := validateGradient(req.ForegroundGradient, "foregroundGradient"); err != nil {
+		return err
+	}
+
+	if err := validateContrast(req); err != nil {
+		return err
+	}
+
 	if err := validateSize(req.Size); err != nil {
 		return err
 	}
@@ -56,10 +83,30 @@ func ValidateTemplateCreate(req models.TemplateCreateRequest) error {
 		return err
 	}
 
+	if err := validateLogoOptions(req.LogoOptions); err != nil {
+		return err
+	}
+
 	if err := validateErrorCorrection(req.ErrorCorrection); err != nil {
 		return err
 	}
 
+	if err := ValidateContentMapping(req.Content, req.Definition); err != nil {
+		return err
+	}
+
+	if err := validateFrame(req.Frame, req.Definition); err != nil {
+		return err
+	}
+
+	if err := validateLandingPage(req.LandingPage, req.Definition); err != nil {
+		return err
+	}
+
+	if err := validateSignedPayload(req.SignedPayload, req.Content, req.Definition); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -117,6 +164,16 @@ func validateDefinition(definition models.Definition) error {
 		if err := validateFieldValidations(field); err != nil {
 			return fmt.Errorf("invalid validations for field %s: %w", field.Name, err)
 		}
+
+		// The default value must satisfy the field's own validations
+		if err := validateFieldData(field, field.Default); err != nil {
+			return fmt.Errorf("invalid default for field %s: %w", field.Name, err)
+		}
+	}
+
+	// requiredIf/visibleIf must reference fields of this definition
+	if err := definition.ValidateConditions(); err != nil {
+		return err
 	}
 
 	return nil
@@ -188,16 +245,19 @@ func validateNumberValidations(validations map[string]interface{}) error {
 }
 
 func validateMediaValidations(validations map[string]interface{}) error {
-	// Example validation for media fields
 	if allowedTypes, ok := validations["allowedTypes"]; ok {
 		if types, ok := allowedTypes.([]interface{}); ok {
 			if len(types) == 0 {
 				return errors.New("allowedTypes must contain at least one type")
 			}
 			for _, t := range types {
-				if _, ok := t.(string); !ok {
+				mediaType, ok := t.(string)
+				if !ok {
 					return errors.New("allowedTypes must be an array of strings")
 				}
+				if parts := strings.Split(mediaType, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+					return fmt.Errorf("allowedTypes must be media types such as image/png or image/*, got %q", mediaType)
+				}
 			}
 		} else {
 			return errors.New("allowedTypes must be an array")
@@ -217,18 +277,23 @@ func validateMediaValidations(validations map[string]interface{}) error {
 	return nil
 }
 
-func validateShape(shape string) error {
+func validateShape(shape models.ModuleShape) error {
 	if shape == "" {
 		return nil // shape is optional
 	}
+	if !shape.IsValid() {
+		return fmt.Errorf("invalid shape: %s. Valid options are square, circle, rounded, dots, diamond, vertical-bars, horizontal-bars", shape)
+	}
+	return nil
+}
 
-	// Add shape validation if there are specific allowed shapes
-	// Example:
-	// allowedShapes := map[string]bool{"square": true, "circle": true, "rounded": true}
-	// if !allowedShapes[shape] {
-	//     return fmt.Errorf("invalid shape: %s. Allowed shapes are: square, circle, rounded", shape)
-	// }
-
+func validateEyeShape(shape models.EyeShape) error {
+	if shape == "" {
+		return nil // eye shape is optional
+	}
+	if !shape.IsValid() {
+		return fmt.Errorf("invalid eyeShape: %s. Valid options are square, rounded, circle", shape)
+	}
 	return nil
 }
 
@@ -242,9 +307,56 @@ func validateColor(color, fieldName string) error {
 		return fmt.Errorf("error validating %s: %v", fieldName, err)
 	}
 	if !matched {
-		return fmt.Errorf("invalid %s format, must be a valid hex color (e.g. #RRGGBB or #RGB)", fieldName)
+		return fmt.Errorf("invalid %s format, must be a valid hex color (e.g. #RRGGBB, #RGB or #RRGGBBAA with alpha)", fieldName)
+	}
+
+	return nil
+}
+
+func validateGradient(gradient *models.Gradient, fieldName string) error {
+	if gradient == nil {
+		return nil // gradient is optional
+	}
+	if !gradient.Type.IsValid() {
+		return fmt.Errorf("invalid %s type: %s. Valid options are linear, radial", fieldName, gradient.Type)
+	}
+	if len(gradient.Stops) < 2 || len(gradient.Stops) > maxGradientStops {
+		return fmt.Errorf("%s must have between 2 and %d stops", fieldName, maxGradientStops)
 	}
+	for i, stop := range gradient.Stops {
+		if stop.Offset < 0 || stop.Offset > 1 {
+			return fmt.Errorf("%s stop offsets must be between 0 and 1", fieldName)
+		}
+		if i > 0 && stop.Offset < gradient.Stops[i-1].Offset {
+			return fmt.Errorf("%s stop offsets must be in ascending order", fieldName)
+		}
+		if stop.Color == "" {
+			return fmt.Errorf("%s stop color is required", fieldName)
+		}
+		if err := validateColor(stop.Color, fieldName+" stop color"); err != nil {
+			return err
+		}
+	}
+	for _, center := range []*float64{gradient.CenterX, gradient.CenterY} {
+		if center != nil && (*center < 0 || *center > 1) {
+			return fmt.Errorf("%s center must be between 0 and 1", fieldName)
+		}
+	}
+	if gradient.Radius < 0 || gradient.Radius > 2 {
+		return fmt.Errorf("%s radius must be between 0 and 2", fieldName)
+	}
+	return nil
+}
 
+// validateContrast rejects foreground paints that would be too close to the background to scan.
+func validateContrast(req models.TemplateCreateRequest) error {
+	contrast, err := utils.ForegroundContrast(req.ForegroundColor, req.ForegroundGradient, req.EyeColor, req.BackgroundColor)
+	if err != nil {
+		return err
+	}
+	if contrast < utils.MinContrastRatio {
+		return fmt.Errorf("contrast between the foreground and the background is %.2f, must be at least %.1f", contrast, utils.MinContrastRatio)
+	}
 	return nil
 }
 
@@ -255,20 +367,40 @@ func validateSize(size int) error {
 	return nil
 }
 
+// validateLogoURL accepts http(s) URLs. Values without a scheme are the paths of uploaded
+// logos, which only the server sets.
 func validateLogoURL(logoURL string) error {
 	if logoURL == "" {
 		return nil // logo is optional
 	}
 
-	// Add URL validation if needed
-	// Example:
-	// if _, err := url.ParseRequestURI(logoURL); err != nil {
-	//     return fmt.Errorf("invalid logo URL: %v", err)
-	// }
+	parsed, err := url.Parse(logoURL)
+	if err != nil {
+		return fmt.Errorf("invalid logoUrl: %v", err)
+	}
+	if parsed.Scheme != "" && !utils.IsRemoteLogoURL(logoURL) {
+		return errors.New("logoUrl must be an http or https URL")
+	}
 
 	return nil
 }
 
+func validateLogoOptions(opts *models.LogoOptions) error {
+	if opts == nil {
+		return nil // logo options are optional
+	}
+	if opts.Scale != 0 && (opts.Scale < minLogoScale || opts.Scale > maxLogoScale) {
+		return fmt.Errorf("logoOptions scale must be between %.2f and %.1f", minLogoScale, maxLogoScale)
+	}
+	if opts.Padding != nil && (*opts.Padding < 0 || *opts.Padding > maxLogoPadding) {
+		return fmt.Errorf("logoOptions padding must be between 0 and %d modules", maxLogoPadding)
+	}
+	if opts.Plate != "" && !opts.Plate.IsValid() {
+		return fmt.Errorf("invalid logoOptions plate: %s. Valid options are none, square, rounded", opts.Plate)
+	}
+	return validateColor(opts.PlateColor, "logoOptions plateColor")
+}
+
 func validateErrorCorrection(ec models.QRCodeErrorCorrection) error {
 	switch ec {
 	case models.ErrorCorrectionL, models.ErrorCorrectionM, models.ErrorCorrectionQ, models.ErrorCorrectionH, "":
@@ -278,6 +410,204 @@ func validateErrorCorrection(ec models.QRCodeErrorCorrection) error {
 	}
 }
 
+// ValidateContentMapping validates how a template assembles its encoded content from Data.
+func ValidateContentMapping(mapping *models.ContentMapping, definition models.Definition) error {
+	if mapping == nil || mapping.Format == "" {
+		return nil // content mapping is optional
+	}
+	if !mapping.Format.IsValid() {
+		return fmt.Errorf("invalid content format: %s. Valid options are deeplink, url, keyvalue, json", mapping.Format)
+	}
+
+	fields := make(map[string]models.Field)
+	for _, field := range definition {
+		fields[field.Name] = field
+	}
+	// The content is readable by anyone holding the printed code
+	checkField := func(name, what string) error {
+		field, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("%s references unknown field: %s", what, name)
+		}
+		if field.Sensitive {
+			return fmt.Errorf("%s cannot reference sensitive field: %s", what, name)
+		}
+		return nil
+	}
+
+	switch mapping.Format {
+	case models.ContentFormatURL:
+		if strings.TrimSpace(mapping.Template) == "" {
+			return errors.New("content template is required for the url format")
+		}
+		names, err := utils.PlaceholderNames(mapping.Template)
+		if err != nil {
+			return fmt.Errorf("invalid content template: %w", err)
+		}
+		for _, name := range names {
+			if name == models.PlaceholderQRCodeID || name == models.PlaceholderDeepLinkURL {
+				continue
+			}
+			if err := checkField(name, "content template"); err != nil {
+				return err
+			}
+		}
+		sample, _ := utils.ExpandPlaceholders(mapping.Template, func(string) (string, error) { return "x", nil }, nil)
+		if parsed, err := url.Parse(sample); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return errors.New("content template must be an absolute URL")
+		}
+	case models.ContentFormatKeyValue, models.ContentFormatJSON:
+		for _, name := range mapping.Fields {
+			if err := checkField(name, "content mapping"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateFrame validates the frame drawn around the symbol and its caption.
+func validateFrame(frame *models.Frame, definition models.Definition) error {
+	if frame == nil {
+		return nil // frame is optional
+	}
+	if !frame.Style.IsValid() {
+		return fmt.Errorf("invalid frame style: %s. Valid options are border, card, banner", frame.Style)
+	}
+	if frame.CaptionPosition != "" && !frame.CaptionPosition.IsValid() {
+		return fmt.Errorf("invalid frame captionPosition: %s. Valid options are top, bottom", frame.CaptionPosition)
+	}
+	if err := validateColor(frame.Color, "frame color"); err != nil {
+		return err
+	}
+	if err := validateColor(frame.TextColor, "frame textColor"); err != nil {
+		return err
+	}
+	if frame.Font != "" && !utils.IsBundledFont(frame.Font) {
+		return fmt.Errorf("invalid frame font: %s. Valid options are %s", frame.Font, strings.Join(utils.BundledFontNames(), ", "))
+	}
+	if frame.FontSize != 0 && (frame.FontSize < minCaptionFontSize || frame.FontSize > maxCaptionFontSize) {
+		return fmt.Errorf("frame fontSize must be between %d and %d", minCaptionFontSize, maxCaptionFontSize)
+	}
+
+	if len([]rune(frame.Caption)) > maxCaptionLength {
+		return fmt.Errorf("frame caption must be less than %d characters", maxCaptionLength)
+	}
+	return validateDisplayText(frame.Caption, "frame caption", definition)
+}
+
+// validateDisplayText checks that the placeholders of text shown with a QR code refer to
+// definition fields that are not sensitive, or to the QR code itself.
+func validateDisplayText(text, fieldName string, definition models.Definition) error {
+	names, err := utils.PlaceholderNames(text)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", fieldName, err)
+	}
+	fields := make(map[string]models.Field)
+	for _, field := range definition {
+		fields[field.Name] = field
+	}
+	for _, name := range names {
+		if name == models.PlaceholderQRCodeID || name == models.PlaceholderDeepLinkURL {
+			continue
+		}
+		field, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("%s references unknown field: %s", fieldName, name)
+		}
+		if field.Sensitive {
+			return fmt.Errorf("%s cannot reference sensitive field: %s", fieldName, name)
+		}
+	}
+	return nil
+}
+
+// validateLandingPage validates the landing page DYNAMIC QR codes of the template open on.
+func validateLandingPage(page *models.LandingPage, definition models.Definition) error {
+	if page == nil {
+		return nil // landing page is optional
+	}
+	if page.Layout != "" && !page.Layout.IsValid() {
+		return fmt.Errorf("invalid landingPage layout: %s. Valid options are sheet, product, menu, card", page.Layout)
+	}
+	for _, text := range []struct{ name, value string }{
+		{"landingPage title", page.Title},
+		{"landingPage subtitle", page.Subtitle},
+	} {
+		if len([]rune(text.value)) > maxLandingTitle {
+			return fmt.Errorf("%s must be less than %d characters", text.name, maxLandingTitle)
+		}
+		if err := validateDisplayText(text.value, text.name, definition); err != nil {
+			return err
+		}
+	}
+
+	fieldNames := make(map[string]bool)
+	for _, field := range definition {
+		fieldNames[field.Name] = true
+	}
+	seen := make(map[string]bool)
+	for _, name := range page.Fields {
+		if !fieldNames[name] {
+			return fmt.Errorf("landingPage fields references unknown field: %s", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("landingPage fields lists %s more than once", name)
+		}
+		seen[name] = true
+	}
+	for name, label := range page.Labels {
+		if !fieldNames[name] {
+			return fmt.Errorf("landingPage labels references unknown field: %s", name)
+		}
+		if len([]rune(label)) > maxLandingLabel {
+			return fmt.Errorf("landingPage label for %s must be less than %d characters", name, maxLandingLabel)
+		}
+	}
+
+	if err := validateColor(page.AccentColor, "landingPage accentColor"); err != nil {
+		return err
+	}
+	return validateColor(page.BackgroundColor, "landingPage backgroundColor")
+}
+
+// validateSignedPayload validates the signed token the template's QR codes carry.
+func validateSignedPayload(payload *models.SignedPayload, content *models.ContentMapping, definition models.Definition) error {
+	if payload == nil {
+		return nil // signing is optional
+	}
+	if payload.Embed != "" && !payload.Embed.IsValid() {
+		return fmt.Errorf("invalid signedPayload embed: %s. Valid options are token, query", payload.Embed)
+	}
+	// Only URLs have a query string to carry the token
+	if payload.Embed == models.SignedPayloadEmbedQuery && content != nil && content.Format != "" &&
+		content.Format != models.ContentFormatDeepLink && content.Format != models.ContentFormatURL {
+		return fmt.Errorf("signedPayload embed query requires deeplink or url content, not %s", content.Format)
+	}
+
+	fields := make(map[string]models.Field)
+	for _, field := range definition {
+		fields[field.Name] = field
+	}
+	seen := make(map[string]bool)
+	for _, name := range payload.Fields {
+		field, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("signedPayload fields references unknown field: %s", name)
+		}
+		// Token claims are readable by anyone holding the printed code
+		if field.Sensitive {
+			return fmt.Errorf("signedPayload fields cannot include sensitive field: %s", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("signedPayload fields lists %s more than once", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
 // ValidateTemplateFilters validates template filter parameters
 func ValidateTemplateFilters(active *bool, clientAppID string, createdAtFrom, createdAtTo *time.Time) error {
 	if clientAppID != "" {